@@ -2,14 +2,19 @@ package bee
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"reflect"
+	"runtime/debug"
 	"slices"
 	"sort"
 	"strings"
@@ -17,6 +22,8 @@ import (
 	"syscall"
 	"time"
 	"unicode"
+
+	"github.com/iancoleman/strcase"
 )
 
 const (
@@ -29,28 +36,56 @@ var osExit = os.Exit
 // App is a typed application runner with config parsing, commands, context,
 // supervised goroutines, and graceful shutdown.
 type App[T any] struct {
-	name        string
-	Cfg         *T
-	commandLine *commandLine
-	timeout     time.Duration
-	logLevel    slog.Leveler
-	Log         *slog.Logger
-	output      io.Writer
-	defaultCmd  string
-	parentUsage string
-	commands    map[string]*Cmd[T]
-	root        *Cmd[T]
-	closers     []c
-	Ctx         context.Context
-	cancel      context.CancelFunc
-	signalCh    chan os.Signal
-	wg          sync.WaitGroup
-	wgMu        sync.Mutex
-	goroutines  int
-	errMu       sync.Mutex
-	runErr      error
+	name         string
+	Cfg          *T
+	commandLine  *commandLine
+	timeout      time.Duration
+	parallel     bool
+	failOnListen bool
+	logLevel     slog.Leveler
+	Log          *slog.Logger
+	logHandler   slog.Handler
+	logFlushed   sync.Once
+	output       io.Writer
+	defaultCmd   string
+	argsFiles    bool
+	parentUsage  string
+	commands     map[string]*Cmd[T]
+	root         *Cmd[T]
+	closers      []c
+	preClosers   []c
+	healthMu     sync.RWMutex
+	healthChecks []healthCheck
+	preTimeout   time.Duration
+	preDone      chan struct{}
+	Ctx          context.Context
+	cancel       context.CancelFunc
+	signals      []os.Signal
+	signalCh     chan os.Signal
+	handlerCh    chan os.Signal
+	handlers     map[os.Signal]func(context.Context) error
+	reloaders    []func(cfg *T) error
+	flags        []string
+	wg           sync.WaitGroup
+	wgMu         sync.Mutex
+	goroutines   int
+	listening    []chan string
+	errMu        sync.Mutex
+	runErr       error
+	runMu        sync.Mutex
+	runState     runState
+	runResult    error
 }
 
+// runState tracks whether the application has been run.
+type runState int
+
+const (
+	runIdle runState = iota
+	runRunning
+	runFinished
+)
+
 // Handler is an application or command handler.
 type Handler[T any] func(*Ctx[T]) error
 
@@ -69,20 +104,42 @@ type Cmd[T any] struct {
 	path        string
 	description string
 	handler     Handler[T]
+	config      any
 	parent      *Cmd[T]
 	children    map[string]*Cmd[T]
 	app         *App[T]
 }
 
 type appOptions struct {
-	timeout       time.Duration
-	logLevel      slog.Leveler
-	log           *slog.Logger
-	output        io.Writer
-	lookupEnvFunc func(string) (string, bool)
-	errorHandling flag.ErrorHandling
-	defaultCmd    string
-	parentUsage   string
+	timeout        time.Duration
+	preTimeout     time.Duration
+	parallel       bool
+	failOnListen   bool
+	logLevel       slog.Leveler
+	logTime        func(slog.Attr) slog.Attr
+	logFormat      string
+	logOutput      io.Writer
+	logTee         []io.Writer
+	logAttrs       []any
+	log            *slog.Logger
+	output         io.Writer
+	lookupEnvFunc  func(string) (string, bool)
+	secretsDir     string
+	strictEnv      bool
+	configFile     string
+	configOptional bool
+	configFlag     string
+	configEnv      string
+	errorHandling  flag.ErrorHandling
+	errorFormat    func(io.Writer, error)
+	defaultCmd     string
+	signals        []os.Signal
+	signalHandlers map[os.Signal]func(context.Context) error
+	dumpSignal     os.Signal
+	defaultLogger  bool
+	argsFiles      bool
+	parentUsage    string
+	name           string
 }
 
 // Option defines application option type.
@@ -94,50 +151,156 @@ func New[T any](name string, cfg *T, opts ...Option) *App[T] {
 		panic("bee: invalid nil config")
 	}
 
+	options := newAppOptions(opts)
+	cl := newOptionsCommandLine(name, options)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	app := &App[T]{ //nolint:exhaustruct
+		name:         name,
+		Cfg:          cfg,
+		commandLine:  cl,
+		timeout:      options.timeout,
+		preTimeout:   options.preTimeout,
+		preDone:      make(chan struct{}),
+		parallel:     options.parallel,
+		failOnListen: options.failOnListen,
+		logLevel:     options.logLevel,
+		output:       options.output,
+		defaultCmd:   normalizeCommandPath(options.defaultCmd),
+		argsFiles:    options.argsFiles,
+		parentUsage:  options.parentUsage,
+		commands:     map[string]*Cmd[T]{},
+		Ctx:          ctx,
+		cancel:       cancel,
+		signals:      options.signals,
+		signalCh:     make(chan os.Signal, 1),
+		handlerCh:    make(chan os.Signal, 1),
+		handlers:     options.signalHandlers,
+	}
+	logOutput := options.logOutput
+	if len(options.logTee) > 0 {
+		logOutput = io.MultiWriter(append([]io.Writer{logOutput}, options.logTee...)...)
+	}
+
+	app.Log = slog.New(newLogHandler(logOutput, options)).With(slog.String("service", name))
+	if options.log != nil {
+		app.Log = options.log
+	}
+
+	// Keep the handler before attributes are added, as handlers returned by WithAttrs may not
+	// implement Flush or io.Closer.
+	app.logHandler = app.Log.Handler()
+	cl.beforeExit = app.flushLog
+
+	if len(options.logAttrs) > 0 {
+		app.Log = app.Log.With(options.logAttrs...)
+	}
+
+	if options.defaultLogger {
+		slog.SetDefault(app.Log)
+	}
+
+	if options.dumpSignal != nil {
+		if app.handlers == nil {
+			app.handlers = map[os.Signal]func(context.Context) error{}
+		}
+
+		app.handlers[options.dumpSignal] = app.logConfig
+	}
+
+	if options.parentUsage != "" {
+		app.commandLine.flagSet.Usage = func() {
+			_, _ = fmt.Fprintf(app.output, "Usage of %s %s:\n", options.parentUsage, app.name)
+			app.commandLine.flagSet.PrintDefaults()
+		}
+	}
+
+	return app
+}
+
+// ParseConfig parses config of type T from args, environment variables, secret files and config
+// file, configured by the same options as New, without running an application, i.e. in tools and
+// tests. Unlike New, parse errors are returned by default instead of exiting, unless overridden by
+// WithErrorHandling. The env variables are prefixed with the name set by WithName, the program name
+// by default. Options not related to parsing are ignored.
+func ParseConfig[T any](args []string, opts ...Option) (*T, error) {
+	options := newAppOptions(append([]Option{WithErrorHandling(flag.ContinueOnError)}, opts...))
+
+	name := options.name
+	if name == "" {
+		name = filepath.Base(os.Args[0])
+	}
+
+	cfg := new(T)
+	if err := newOptionsCommandLine(name, options).parse(cfg, args); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// newAppOptions returns default application options modified by opts.
+func newAppOptions(opts []Option) appOptions {
 	options := appOptions{ //nolint:exhaustruct
 		timeout:       defaultShutdownGracePeriod,
 		output:        os.Stderr,
+		logOutput:     os.Stdout,
 		lookupEnvFunc: os.LookupEnv,
 		errorHandling: flag.ExitOnError,
+		failOnListen:  true,
+		signals:       []os.Signal{syscall.SIGINT, syscall.SIGTERM},
 	}
 	for _, opt := range opts {
 		opt(&options)
 	}
 
+	return options
+}
+
+// newOptionsCommandLine creates command line configured by application options.
+func newOptionsCommandLine(name string, options appOptions) *commandLine {
 	cl := newCommandLine(name)
 	cl.output = options.output
 	cl.lookupEnvFunc = options.lookupEnvFunc
+	cl.secretsDir = options.secretsDir
+	cl.strictEnv = options.strictEnv
+	cl.configFile = options.configFile
+	cl.configOptional = options.configOptional
+	cl.configFlag = options.configFlag
+	cl.configEnv = options.configEnv
 	cl.errorHandling = options.errorHandling
+	if options.errorFormat != nil {
+		cl.errorFormat = options.errorFormat
+	}
 	cl.flagSet.SetOutput(options.output)
 
-	ctx, cancel := context.WithCancel(context.Background())
-	app := &App[T]{ //nolint:exhaustruct
-		name:        name,
-		Cfg:         cfg,
-		commandLine: cl,
-		timeout:     options.timeout,
-		logLevel:    options.logLevel,
-		output:      options.output,
-		defaultCmd:  normalizeCommandPath(options.defaultCmd),
-		parentUsage: options.parentUsage,
-		commands:    map[string]*Cmd[T]{},
-		Ctx:         ctx,
-		cancel:      cancel,
-		signalCh:    make(chan os.Signal, 1),
-	}
-	app.Log = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: app.logLevel})) //nolint:exhaustruct
-	if options.log != nil {
-		app.Log = options.log
-	}
+	return cl
+}
 
-	if options.parentUsage != "" {
-		app.commandLine.flagSet.Usage = func() {
-			_, _ = fmt.Fprintf(app.output, "Usage of %s %s:\n", options.parentUsage, app.name)
-			app.commandLine.flagSet.PrintDefaults()
+// newLogHandler creates the default JSON or text log handler configured by application options.
+func newLogHandler(w io.Writer, options appOptions) slog.Handler {
+	handlerOptions := &slog.HandlerOptions{Level: options.logLevel} //nolint:exhaustruct
+	if options.logTime != nil {
+		handlerOptions.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey && a.Value.Kind() == slog.KindTime {
+				return options.logTime(a)
+			}
+
+			return a
 		}
 	}
 
-	return app
+	if options.logFormat == "text" {
+		return slog.NewTextHandler(w, handlerOptions)
+	}
+
+	return slog.NewJSONHandler(w, handlerOptions)
+}
+
+// NewLifecycle creates an application without config which only manages the
+// application lifecycle: context, supervised goroutines, signals, and shutdown.
+func NewLifecycle(name string, opts ...Option) *App[struct{}] {
+	return New(name, &struct{}{}, opts...)
 }
 
 // Register registers closer to be called on graceful shutdown.
@@ -145,21 +308,81 @@ func (c Ctx[T]) Register(name string, closer func(ctx context.Context) error) {
 	c.appRuntime().Register(name, closer)
 }
 
+// RegisterWithTimeout registers closer to be called on graceful shutdown with its own timeout.
+func (c Ctx[T]) RegisterWithTimeout(name string, timeout time.Duration, closer func(ctx context.Context) error) {
+	c.appRuntime().RegisterWithTimeout(name, timeout, closer)
+}
+
+// RegisterWithDeps registers closer which depends on closers registered with names deps.
+func (c Ctx[T]) RegisterWithDeps(name string, deps []string, closer func(ctx context.Context) error) {
+	c.appRuntime().RegisterWithDeps(name, deps, closer)
+}
+
+// RegisterWithCriticality registers closer which, if not critical, doesn't delay exit after the
+// shutdown timeout.
+func (c Ctx[T]) RegisterWithCriticality(name string, critical bool, closer func(ctx context.Context) error) {
+	c.appRuntime().RegisterWithCriticality(name, critical, closer)
+}
+
+// RegisterPreShutdown registers hook to be called when shutdown begins, before
+// the shutdown grace period starts.
+func (c Ctx[T]) RegisterPreShutdown(name string, fn func(ctx context.Context) error) {
+	c.appRuntime().RegisterPreShutdown(name, fn)
+}
+
 // Go starts a supervised goroutine with the application context.
 func (c Ctx[T]) Go(name string, fn func(context.Context) error) {
 	c.appRuntime().Go(name, fn)
 }
 
+// runGoroutine runs fn and converts its panic into an error, logging the panic with stack trace.
+func (a *App[T]) runGoroutine(name string, fn func(context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			a.Log.Error("goroutine panic", slog.String("name", name), slog.Any("panic", r),
+				slog.String("stack", string(debug.Stack())))
+
+			err = fmt.Errorf("goroutine %s panicked: %v", name, r)
+		}
+	}()
+
+	return fn(a.Ctx)
+}
+
 // HTTPServer starts an HTTP server as a supervised goroutine.
 func (c Ctx[T]) HTTPServer(name string, server *http.Server) {
 	c.appRuntime().HTTPServer(name, server)
 }
 
+// Args returns positional arguments remaining after flags.
+func (c Ctx[T]) Args() []string {
+	return c.appRuntime().Args()
+}
+
+// DumpConfig writes the resolved config with the source of each value.
+func (c Ctx[T]) DumpConfig(w io.Writer) {
+	c.appRuntime().DumpConfig(w)
+}
+
 // Exit records a fatal application result and cancels the application context.
 func (c Ctx[T]) Exit(message string, err error) {
 	c.appRuntime().Exit(message, err)
 }
 
+// Sub returns context of a component, i.e. a worker started by the command handler, which shares
+// the application config, context, supervised goroutines and closers, so closers registered on it
+// run during the application shutdown, while its logger adds subcommand attribute with name.
+func (c Ctx[T]) Sub(name string) *Ctx[T] {
+	app := c.appRuntime()
+
+	return &Ctx[T]{
+		Cfg: c.Cfg,
+		Log: c.Log.With(slog.String("subcommand", name)),
+		Ctx: c.Ctx,
+		app: app,
+	}
+}
+
 func (c Ctx[T]) appRuntime() *App[T] {
 	if c.app == nil {
 		panic("bee: runtime method called on context not created by App")
@@ -187,6 +410,22 @@ func (c *Cmd[T]) Cmd(name string, description string, handler ...Handler[T]) *Cm
 	return c.appRuntime().addCommand(c, name, description, handler...)
 }
 
+// Config sets pointer to struct with command's own config, i.e. migration settings used only by
+// "migrate" command. Its fields are parsed, together with the application config, only when the
+// command is selected, and their environment variables are prefixed with the application name
+// followed by the command path, i.e. MAIA_MIGRATE_DSN. Flag names must not conflict with the
+// application config. It panics if cfg is not a non-nil pointer to struct.
+func (c *Cmd[T]) Config(cfg any) *Cmd[T] {
+	v := reflect.ValueOf(cfg)
+	if !v.IsValid() || v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("bee: invalid config of command %q", c.path))
+	}
+
+	c.config = cfg
+
+	return c
+}
+
 func (c *Cmd[T]) appRuntime() *App[T] {
 	if c == nil || c.app == nil {
 		panic("bee: command method called on command not created by App")
@@ -202,10 +441,77 @@ func (a *App[T]) Root(description string, handler Handler[T]) {
 
 // Register registers closer to be called on graceful shutdown.
 func (a *App[T]) Register(name string, closer func(ctx context.Context) error) {
-	a.closers = append(a.closers, c{name: name, inner: closer})
+	a.closers = append(a.closers, c{name: name, timeout: a.timeout, inner: closer})
 }
 
-// Go starts a supervised goroutine with the application context.
+// RegisterWithTimeout registers closer to be called on graceful shutdown with its own timeout
+// instead of the shutdown timeout, so a slow closer doesn't starve the others. Zero timeout means
+// no deadline.
+func (a *App[T]) RegisterWithTimeout(name string, timeout time.Duration, closer func(ctx context.Context) error) {
+	a.closers = append(a.closers, c{name: name, timeout: timeout, inner: closer})
+}
+
+// RegisterWithDeps registers closer which depends on closers registered with names deps, i.e.
+// "db" depending on "migrations". Dependents are closed before their dependencies, otherwise
+// closers are called in reverse order of registration.
+func (a *App[T]) RegisterWithDeps(name string, deps []string, closer func(ctx context.Context) error) {
+	a.closers = append(a.closers, c{name: name, deps: slices.Clone(deps), timeout: a.timeout, inner: closer})
+}
+
+// RegisterWithCriticality registers closer to be called on graceful shutdown. Critical closers
+// hold shutdown until they return, like closers registered with Register. Non-critical closers,
+// i.e. flushing optional metrics, which still run when the shutdown timeout expires are detached
+// and logged, so they don't delay exit.
+func (a *App[T]) RegisterWithCriticality(name string, critical bool, closer func(ctx context.Context) error) {
+	a.closers = append(a.closers, c{name: name, timeout: a.timeout, detach: !critical, inner: closer})
+}
+
+// RegisterPreShutdown registers hook to be called when shutdown begins, before
+// the shutdown grace period starts. Hooks are not bound by the shutdown timeout,
+// only by the optional pre-shutdown timeout.
+func (a *App[T]) RegisterPreShutdown(name string, fn func(ctx context.Context) error) {
+	a.preClosers = append(a.preClosers, c{name: name, inner: fn})
+}
+
+// Args returns positional arguments remaining after flags of the selected command, i.e. files to
+// process. Flag parsing stops at the first non-flag argument or at "--" terminator, so arguments
+// after "--" which look like flags, i.e. "-v", are returned as they are and don't affect config.
+func (a *App[T]) Args() []string {
+	return slices.Clone(a.commandLine.flagSet.Args())
+}
+
+// DumpConfig writes the resolved config for debugging, one line per flag with its value and
+// source, i.e. "port=9090 (flag)". Sources are default, file, secret, env and flag. Values of
+// fields tagged with secret are masked.
+func (a *App[T]) DumpConfig(w io.Writer) {
+	a.commandLine.dumpConfig(w)
+}
+
+// FlagSpecJSON returns JSON array describing name, type, default value, environment variable and
+// help of each flag, sorted by name, i.e. for shell completion tools. Defaults of fields tagged
+// with secret are hidden.
+func (a *App[T]) FlagSpecJSON() ([]byte, error) {
+	specs, err := newCommandLine(a.name).flagSpecs(new(T))
+	if err != nil {
+		return nil, fmt.Errorf("flag spec: %w", err)
+	}
+
+	return json.Marshal(specs) //nolint:wrapcheck
+}
+
+// WriteEnvTemplate writes sample .env file, i.e. .env.example, with KEY=default # help line for
+// each config field, using the same environment variable names, defaults and help as parsing.
+// Values of fields tagged with secret are left empty.
+func (a *App[T]) WriteEnvTemplate(w io.Writer) error {
+	if err := newCommandLine(a.name).writeEnvTemplate(w, new(T)); err != nil {
+		return fmt.Errorf("env template: %w", err)
+	}
+
+	return nil
+}
+
+// Go starts a supervised goroutine with the application context. Its error or panic, which is
+// recovered and logged, starts graceful shutdown.
 func (a *App[T]) Go(name string, fn func(context.Context) error) {
 	a.wgMu.Lock()
 	a.goroutines++
@@ -216,7 +522,7 @@ func (a *App[T]) Go(name string, fn func(context.Context) error) {
 		defer a.wg.Done()
 
 		a.Log.Debug("goroutine start", slog.String("name", name))
-		if err := fn(a.Ctx); err != nil {
+		if err := a.runGoroutine(name, fn); err != nil {
 			a.Log.Error("goroutine error", slog.String("name", name), SlogError(err))
 			a.recordErr(err)
 			a.cancel()
@@ -228,9 +534,38 @@ func (a *App[T]) Go(name string, fn func(context.Context) error) {
 }
 
 // HTTPServer starts an HTTP server as a supervised goroutine and shuts it down
-// when the application context is cancelled.
+// when the application context is cancelled, once pre-shutdown hooks finish, so
+// the grace period of the server starts after them. Failure to listen, i.e.
+// because the address is already in use, starts graceful shutdown unless the
+// application was created with WithFailOnListenError(false).
 func (a *App[T]) HTTPServer(name string, server *http.Server) {
+	listening := make(chan string, 1)
+
+	a.wgMu.Lock()
+	a.listening = append(a.listening, listening)
+	a.wgMu.Unlock()
+
 	a.Go(name, func(ctx context.Context) error {
+		addr := server.Addr
+		if addr == "" {
+			addr = ":http"
+		}
+
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			close(listening)
+
+			if !a.failOnListen {
+				a.Log.Error("http server listen", slog.String("name", name), SlogError(err))
+
+				return nil
+			}
+
+			return err
+		}
+
+		listening <- ln.Addr().String()
+
 		shutdownStarted := make(chan struct{})
 		shutdownErr := make(chan error, 1)
 		serveDone := make(chan struct{})
@@ -238,15 +573,23 @@ func (a *App[T]) HTTPServer(name string, server *http.Server) {
 		go func() {
 			select {
 			case <-ctx.Done():
+			case <-serveDone:
+				return
+			}
+
+			// Keep serving, i.e. to report not ready, until pre-shutdown hooks finish, so the
+			// grace period starts after them.
+			select {
+			case <-a.preDone:
 				close(shutdownStarted)
-				shutdownCtx, cancel := context.WithTimeout(context.Background(), a.timeout)
+				shutdownCtx, cancel := timeoutContext(a.timeout)
 				defer cancel()
 				shutdownErr <- server.Shutdown(shutdownCtx)
 			case <-serveDone:
 			}
 		}()
 
-		err := server.ListenAndServe()
+		err = server.Serve(ln)
 		close(serveDone)
 		if errors.Is(err, http.ErrServerClosed) {
 			select {
@@ -264,6 +607,71 @@ func (a *App[T]) HTTPServer(name string, server *http.Server) {
 	})
 }
 
+// handleSignals runs registered signal handlers until the application context is cancelled.
+func (a *App[T]) handleSignals() {
+	for {
+		select {
+		case <-a.Ctx.Done():
+			return
+		case sig := <-a.handlerCh:
+			fn, ok := a.handlers[sig]
+			if !ok {
+				continue
+			}
+
+			a.Log.Debug("signal handler", slog.String("signal", sig.String()))
+			if err := fn(a.Ctx); err != nil {
+				a.Log.Error("signal handler", slog.String("signal", sig.String()), SlogError(err))
+			}
+		}
+	}
+}
+
+// OnReload registers fn to be called with freshly parsed config, using the same command line
+// flags, environment variables and config file, whenever the application receives SIGHUP, which
+// then doesn't terminate it. Config of the running application is not modified, so fn applies
+// the settings which can change safely, i.e. log level. Parse and fn errors are logged and the
+// application keeps running. It must be called before Run.
+func (a *App[T]) OnReload(fn func(cfg *T) error) {
+	a.reloaders = append(a.reloaders, fn)
+
+	if a.handlers == nil {
+		a.handlers = map[os.Signal]func(context.Context) error{}
+	}
+
+	a.handlers[syscall.SIGHUP] = a.reload
+}
+
+// reload parses config again and calls registered reload callbacks.
+func (a *App[T]) reload(context.Context) error {
+	a.wgMu.Lock()
+	flags := a.flags
+	cl := a.commandLine.reloadCommandLine()
+	a.wgMu.Unlock()
+
+	cfg := new(T)
+	if err := cl.parse(cfg, flags); err != nil {
+		return fmt.Errorf("reloading config: %w", err)
+	}
+
+	for _, fn := range a.reloaders {
+		if err := fn(cfg); err != nil {
+			return fmt.Errorf("reloading config: %w", err)
+		}
+	}
+
+	a.Log.Info("config reloaded")
+
+	return nil
+}
+
+// logConfig logs the config with values of fields tagged with secret redacted.
+func (a *App[T]) logConfig(context.Context) error {
+	a.Log.Info("config", SlogConfig(a.Cfg))
+
+	return nil
+}
+
 // Exit records a fatal application result and cancels the application context.
 func (a *App[T]) Exit(message string, err error) {
 	if err != nil {
@@ -287,20 +695,83 @@ func (a *App[T]) Run() {
 	}
 }
 
-// RunE runs the application and returns a testable error instead of exiting.
+// RunE runs the application and returns a testable error instead of exiting. Before returning,
+// the log handler is flushed and closed if it implements Flush() error or io.Closer.
 func (a *App[T]) RunE(args ...string) error {
-	signal.Notify(a.signalCh, syscall.SIGINT, syscall.SIGTERM)
-	defer signal.Stop(a.signalCh)
+	return a.RunContext(context.Background(), args...)
+}
+
+// RunContext runs the application like RunE, but also starts graceful shutdown when ctx is
+// cancelled, i.e. to embed the application in a larger supervision tree. The application runs only
+// once: subsequent calls return ErrAlreadyRunning while it runs and the result of the first run
+// afterwards, without blocking.
+func (a *App[T]) RunContext(ctx context.Context, args ...string) error {
+	a.runMu.Lock()
+	if a.runState != runIdle {
+		defer a.runMu.Unlock()
+
+		if a.runState == runRunning {
+			return ErrAlreadyRunning
+		}
+
+		return a.runResult
+	}
+
+	a.runState = runRunning
+	a.runMu.Unlock()
+
+	err := a.run(ctx, args...)
+
+	a.runMu.Lock()
+	a.runState = runFinished
+	a.runResult = err
+	a.runMu.Unlock()
+
+	return err
+}
+
+func (a *App[T]) run(ctx context.Context, args ...string) error {
+	start := time.Now()
+
+	defer a.flushLog()
+
+	if len(a.signals) > 0 {
+		signal.Notify(a.signalCh, a.signals...)
+		defer signal.Stop(a.signalCh)
+	}
 	defer a.cancel()
 
 	go func() {
 		select {
 		case <-a.Ctx.Done():
+		case <-ctx.Done():
+			a.cancel()
 		case <-a.signalCh:
 			a.cancel()
 		}
 	}()
 
+	if len(a.handlers) > 0 {
+		signals := make([]os.Signal, 0, len(a.handlers))
+		for sig := range a.handlers {
+			signals = append(signals, sig)
+		}
+
+		signal.Notify(a.handlerCh, signals...)
+		defer signal.Stop(a.handlerCh)
+
+		go a.handleSignals()
+	}
+
+	if a.argsFiles {
+		expanded, err := expandArgsFiles(args)
+		if err != nil {
+			return a.commandLine.exit(err)
+		}
+
+		args = expanded
+	}
+
 	cmd, flags, err := a.selectCommand(args)
 	if err != nil {
 		a.writeUsage(nil)
@@ -321,6 +792,12 @@ func (a *App[T]) RunE(args ...string) error {
 	}
 
 	a.setUsage(cmd)
+	a.wgMu.Lock()
+	a.flags = flags
+	a.commandLine.cmdConfig = cmd.config
+	a.commandLine.cmdEnvPrefix = a.name + "_" + strings.ReplaceAll(cmd.path, " ", "_")
+	a.wgMu.Unlock()
+
 	if err := a.commandLine.parse(a.Cfg, flags); err != nil {
 		return err
 	}
@@ -329,21 +806,119 @@ func (a *App[T]) RunE(args ...string) error {
 		return nil
 	}
 
+	for _, name := range a.commandLine.usedExperimental() {
+		a.Log.Warn("experimental option used", slog.String("flag", name),
+			slog.String("source", a.commandLine.sources[name]))
+	}
+
 	if err := cmd.handler(a.runtimeContext()); err != nil {
 		a.recordErr(err)
 		a.cancel()
 	} else if a.goroutineCount() == 0 {
 		a.cancel()
+	} else {
+		a.logStarted(start)
 	}
 
 	<-a.Ctx.Done()
+	a.runPreShutdown()
+	close(a.preDone)
 	a.wg.Wait()
 	a.runClosers()
 
 	return a.err()
 }
 
-// WithShutdownTimeout can be used to set the shutdown timeout.
+// logStarted waits until HTTP servers listen and registered health checks pass and logs that the
+// application started, with listen addresses, main module version from the build info and startup
+// duration. Nothing is logged if shutdown begins first.
+func (a *App[T]) logStarted(start time.Time) {
+	a.wgMu.Lock()
+	listening := slices.Clone(a.listening)
+	a.wgMu.Unlock()
+
+	addrs := make([]string, 0, len(listening))
+
+	for _, ch := range listening {
+		select {
+		case addr, ok := <-ch:
+			if ok {
+				addrs = append(addrs, addr)
+			}
+		case <-a.Ctx.Done():
+			return
+		}
+	}
+
+	if !a.waitReady() {
+		return
+	}
+
+	attrs := []any{}
+	if len(addrs) > 0 {
+		attrs = append(attrs, slog.Any("addrs", addrs))
+	}
+
+	if version, ok := a.commandLine.buildSetting("version"); ok {
+		attrs = append(attrs, slog.String("version", version))
+	}
+
+	attrs = append(attrs, slog.Duration("startup", time.Since(start)))
+
+	a.Log.Info("app started", attrs...)
+}
+
+// readyPollInterval is the interval between runs of health checks while waiting for readiness.
+const readyPollInterval = 100 * time.Millisecond
+
+// waitReady runs registered health checks until all of them pass and reports whether they passed
+// before shutdown began.
+func (a *App[T]) waitReady() bool {
+	ticker := time.NewTicker(readyPollInterval)
+	defer ticker.Stop()
+
+	for {
+		a.healthMu.RLock()
+		checks := slices.Clone(a.healthChecks)
+		a.healthMu.RUnlock()
+
+		ready := true
+
+		for _, result := range runHealthChecks(a.Ctx, checks) {
+			if result.Status != healthOK {
+				ready = false
+			}
+		}
+
+		if ready {
+			return a.Ctx.Err() == nil
+		}
+
+		select {
+		case <-a.Ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// flushLog flushes and closes the log handler, if it implements Flush or io.Closer, so buffered
+// records are not lost on exit. It runs only once, whether run returns or the process exits on
+// parse error. Errors are ignored, as there is nowhere left to log them.
+func (a *App[T]) flushLog() {
+	a.logFlushed.Do(func() {
+		if f, ok := a.logHandler.(interface{ Flush() error }); ok {
+			_ = f.Flush()
+		}
+
+		if c, ok := a.logHandler.(io.Closer); ok {
+			_ = c.Close()
+		}
+	})
+}
+
+// WithShutdownTimeout can be used to set the shutdown timeout. Zero timeout
+// means no deadline, so HTTP servers and closers run until they are done.
 func WithShutdownTimeout(d time.Duration) Option {
 	return func(o *appOptions) {
 		o.timeout = d
@@ -355,6 +930,44 @@ func WithShutdownGracePeriod(d time.Duration) Option {
 	return WithShutdownTimeout(d)
 }
 
+// WithPreShutdownTimeout can be used to set the deadline of each pre-shutdown
+// hook. By default pre-shutdown hooks have no deadline.
+func WithPreShutdownTimeout(d time.Duration) Option {
+	return func(o *appOptions) {
+		o.preTimeout = d
+	}
+}
+
+// WithSignals can be used to replace signals starting graceful shutdown, SIGINT and SIGTERM by
+// default, i.e. to add SIGQUIT. Passing no signals disables signal-based shutdown, so the
+// application stops only when its context, i.e. the one passed to RunContext, is cancelled.
+func WithSignals(sigs ...os.Signal) Option {
+	return func(o *appOptions) {
+		o.signals = slices.Clone(sigs)
+	}
+}
+
+// WithSignalHandler registers handler to be called whenever the application receives the signal.
+// Unlike shutdown signals, these signals don't terminate the application and handler errors are
+// only logged.
+func WithSignalHandler(sig os.Signal, fn func(ctx context.Context) error) Option {
+	return func(o *appOptions) {
+		if o.signalHandlers == nil {
+			o.signalHandlers = map[os.Signal]func(context.Context) error{}
+		}
+
+		o.signalHandlers[sig] = fn
+	}
+}
+
+// WithConfigDumpSignal can be used to log the config, with values of fields tagged with secret
+// redacted, whenever the application receives sig, i.e. syscall.SIGUSR1.
+func WithConfigDumpSignal(sig os.Signal) Option {
+	return func(o *appOptions) {
+		o.dumpSignal = sig
+	}
+}
+
 // WithDefaultCommand configures the command used when no command is supplied.
 func WithDefaultCommand(path string) Option {
 	return func(o *appOptions) {
@@ -378,6 +991,71 @@ func WithLogLevel(l string) Option {
 	}
 }
 
+// WithLogFormat can be used to set format of the default logger, "json" or human-readable "text",
+// i.e. for local development. Default format is JSON.
+func WithLogFormat(format string) Option {
+	return func(o *appOptions) {
+		o.logFormat = strings.ToLower(format)
+	}
+}
+
+// WithLogOutput can be used to set writer of the default logger instead of standard output, i.e.
+// to capture logs in tests.
+func WithLogOutput(w io.Writer) Option {
+	return func(o *appOptions) {
+		o.logOutput = w
+	}
+}
+
+// WithLogTee can be used to write records of the default logger to additional writers, i.e. a log
+// file for local debugging, besides standard output or writer set by WithLogOutput.
+func WithLogTee(w ...io.Writer) Option {
+	return func(o *appOptions) {
+		o.logTee = append(o.logTee, w...)
+	}
+}
+
+// WithLogAttrs can be used to add attributes, i.e. version, to every record of the application
+// logger, including injected one. The default logger already includes service attribute with the
+// application name.
+func WithLogAttrs(attrs ...slog.Attr) Option {
+	return func(o *appOptions) {
+		for _, attr := range attrs {
+			o.logAttrs = append(o.logAttrs, attr)
+		}
+	}
+}
+
+// WithLogTimeFormat can be used to format the time of log records using the given layout,
+// i.e. time.RFC3339Nano, instead of the default RFC3339 format.
+func WithLogTimeFormat(layout string) Option {
+	return func(o *appOptions) {
+		o.logTime = func(a slog.Attr) slog.Attr {
+			return slog.String(a.Key, a.Value.Time().Format(layout))
+		}
+	}
+}
+
+// WithLogTimeEpochMillis can be used to render the time of log records as milliseconds since
+// Unix epoch.
+func WithLogTimeEpochMillis() Option {
+	return func(o *appOptions) {
+		o.logTime = func(a slog.Attr) slog.Attr {
+			return slog.Int64(a.Key, a.Value.Time().UnixMilli())
+		}
+	}
+}
+
+// WithoutLogTime can be used to omit the time from log records, i.e. when the log collector
+// already adds timestamps.
+func WithoutLogTime() Option {
+	return func(o *appOptions) {
+		o.logTime = func(slog.Attr) slog.Attr {
+			return slog.Attr{}
+		}
+	}
+}
+
 // WithLogger can be used to inject an application logger.
 func WithLogger(log *slog.Logger) Option {
 	return func(o *appOptions) {
@@ -385,6 +1063,48 @@ func WithLogger(log *slog.Logger) Option {
 	}
 }
 
+// WithLogHandler can be used to inject an application log handler, i.e. one adding service
+// version or trace IDs, used verbatim instead of the default JSON handler, so log format, output,
+// level and time options don't apply to it.
+func WithLogHandler(h slog.Handler) Option {
+	return func(o *appOptions) {
+		o.log = slog.New(h)
+	}
+}
+
+// WithSetDefaultLogger can be used to set the application logger as the default slog logger,
+// so libraries using slog.Default log through the configured handler.
+func WithSetDefaultLogger() Option {
+	return func(o *appOptions) {
+		o.defaultLogger = true
+	}
+}
+
+// WithParallelShutdown runs registered closers concurrently instead of sequentially in reverse
+// order of registration. Closers registered with dependencies still wait for their dependents.
+func WithParallelShutdown() Option {
+	return func(o *appOptions) {
+		o.parallel = true
+	}
+}
+
+// WithFailOnListenError sets whether failure of HTTP server started by HTTPServer to listen starts
+// graceful shutdown of the application, which is the default. If fail is false, the error is only
+// logged and the application keeps running without the server.
+func WithFailOnListenError(fail bool) Option {
+	return func(o *appOptions) {
+		o.failOnListen = fail
+	}
+}
+
+// WithArgsFiles enables expansion of arguments of the form @file into arguments read from the
+// file, i.e. to overcome command line length limits.
+func WithArgsFiles() Option {
+	return func(o *appOptions) {
+		o.argsFiles = true
+	}
+}
+
 // WithErrorHandling is an option to change error handling similar to flag package.
 func WithErrorHandling(errorHandling flag.ErrorHandling) Option {
 	return func(o *appOptions) {
@@ -392,6 +1112,14 @@ func WithErrorHandling(errorHandling flag.ErrorHandling) Option {
 	}
 }
 
+// WithErrorFormat is an option to change how parse errors are written to the output writer
+// before exiting when ExitOnError error handling is used. Default format is "bee: <error>".
+func WithErrorFormat(fn func(w io.Writer, err error)) Option {
+	return func(o *appOptions) {
+		o.errorFormat = fn
+	}
+}
+
 // WithOutput is an option to change the output writer similar as flag.SetOutput does.
 func WithOutput(w io.Writer) Option {
 	return func(o *appOptions) {
@@ -406,6 +1134,64 @@ func WithLookupEnvFunc(fn func(string) (string, bool)) Option {
 	}
 }
 
+// WithSecretsDir may be used to read field values from files in a secrets directory. For each field,
+// a file named after its environment variable is read and its trimmed content is used if the environment
+// variable is not set. Values from command line flags and environment variables take precedence.
+func WithSecretsDir(path string) Option {
+	return func(o *appOptions) {
+		o.secretsDir = path
+	}
+}
+
+// WithConfigFile can be used to load config from JSON or YAML file as the base layer, overriding
+// default values, while environment variables and command line flags override the file. Files
+// with .yaml or .yml extension are decoded as YAML, all others as JSON. Unknown keys in the file
+// are rejected.
+func WithConfigFile(path string) Option {
+	return func(o *appOptions) {
+		o.configFile = path
+		o.configOptional = false
+	}
+}
+
+// WithOptionalConfigFile works like WithConfigFile, but missing file is skipped instead of
+// returning an error.
+func WithOptionalConfigFile(path string) Option {
+	return func(o *appOptions) {
+		o.configFile = path
+		o.configOptional = true
+	}
+}
+
+// WithConfigFileFlag can be used to select the config file at runtime using command line flag
+// flagName, i.e. "config", or environment variable envName, i.e. "MYCMD_CONFIG_FILE", which may be
+// empty. The path is resolved before the file is loaded and the rest of the flags are parsed. The
+// path set by WithConfigFile or WithOptionalConfigFile is used as the default, while the file
+// selected by the flag or environment variable must exist.
+func WithConfigFileFlag(flagName, envName string) Option {
+	return func(o *appOptions) {
+		o.configFlag = flagName
+		o.configEnv = envName
+	}
+}
+
+// WithStrictEnvValues makes parsing report all environment variables with malformed values at
+// once instead of failing on the first one, so the service refuses to start with an aggregated
+// error.
+func WithStrictEnvValues() Option {
+	return func(o *appOptions) {
+		o.strictEnv = true
+	}
+}
+
+// WithName can be used to set the name of the config parsed by ParseConfig, used in usage and as
+// the prefix of environment variables. New takes the name as its argument and ignores this option.
+func WithName(name string) Option {
+	return func(o *appOptions) {
+		o.name = name
+	}
+}
+
 // WithUsage allows to prefix your command name with a parent command name.
 func WithUsage(parentCmdName string) Option {
 	return func(o *appOptions) {
@@ -430,9 +1216,78 @@ func SlogError(err error) slog.Attr {
 	return slog.String("error", err.Error())
 }
 
+// SlogConfig creates slog group attribute named config with the config struct fields nested
+// in groups mirroring the struct nesting. Keys are snake cased field names and values of fields
+// tagged with secret are redacted.
+func SlogConfig(cfg any) slog.Attr {
+	v := reflect.ValueOf(cfg)
+	for v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return slog.Group("config")
+	}
+
+	return slog.Group("config", slogConfigAttrs(v)...)
+}
+
+const redacted = "[REDACTED]"
+
+func slogConfigAttrs(v reflect.Value) []any {
+	t := v.Type()
+	attrs := make([]any, 0, t.NumField())
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if ignoredField(field) {
+			continue
+		}
+
+		key := strcase.ToSnake(field.Name)
+		value := v.Field(i)
+		_, secret := field.Tag.Lookup("secret")
+
+		switch {
+		case secret:
+			attrs = append(attrs, slog.String(key, redacted))
+		case value.Kind() == reflect.Struct && !isSpecialStructType(value.Type()) && field.Anonymous:
+			attrs = append(attrs, slogConfigAttrs(value)...)
+		case value.Kind() == reflect.Struct && !isSpecialStructType(value.Type()):
+			attrs = append(attrs, slog.Group(key, slogConfigAttrs(value)...))
+		case value.Kind() == reflect.Pointer && value.IsNil():
+			attrs = append(attrs, slog.Any(key, nil))
+		case value.Kind() == reflect.Pointer:
+			attrs = append(attrs, slog.Any(key, value.Elem().Interface()))
+		case value.Type() == durationType:
+			attrs = append(attrs, slog.Duration(key, time.Duration(value.Int())))
+		default:
+			attrs = append(attrs, slog.Any(key, slogConfigValue(value)))
+		}
+	}
+
+	return attrs
+}
+
+func slogConfigValue(v reflect.Value) any {
+	switch v := v.Interface().(type) {
+	case URL:
+		return v.String()
+	case Time:
+		return v.String()
+	case net.IPNet:
+		return v.String()
+	default:
+		return v
+	}
+}
+
 type c struct {
-	name  string
-	inner func(ctx context.Context) error
+	name    string
+	deps    []string
+	timeout time.Duration
+	detach  bool
+	inner   func(ctx context.Context) error
 }
 
 func (a *App[T]) selectCommand(args []string) (*Cmd[T], []string, error) {
@@ -608,25 +1463,197 @@ func validateCommandName(name string) {
 }
 
 func (a *App[T]) runClosers() {
-	closers := slices.Clone(a.closers)
-	slices.Reverse(closers)
+	closers, err := shutdownOrder(a.closers)
+	if err != nil {
+		a.Log.Warn("shutdown order", SlogError(err))
+		a.recordErr(err)
+	}
+
 	if len(closers) > 0 {
 		a.Log.Info("graceful shutdown", slog.Duration("grace period", a.timeout))
 	}
 
+	if a.parallel {
+		a.runClosersParallel(closers, err == nil)
+
+		return
+	}
+
 	for _, f := range closers {
-		a.Log.Debug("closing " + f.name)
+		a.runCloser(f)
+	}
+}
+
+// runClosersParallel runs closers concurrently. If ordered, each closer waits for closers
+// depending on it to finish first.
+func (a *App[T]) runClosersParallel(closers []c, ordered bool) {
+	done := make([]chan struct{}, len(closers))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+
+	var wg sync.WaitGroup
+
+	for i, f := range closers {
+		var dependents []chan struct{}
+
+		if ordered {
+			for j, closer := range closers {
+				if slices.Contains(closer.deps, f.name) {
+					dependents = append(dependents, done[j])
+				}
+			}
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			defer close(done[i])
+
+			for _, d := range dependents {
+				<-d
+			}
 
-		ctx, cancel := context.WithTimeout(context.Background(), a.timeout)
+			a.runCloser(f)
+		}()
+	}
+
+	wg.Wait()
+}
+
+// runCloser runs closer bounded by its timeout and logs its error.
+func (a *App[T]) runCloser(f c) {
+	a.Log.Debug("closing " + f.name)
+
+	ctx, cancel := timeoutContext(f.timeout)
+
+	detached, err := callCloser(ctx, f)
+	if detached {
+		cancel()
+		a.Log.Warn("closer "+f.name+" detached", slog.Duration("timeout", f.timeout))
+
+		return
+	}
+
+	deadlineExceeded := errors.Is(ctx.Err(), context.DeadlineExceeded)
+	cancel()
+
+	switch {
+	case err != nil && deadlineExceeded:
+		a.Log.Warn("closer "+f.name+" timed out", slog.Duration("timeout", f.timeout), SlogError(err))
+		a.recordErr(err)
+	case err != nil:
+		a.Log.Warn("closer "+f.name, SlogError(err))
+		a.recordErr(err)
+	}
+}
+
+// callCloser calls closer and returns its error. Non-critical closer which doesn't return until ctx
+// is done is left running and reported as detached.
+func callCloser(ctx context.Context, f c) (bool, error) {
+	if !f.detach {
+		return false, f.inner(ctx)
+	}
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- f.inner(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return false, err
+	case <-ctx.Done():
+	}
+
+	select {
+	case err := <-done:
+		return false, err
+	default:
+		return true, nil
+	}
+}
+
+// shutdownOrder returns closers in reverse order of registration with dependents moved before
+// their dependencies. On unknown dependency or dependency cycle it returns closers in reverse
+// order of registration together with the error.
+func shutdownOrder(closers []c) ([]c, error) {
+	reversed := slices.Clone(closers)
+	slices.Reverse(reversed)
+
+	// dependents counts not yet closed dependents of each closer name
+	dependents := map[string]int{}
+	for _, closer := range closers {
+		dependents[closer.name] = 0
+	}
+
+	for _, closer := range closers {
+		for _, dep := range closer.deps {
+			if _, ok := dependents[dep]; !ok {
+				return reversed, fmt.Errorf("closer %s depends on unknown closer %s", closer.name, dep)
+			}
+
+			dependents[dep]++
+		}
+	}
+
+	ordered := make([]c, 0, len(closers))
+	remaining := reversed
+
+	for len(remaining) > 0 {
+		i := slices.IndexFunc(remaining, func(closer c) bool {
+			return dependents[closer.name] == 0
+		})
+		if i < 0 {
+			names := make([]string, 0, len(remaining))
+			for _, closer := range remaining {
+				names = append(names, closer.name)
+			}
+
+			return reversed, fmt.Errorf("closer dependency cycle between %s", strings.Join(names, ", "))
+		}
+
+		closer := remaining[i]
+		remaining = slices.Delete(slices.Clone(remaining), i, i+1)
+		ordered = append(ordered, closer)
+
+		for _, dep := range closer.deps {
+			dependents[dep]--
+		}
+	}
+
+	return ordered, nil
+}
+
+func (a *App[T]) runPreShutdown() {
+	hooks := slices.Clone(a.preClosers)
+	slices.Reverse(hooks)
+
+	for _, f := range hooks {
+		a.Log.Debug("pre-shutdown " + f.name)
+
+		ctx, cancel := timeoutContext(a.preTimeout)
 		err := f.inner(ctx)
 		cancel()
 		if err != nil {
-			a.Log.Warn("closer "+f.name, SlogError(err))
+			a.Log.Warn("pre-shutdown "+f.name, SlogError(err))
 			a.recordErr(err)
 		}
 	}
 }
 
+// timeoutContext returns context with timeout or context without deadline if
+// timeout is not positive.
+func timeoutContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(context.Background(), timeout)
+	}
+
+	return context.WithCancel(context.Background())
+}
+
 func (a *App[T]) recordErr(err error) {
 	if err == nil {
 		return
@@ -672,6 +1699,8 @@ func commandTokens(args []string) []string {
 func hasHelp(args []string) bool {
 	for _, arg := range args {
 		switch arg {
+		case "--":
+			return false
 		case "--help", "-help", "--h", "-h":
 			return true
 		}
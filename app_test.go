@@ -3,6 +3,7 @@ package bee
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
@@ -10,8 +11,15 @@ import (
 	"log/slog"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"reflect"
+	"runtime/debug"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 )
@@ -104,6 +112,56 @@ func TestNewRejectsNilConfig(t *testing.T) {
 	New("maia", (*appTestConfig)(nil))
 }
 
+func TestParseConfig(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := ParseConfig[appTestConfig]([]string{"--port", "9091"},
+		WithName("maia"),
+		WithOutput(io.Discard),
+		WithLookupEnvFunc(func(name string) (string, bool) {
+			return "0.0.0.0", name == "MAIA_HTTP_HOST"
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &appTestConfig{LogLevel: "INFO", Port: 9091}
+	want.HTTP.Host = "0.0.0.0"
+
+	if !reflect.DeepEqual(cfg, want) {
+		t.Fatalf("want config %+v, got %+v", want, cfg)
+	}
+}
+
+func TestParseConfigReturnsError(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := ParseConfig[appTestConfig]([]string{"--port", "http"}, WithOutput(io.Discard))
+
+	want := `invalid value "http" for flag -port: parse error`
+	if err == nil || err.Error() != want {
+		t.Fatalf("want error %q, got %v", want, err)
+	}
+
+	if cfg != nil {
+		t.Fatalf("want nil config on error, got %+v", cfg)
+	}
+}
+
+func TestParseConfigDefaultName(t *testing.T) {
+	t.Parallel()
+
+	var output bytes.Buffer
+	if _, err := ParseConfig[appTestConfig]([]string{"--help"}, WithOutput(&output)); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "Usage of " + filepath.Base(os.Args[0]); !strings.Contains(output.String(), want) {
+		t.Fatalf("want usage containing %q, got %s", want, output.String())
+	}
+}
+
 func TestNewExposesRuntimeFields(t *testing.T) {
 	t.Parallel()
 
@@ -122,6 +180,69 @@ func TestNewExposesRuntimeFields(t *testing.T) {
 	}
 }
 
+func TestNewLifecycleRunsWithoutConfig(t *testing.T) {
+	t.Parallel()
+
+	app := NewLifecycle("maia",
+		WithOutput(io.Discard),
+		WithErrorHandling(flag.ContinueOnError),
+		WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+	)
+
+	var calls []string
+	app.Root("Run app", func(ctx *Ctx[struct{}]) error {
+		ctx.Register("resource", func(context.Context) error {
+			calls = append(calls, "resource closed")
+
+			return nil
+		})
+		ctx.Go("worker", func(run context.Context) error {
+			<-run.Done()
+			calls = append(calls, "worker stopped")
+
+			return nil
+		})
+		ctx.Exit("done", nil)
+
+		return nil
+	})
+
+	err := app.RunE()
+	if err == nil || err.Error() != "done" {
+		t.Fatalf("want exit error %q, got %v", "done", err)
+	}
+
+	want := []string{"worker stopped", "resource closed"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("want calls %v, got %v", want, calls)
+	}
+}
+
+func TestNewWithSetDefaultLogger(t *testing.T) {
+	defaultLogger := slog.Default()
+	t.Cleanup(func() {
+		slog.SetDefault(defaultLogger)
+	})
+
+	var logs bytes.Buffer
+	cfg := appTestConfig{}
+	New("maia", &cfg,
+		WithLogger(slog.New(slog.NewJSONHandler(&logs, nil))),
+		WithSetDefaultLogger(),
+	)
+
+	slog.Info("library log")
+
+	var entry map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatalf("want default logger to use app handler, got %q: %v", logs.String(), err)
+	}
+
+	if entry["msg"] != "library log" {
+		t.Fatalf("want library log message, got %v", entry["msg"])
+	}
+}
+
 func TestContextRuntimeMethodPanicsWithoutApp(t *testing.T) {
 	t.Parallel()
 
@@ -215,6 +336,346 @@ func TestAppCommandParsingAndConfig(t *testing.T) {
 	}
 }
 
+func TestAppWithArgsFiles(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "args")
+	content := "# service arguments\nstart\n--port 7070\n\n--http-host '0.0.0.0'\n"
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := appTestConfig{}
+	output := &bytes.Buffer{}
+	app := newTestApp(t, cfg, output, WithArgsFiles())
+
+	var ran bool
+	app.Cmd("start", "Run starter", func(*Ctx[appTestConfig]) error {
+		ran = true
+
+		return nil
+	})
+
+	if err := app.RunE("@"+path, "--log-level", "WARN"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !ran {
+		t.Fatal("want start command from args file to run")
+	}
+
+	if app.Cfg.Port != 7070 || app.Cfg.HTTP.Host != "0.0.0.0" || app.Cfg.LogLevel != "WARN" {
+		t.Fatalf("want config from args file and command line, got %+v", *app.Cfg)
+	}
+}
+
+func TestAppWithArgsFilesMissingFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "missing")
+	app := newTestApp(t, appTestConfig{}, &bytes.Buffer{}, WithArgsFiles())
+	app.Root("Run root", func(*Ctx[appTestConfig]) error {
+		return nil
+	})
+
+	err := app.RunE("@" + path)
+	if err == nil || !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("want missing args file error, got %v", err)
+	}
+
+	if want := "args file " + path + ": reading: open " + path + ": no such file or directory"; err.Error() != want {
+		t.Fatalf("want error %q, got %q", want, err.Error())
+	}
+}
+
+func TestAppWithStrictEnvValuesReportsAllMalformedValues(t *testing.T) {
+	t.Parallel()
+
+	type config struct {
+		Port    int
+		Timeout time.Duration
+		Name    string
+	}
+
+	app := New("maia", &config{},
+		WithOutput(io.Discard),
+		WithErrorHandling(flag.ContinueOnError),
+		WithStrictEnvValues(),
+		WithLookupEnvFunc(func(key string) (string, bool) {
+			switch key {
+			case "MAIA_PORT":
+				return "http", true
+			case "MAIA_TIMEOUT":
+				return "soon", true
+			case "MAIA_NAME":
+				return "maia", true
+			default:
+				return "", false
+			}
+		}),
+	)
+	app.Root("Run root", func(*Ctx[config]) error {
+		t.Fatal("root must not run with malformed env values")
+
+		return nil
+	})
+
+	err := app.RunE()
+
+	want := `Port env: parsing int "http": strconv.ParseInt: parsing "http": invalid syntax` + "\n" +
+		`Timeout env: parsing duration "soon": time: invalid duration "soon"`
+	if err == nil || err.Error() != want {
+		t.Fatalf("want error %q, got %v", want, err)
+	}
+}
+
+func TestAppDumpConfig(t *testing.T) {
+	t.Parallel()
+
+	type config struct {
+		Port     int    `def:"8080"`
+		Host     string `def:"localhost"`
+		Password string `secret:"true"`
+		Mongo    struct {
+			Database string `def:"maia"`
+		}
+		Auth struct {
+			Token string `def:"t0k3n"`
+		} `secret:"true"`
+	}
+
+	app := New("maia", &config{},
+		WithOutput(io.Discard),
+		WithErrorHandling(flag.ContinueOnError),
+		WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		WithLookupEnvFunc(func(key string) (string, bool) {
+			switch key {
+			case "MAIA_HOST":
+				return "db", true
+			case "MAIA_PASSWORD":
+				return "s3cr3t", true
+			default:
+				return "", false
+			}
+		}),
+	)
+
+	var dump bytes.Buffer
+	app.Root("Run root", func(ctx *Ctx[config]) error {
+		ctx.DumpConfig(&dump)
+
+		return nil
+	})
+
+	if err := app.RunE("-port", "9090"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "auth-token=**** (default)\nhost=db (env)\nmongo-database=maia (default)\npassword=**** (env)\n" +
+		"port=9090 (flag)\n"
+	if got := dump.String(); got != want {
+		t.Fatalf("want dump %q, got %q", want, got)
+	}
+}
+
+func TestAppWarnsAboutUsedExperimentalOptions(t *testing.T) {
+	t.Parallel()
+
+	type config struct {
+		FastPath bool `experimental:"true"`
+		Workers  int  `def:"4" experimental:"true"`
+		Cache    bool `experimental:"true"`
+		Port     int  `def:"8080"`
+	}
+
+	var logs bytes.Buffer
+	app := New("maia", &config{},
+		WithOutput(io.Discard),
+		WithErrorHandling(flag.ContinueOnError),
+		WithLogger(slog.New(slog.NewJSONHandler(&logs, nil))),
+		WithLookupEnvFunc(func(key string) (string, bool) {
+			return "true", key == "MAIA_CACHE"
+		}),
+	)
+	app.Root("Run root", func(*Ctx[config]) error {
+		return nil
+	})
+
+	if err := app.RunE("-fast-path", "-port", "9090"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `"level":"WARN","msg":"experimental option used","flag":"cache","source":"env"}` + "\n" +
+		`{"time":`
+	if !strings.Contains(logs.String(), want) {
+		t.Fatalf("want warning for cache, got %s", logs.String())
+	}
+
+	if !strings.Contains(logs.String(), `"msg":"experimental option used","flag":"fast-path","source":"flag"}`) {
+		t.Fatalf("want warning for fast-path, got %s", logs.String())
+	}
+
+	if strings.Contains(logs.String(), `"flag":"workers"`) || strings.Contains(logs.String(), `"flag":"port"`) {
+		t.Fatalf("want no warning for defaults and stable options, got %s", logs.String())
+	}
+}
+
+type flushingHandler struct {
+	slog.Handler
+	calls *[]string
+}
+
+func (h flushingHandler) Flush() error {
+	*h.calls = append(*h.calls, "flush")
+
+	return nil
+}
+
+func (h flushingHandler) Close() error {
+	*h.calls = append(*h.calls, "close")
+
+	return errors.New("ignored")
+}
+
+func TestAppFlushesLogHandlerBeforeReturning(t *testing.T) {
+	t.Parallel()
+
+	var calls []string
+	handler := flushingHandler{Handler: slog.NewTextHandler(io.Discard, nil), calls: &calls}
+	app := newTestApp(t, appTestConfig{}, &bytes.Buffer{}, WithLogger(slog.New(handler)))
+
+	app.Root("Run app", func(ctx *Ctx[appTestConfig]) error {
+		ctx.Register("database", func(context.Context) error {
+			calls = append(calls, "closer")
+
+			return nil
+		})
+
+		return errors.New("boom")
+	})
+
+	if err := app.RunE(); err == nil {
+		t.Fatal("want handler error")
+	}
+
+	if want := []string{"closer", "flush", "close"}; !reflect.DeepEqual(calls, want) {
+		t.Fatalf("want calls %v, got %v", want, calls)
+	}
+}
+
+func TestAppFlushesLogHandlerWithAttrs(t *testing.T) {
+	t.Parallel()
+
+	var calls []string
+	handler := flushingHandler{Handler: slog.NewTextHandler(io.Discard, nil), calls: &calls}
+	app := newTestApp(t, appTestConfig{}, &bytes.Buffer{},
+		WithLogHandler(handler), WithLogAttrs(slog.String("version", "v1.2.3")))
+
+	app.Root("Run app", func(*Ctx[appTestConfig]) error { return nil })
+
+	if err := app.RunE(); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"flush", "close"}; !reflect.DeepEqual(calls, want) {
+		t.Fatalf("want calls %v, got %v", want, calls)
+	}
+}
+
+func TestAppFlagSpecJSON(t *testing.T) {
+	t.Parallel()
+
+	type config struct {
+		Port     int    `def:"8080" help:"listen port" short:"p"`
+		Password string `def:"hunter2" secret:"true"`
+		Mongo    struct {
+			Hosts StringSlice `def:"a,b"`
+		}
+	}
+
+	app := New("maia", &config{},
+		WithOutput(io.Discard),
+		WithLookupEnvFunc(func(string) (string, bool) {
+			return "9090", true
+		}),
+	)
+
+	got, err := app.FlagSpecJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `[` +
+		`{"name":"mongo-hosts","type":"bee.StringSlice","default":"['a','b']","env":"MAIA_MONGO_HOSTS","help":"mongo hosts"},` +
+		`{"name":"password","type":"string","default":"","env":"MAIA_PASSWORD","help":"password"},` +
+		`{"name":"port","type":"int","default":"8080","env":"MAIA_PORT","help":"listen port","short":"p"}` +
+		`]`
+	if string(got) != want {
+		t.Fatalf("want spec %s, got %s", want, got)
+	}
+}
+
+func TestAppFlagSpecJSONError(t *testing.T) {
+	t.Parallel()
+
+	app := New("maia", &struct {
+		Port int `def:"a"`
+	}{}, WithOutput(io.Discard))
+
+	want := `flag spec: Port def: parsing int "a": strconv.ParseInt: parsing "a": invalid syntax`
+	if _, err := app.FlagSpecJSON(); err == nil || err.Error() != want {
+		t.Fatalf("want error %q, got %v", want, err)
+	}
+}
+
+func TestAppWriteEnvTemplate(t *testing.T) {
+	t.Parallel()
+
+	type config struct {
+		Port     int    `def:"8080" help:"listen port"`
+		Password string `def:"hunter2" secret:"true"`
+		Greeting string `def:"hello world"`
+		Mongo    struct {
+			Hosts StringSlice `def:"a,b"`
+		}
+	}
+
+	app := New("maia", &config{},
+		WithOutput(io.Discard),
+		WithLookupEnvFunc(func(string) (string, bool) {
+			return "9090", true
+		}),
+	)
+
+	var buf bytes.Buffer
+	if err := app.WriteEnvTemplate(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `MAIA_GREETING="hello world" # greeting
+MAIA_MONGO_HOSTS=a,b # mongo hosts
+MAIA_PASSWORD= # password (secret)
+MAIA_PORT=8080 # listen port
+`
+	if got := buf.String(); got != want {
+		t.Fatalf("want env template:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestAppWriteEnvTemplateError(t *testing.T) {
+	t.Parallel()
+
+	app := New("maia", &struct {
+		Port int `def:"a"`
+	}{}, WithOutput(io.Discard))
+
+	want := `env template: Port def: parsing int "a": strconv.ParseInt: parsing "a": invalid syntax`
+	if err := app.WriteEnvTemplate(io.Discard); err == nil || err.Error() != want {
+		t.Fatalf("want error %q, got %v", want, err)
+	}
+}
+
 func TestAppDefaultCommand(t *testing.T) {
 	t.Parallel()
 
@@ -470,32 +931,225 @@ func TestAppHTTPServerShutsDownWhenContextIsCancelled(t *testing.T) {
 	}
 }
 
-func TestAppHTTPServerDrainsBeforeRegisteredClosers(t *testing.T) {
+func TestAppHTTPServerListenError(t *testing.T) {
 	t.Parallel()
 
-	output := &bytes.Buffer{}
-	app := newTestApp(t, appTestConfig{}, output)
-	app.timeout = time.Second
+	tests := map[string]struct {
+		fail    bool
+		wantErr bool
+	}{
+		"fail": {
+			fail:    true,
+			wantErr: true,
+		},
+		"log": {
+			fail: false,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
 
-	probe, err := net.Listen("tcp", "127.0.0.1:0")
-	if err != nil {
-		t.Fatal(err)
-	}
-	addr := probe.Addr().String()
-	if err := probe.Close(); err != nil {
-		t.Fatal(err)
-	}
+			busy, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer busy.Close()
 
-	requestStarted := make(chan struct{})
-	allowRequestFinish := make(chan struct{})
-	events := make(chan string, 4)
-	clientDone := make(chan error, 1)
-	exitCalled := make(chan struct{})
+			logs := make(notifyWriter, 100)
+			app := newTestApp(t, appTestConfig{}, &bytes.Buffer{},
+				WithLogger(slog.New(slog.NewJSONHandler(logs, nil))),
+				WithFailOnListenError(tt.fail),
+			)
 
-	server := &http.Server{
-		Addr: addr,
-		Handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
-			close(requestStarted)
+			app.Root("Run app", func(ctx *Ctx[appTestConfig]) error {
+				ctx.HTTPServer("http api", &http.Server{Addr: busy.Addr().String()}) //nolint:gosec,exhaustruct
+				ctx.Go("worker", func(run context.Context) error {
+					<-run.Done()
+
+					return nil
+				})
+
+				return nil
+			})
+
+			errCh := make(chan error, 1)
+			go func() { errCh <- app.RunE() }()
+
+			if !tt.wantErr {
+				for line := range logs {
+					if strings.Contains(line, `"msg":"http server listen"`) {
+						break
+					}
+				}
+
+				select {
+				case err := <-errCh:
+					t.Fatalf("want app running after listen error, got %v", err)
+				case <-time.After(50 * time.Millisecond):
+				}
+
+				app.cancel()
+			}
+
+			err = <-errCh
+			if tt.wantErr && (err == nil || !strings.Contains(err.Error(), "address already in use")) {
+				t.Fatalf("want listen error, got %v", err)
+			}
+
+			if !tt.wantErr && err != nil {
+				t.Fatalf("want nil error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestAppLogsStarted(t *testing.T) {
+	t.Parallel()
+
+	logs := make(notifyWriter, 100)
+	app := newTestApp(t, appTestConfig{}, &bytes.Buffer{}, WithLogger(slog.New(slog.NewJSONHandler(logs, nil))))
+	app.commandLine.buildInfoFunc = func() (*debug.BuildInfo, bool) {
+		return &debug.BuildInfo{Main: debug.Module{Version: "v1.2.3"}}, true //nolint:exhaustruct
+	}
+
+	app.Root("Run app", func(ctx *Ctx[appTestConfig]) error {
+		ctx.HTTPServer("http api", &http.Server{Addr: "127.0.0.1:0"}) //nolint:gosec,exhaustruct
+
+		return nil
+	})
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- app.RunE() }()
+
+	var started struct {
+		Msg     string   `json:"msg"`
+		Addrs   []string `json:"addrs"`
+		Version string   `json:"version"`
+		Startup int64    `json:"startup"`
+	}
+
+	for started.Msg != "app started" {
+		if err := json.Unmarshal([]byte(receiveString(t, logs, time.Second, "started log")), &started); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	app.cancel()
+
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+
+	if len(started.Addrs) != 1 || !strings.HasPrefix(started.Addrs[0], "127.0.0.1:") || started.Addrs[0] == "127.0.0.1:0" {
+		t.Errorf("want listen address, got %v", started.Addrs)
+	}
+
+	if want := "v1.2.3"; started.Version != want {
+		t.Errorf("want version %q, got %q", want, started.Version)
+	}
+
+	if started.Startup <= 0 {
+		t.Errorf("want startup duration, got %d", started.Startup)
+	}
+}
+
+func TestAppLogsStartedOnceReady(t *testing.T) {
+	t.Parallel()
+
+	logs := make(notifyWriter, 100)
+	app := newTestApp(t, appTestConfig{}, &bytes.Buffer{}, WithLogger(slog.New(slog.NewJSONHandler(logs, nil))))
+
+	var ready atomic.Bool
+	checked := make(chan struct{}, 100)
+
+	app.Root("Run app", func(ctx *Ctx[appTestConfig]) error {
+		ctx.RegisterHealthCheck("db", func(context.Context) error {
+			checked <- struct{}{}
+			if !ready.Load() {
+				return errors.New("not ready")
+			}
+
+			return nil
+		})
+		ctx.HTTPServer("http api", &http.Server{Addr: "127.0.0.1:0"}) //nolint:gosec,exhaustruct
+
+		return nil
+	})
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- app.RunE() }()
+
+	for range 2 {
+		select {
+		case <-checked:
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for health check")
+		}
+	}
+
+	for len(logs) > 0 {
+		if got := <-logs; strings.Contains(got, "app started") {
+			t.Fatalf("want no started log while health check fails, got %s", got)
+		}
+	}
+
+	ready.Store(true)
+
+	for got := ""; !strings.Contains(got, "app started"); {
+		got = receiveString(t, logs, time.Second, "started log")
+	}
+
+	app.cancel()
+
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAppDoesNotLogStartedForCommands(t *testing.T) {
+	t.Parallel()
+
+	var logs bytes.Buffer
+	app := newTestApp(t, appTestConfig{}, &bytes.Buffer{}, WithLogger(slog.New(slog.NewJSONHandler(&logs, nil))))
+	app.Root("Run app", func(*Ctx[appTestConfig]) error { return nil })
+
+	if err := app.RunE(); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(logs.String(), "app started") {
+		t.Fatalf("want no started log for command without goroutines, got %s", logs.String())
+	}
+}
+
+func TestAppHTTPServerDrainsBeforeRegisteredClosers(t *testing.T) {
+	t.Parallel()
+
+	output := &bytes.Buffer{}
+	app := newTestApp(t, appTestConfig{}, output)
+	app.timeout = time.Second
+
+	probe, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := probe.Addr().String()
+	if err := probe.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	requestStarted := make(chan struct{})
+	allowRequestFinish := make(chan struct{})
+	events := make(chan string, 4)
+	clientDone := make(chan error, 1)
+	exitCalled := make(chan struct{})
+
+	server := &http.Server{
+		Addr: addr,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			close(requestStarted)
 			<-allowRequestFinish
 			events <- "handler finished"
 			w.WriteHeader(http.StatusNoContent)
@@ -712,6 +1366,131 @@ func TestAppCommandHelpListsSelectedCommandAndFlags(t *testing.T) {
 	}
 }
 
+func TestAppCommandConfig(t *testing.T) {
+	t.Parallel()
+
+	type migrateConfig struct {
+		DSN   string `flag:"dsn" required:""`
+		Steps int    `def:"1"`
+	}
+
+	type workerConfig struct {
+		Queue string `def:"default"`
+	}
+
+	tests := map[string]struct {
+		args        []string
+		env         map[string]string
+		wantMigrate migrateConfig
+		wantWorker  workerConfig
+		wantPort    int
+		wantErr     string
+		wantOutput  []string
+	}{
+		"migrate": {
+			args:        []string{"migrate", "--dsn", "postgres://db", "--port", "9090"},
+			wantMigrate: migrateConfig{DSN: "postgres://db", Steps: 1},
+			wantPort:    9090,
+		},
+		"migrate-env": {
+			args:        []string{"migrate"},
+			env:         map[string]string{"MAIA_MIGRATE_DSN": "postgres://env", "MAIA_MIGRATE_STEPS": "3"},
+			wantMigrate: migrateConfig{DSN: "postgres://env", Steps: 3},
+			wantPort:    8080,
+		},
+		"migrate-missing-required": {
+			args:    []string{"migrate"},
+			wantErr: "DSN required: required value missing",
+		},
+		"nested-worker": {
+			args:       []string{"start", "worker", "--queue", "emails"},
+			wantWorker: workerConfig{Queue: "emails"},
+			wantPort:   8080,
+		},
+		"other-command-flag": {
+			args:    []string{"start", "worker", "--dsn", "postgres://db"},
+			wantErr: "flag provided but not defined: -dsn",
+		},
+		"help": {
+			args:        []string{"migrate", "--help"},
+			wantMigrate: migrateConfig{DSN: "", Steps: 1},
+			wantOutput:  []string{"Usage of maia migrate:", "-dsn string", "MAIA_MIGRATE_DSN", "-steps int", "-port int"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			output := &bytes.Buffer{}
+			app := newTestApp(t, appTestConfig{}, output, WithLookupEnvFunc(func(name string) (string, bool) {
+				value, ok := tt.env[name]
+
+				return value, ok
+			}))
+
+			var migrate migrateConfig
+			var worker workerConfig
+			var port int
+
+			app.Cmd("migrate", "Run migrations", func(ctx *Ctx[appTestConfig]) error {
+				port = ctx.Cfg.Port
+
+				return nil
+			}).Config(&migrate)
+			app.Cmd("start", "Start services").Cmd("worker", "Run worker", func(ctx *Ctx[appTestConfig]) error {
+				port = ctx.Cfg.Port
+
+				return nil
+			}).Config(&worker)
+
+			err := app.RunE(tt.args...)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("want error containing %q, got %v", tt.wantErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if migrate != tt.wantMigrate {
+				t.Errorf("want migrate config %+v, got %+v", tt.wantMigrate, migrate)
+			}
+			if worker != tt.wantWorker {
+				t.Errorf("want worker config %+v, got %+v", tt.wantWorker, worker)
+			}
+			if port != tt.wantPort {
+				t.Errorf("want port %d, got %d", tt.wantPort, port)
+			}
+
+			for _, want := range tt.wantOutput {
+				if !strings.Contains(output.String(), want) {
+					t.Errorf("want output to contain %q, got:\n%s", want, output.String())
+				}
+			}
+		})
+	}
+}
+
+func TestAppCommandConfigPanicsOnInvalidConfig(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApp(t, appTestConfig{}, &bytes.Buffer{})
+	cmd := app.Cmd("migrate", "Run migrations", func(*Ctx[appTestConfig]) error { return nil })
+
+	defer func() {
+		if got, want := recover(), `bee: invalid config of command "migrate"`; got != want {
+			t.Fatalf("want panic %q, got %v", want, got)
+		}
+	}()
+
+	cmd.Config(struct{}{})
+}
+
 func TestAppRootCommand(t *testing.T) {
 	t.Parallel()
 
@@ -739,6 +1518,87 @@ func TestAppRootCommand(t *testing.T) {
 	}
 }
 
+func TestAppArgs(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		args     []string
+		cmd      bool
+		wantPort int
+		wantHost string
+		wantArgs []string
+	}{
+		"no-args": {
+			args:     []string{"--port", "9091"},
+			wantPort: 9091,
+			wantHost: "10.0.0.1",
+			wantArgs: []string{},
+		},
+		"positional": {
+			args:     []string{"--port", "9091", "a.txt", "b.txt"},
+			wantPort: 9091,
+			wantHost: "10.0.0.1",
+			wantArgs: []string{"a.txt", "b.txt"},
+		},
+		"terminator": {
+			args:     []string{"--port", "9091", "--", "--port", "9092", "-h", "--help"},
+			wantPort: 9091,
+			wantHost: "10.0.0.1",
+			wantArgs: []string{"--port", "9092", "-h", "--help"},
+		},
+		"terminator-only": {
+			args:     []string{"--", "-http-host=evil"},
+			wantPort: 8080,
+			wantHost: "10.0.0.1",
+			wantArgs: []string{"-http-host=evil"},
+		},
+		"command": {
+			args:     []string{"start", "--port", "9091", "--", "-v"},
+			cmd:      true,
+			wantPort: 9091,
+			wantHost: "10.0.0.1",
+			wantArgs: []string{"-v"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			app := newTestApp(t, appTestConfig{}, &bytes.Buffer{}, WithLookupEnvFunc(func(env string) (string, bool) {
+				return "10.0.0.1", env == "MAIA_HTTP_HOST"
+			}))
+
+			var got []string
+			handler := func(ctx *Ctx[appTestConfig]) error {
+				got = ctx.Args()
+
+				return nil
+			}
+
+			if tt.cmd {
+				app.Cmd("start", "Start app", handler)
+			} else {
+				app.Root("Run app", handler)
+			}
+
+			if err := app.RunE(tt.args...); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, tt.wantArgs) {
+				t.Errorf("want args %q, got %q", tt.wantArgs, got)
+			}
+			if app.Cfg.Port != tt.wantPort {
+				t.Errorf("want port %d, got %d", tt.wantPort, app.Cfg.Port)
+			}
+			if app.Cfg.HTTP.Host != tt.wantHost {
+				t.Errorf("want host %q, got %q", tt.wantHost, app.Cfg.HTTP.Host)
+			}
+		})
+	}
+}
+
 func TestAppRunEReturnsConfigParseError(t *testing.T) {
 	t.Parallel()
 
@@ -754,6 +1614,37 @@ func TestAppRunEReturnsConfigParseError(t *testing.T) {
 	}
 }
 
+func TestAppWithErrorFormatWritesCustomParseError(t *testing.T) {
+	output := &bytes.Buffer{}
+	app := newTestApp(t, appTestConfig{}, output,
+		WithErrorHandling(flag.ExitOnError),
+		WithErrorFormat(func(w io.Writer, err error) {
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		}),
+	)
+	app.Root("Run app", func(*Ctx[appTestConfig]) error {
+		return nil
+	})
+
+	code := captureExit(t, func() {
+		_ = app.RunE("--port", "a")
+	})
+	if code != exitCode {
+		t.Fatalf("want exit code %d, got %d", exitCode, code)
+	}
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+
+	var got map[string]string
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &got); err != nil {
+		t.Fatalf("want JSON error output, got %q: %v", output.String(), err)
+	}
+
+	if want := `invalid value "a" for flag -port: parse error`; got["error"] != want {
+		t.Fatalf("want error %q, got %q", want, got["error"])
+	}
+}
+
 func TestAppContextCancelledOnSignal(t *testing.T) {
 	t.Parallel()
 
@@ -787,122 +1678,1188 @@ func TestAppContextCancelledOnSignal(t *testing.T) {
 	}
 }
 
-func TestAppGoReceivesAppContextAndFailsFast(t *testing.T) {
-	t.Parallel()
-
-	app := newTestApp(t, appTestConfig{}, &bytes.Buffer{})
-	boom := errors.New("boom")
-	gotContext := make(chan bool, 1)
+func TestAppWithSignals(t *testing.T) { //nolint:paralleltest
+	app := newTestApp(t, appTestConfig{}, &bytes.Buffer{}, WithSignals(syscall.SIGQUIT))
+	entered := make(chan struct{})
 	app.Root("Run app", func(ctx *Ctx[appTestConfig]) error {
 		ctx.Go("worker", func(run context.Context) error {
-			gotContext <- run == ctx.Ctx
+			<-run.Done()
 
-			return boom
+			return nil
 		})
-
-		<-ctx.Ctx.Done()
+		close(entered)
 
 		return nil
 	})
 
-	err := app.RunE()
-	if err == nil {
-		t.Fatal("want goroutine error")
-	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- app.RunE()
+	}()
 
-	if !errors.Is(err, boom) {
-		t.Fatalf("want goroutine error %v, got %v", boom, err)
+	<-entered
+
+	process, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	if !<-gotContext {
-		t.Fatal("Go did not receive app context")
+	if err := process.Signal(syscall.SIGQUIT); err != nil {
+		t.Fatal(err)
 	}
 
-	if app.Ctx.Err() == nil {
-		t.Fatal("want app context cancelled after goroutine failure")
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("app didn't shut down on configured signal")
 	}
 }
 
-func TestAppGoExitsCleanlyOnContextCancellation(t *testing.T) {
+func TestAppWithoutSignals(t *testing.T) {
 	t.Parallel()
 
-	app := newTestApp(t, appTestConfig{}, &bytes.Buffer{})
-	stopped := make(chan struct{})
+	app := newTestApp(t, appTestConfig{}, &bytes.Buffer{}, WithSignals())
+	if len(app.signals) != 0 {
+		t.Fatalf("want no shutdown signals, got %v", app.signals)
+	}
+
+	parent, cancel := context.WithCancel(context.Background())
 	app.Root("Run app", func(ctx *Ctx[appTestConfig]) error {
 		ctx.Go("worker", func(run context.Context) error {
 			<-run.Done()
-			close(stopped)
 
 			return nil
 		})
-
-		app.cancel()
+		cancel()
 
 		return nil
 	})
 
-	if err := app.RunE(); err != nil {
+	if err := app.RunContext(parent); err != nil {
 		t.Fatal(err)
 	}
-
-	select {
-	case <-stopped:
-	case <-time.After(time.Second):
-		t.Fatal("worker did not stop after context cancellation")
-	}
 }
 
-func TestAppClosersRunAfterGoroutinesStopInReverseOrder(t *testing.T) {
+func TestAppSignalHandlerRunsWithoutShutdown(t *testing.T) {
 	t.Parallel()
 
-	app := newTestApp(t, appTestConfig{}, &bytes.Buffer{})
-	var calls []string
-	app.Root("Run app", func(ctx *Ctx[appTestConfig]) error {
-		ctx.Register("first", func(run context.Context) error {
-			if run.Err() != nil {
-				t.Fatalf("first closer got cancelled shutdown context: %v", run.Err())
-			}
+	handled := make(chan string, 2)
+	app := newTestApp(t, appTestConfig{}, &bytes.Buffer{},
+		WithSignalHandler(testSignal{}, func(ctx context.Context) error {
+			handled <- "reload"
 
-			calls = append(calls, "first")
+			return errors.New("reload failed")
+		}),
+		WithSignalHandler(otherTestSignal{}, func(ctx context.Context) error {
+			handled <- "rotate"
 
 			return nil
-		})
-		ctx.Register("second", func(run context.Context) error {
-			if run.Err() != nil {
-				t.Fatalf("second closer got cancelled shutdown context: %v", run.Err())
-			}
+		}),
+	)
+	entered := make(chan struct{})
+	app.Root("Run app", func(ctx *Ctx[appTestConfig]) error {
+		ctx.Go("worker", func(run context.Context) error {
+			<-run.Done()
+
+			return nil
+		})
+		close(entered)
+
+		return nil
+	})
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- app.RunE()
+	}()
+
+	<-entered
+	app.handlerCh <- testSignal{}
+	if got := receiveString(t, handled, time.Second, "reload handler"); got != "reload" {
+		t.Fatalf("want reload handler, got %q", got)
+	}
+
+	app.handlerCh <- otherTestSignal{}
+	if got := receiveString(t, handled, time.Second, "rotate handler"); got != "rotate" {
+		t.Fatalf("want rotate handler, got %q", got)
+	}
+
+	if err := app.Ctx.Err(); err != nil {
+		t.Fatalf("want app to keep running after signal handlers, got %v", err)
+	}
+
+	app.signalCh <- testSignal{}
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAppOnReload(t *testing.T) {
+	t.Parallel()
+
+	var envMu sync.Mutex
+	env := map[string]string{"MAIA_LOG_LEVEL": "INFO"}
+	setEnv := func(name, value string) {
+		envMu.Lock()
+		defer envMu.Unlock()
+
+		env[name] = value
+	}
+
+	logs := make(notifyWriter, 10)
+	app := newTestApp(t, appTestConfig{}, &bytes.Buffer{},
+		WithLogger(slog.New(slog.NewTextHandler(logs, nil))),
+		WithLookupEnvFunc(func(name string) (string, bool) {
+			envMu.Lock()
+			defer envMu.Unlock()
+
+			value, ok := env[name]
+
+			return value, ok
+		}),
+	)
+
+	nextLog := func(name string) string {
+		for {
+			if got := receiveString(t, logs, time.Second, name); !strings.Contains(got, "app started") {
+				return got
+			}
+		}
+	}
+
+	reloaded := make(chan *appTestConfig, 1)
+	app.OnReload(func(cfg *appTestConfig) error {
+		reloaded <- cfg
+
+		return nil
+	})
+
+	entered := make(chan struct{})
+	app.Root("Run app", func(ctx *Ctx[appTestConfig]) error {
+		ctx.Go("worker", func(run context.Context) error {
+			<-run.Done()
+
+			return nil
+		})
+		close(entered)
+
+		return nil
+	})
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- app.RunE("--port", "9091")
+	}()
+
+	<-entered
+	setEnv("MAIA_LOG_LEVEL", "DEBUG")
+	app.handlerCh <- syscall.SIGHUP
+
+	select {
+	case cfg := <-reloaded:
+		if cfg.LogLevel != "DEBUG" || cfg.Port != 9091 {
+			t.Errorf("want reloaded config with env and flags, got %+v", cfg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for reload")
+	}
+
+	if got := nextLog("reload log"); !strings.Contains(got, "config reloaded") {
+		t.Fatalf("want config reloaded log, got %q", got)
+	}
+
+	setEnv("MAIA_PORT", "x")
+	app.handlerCh <- syscall.SIGHUP
+
+	got := nextLog("reload error log")
+	if !strings.Contains(got, `error="reloading config: Port env: parsing int`) {
+		t.Fatalf("want reload error logged, got %q", got)
+	}
+
+	if err := app.Ctx.Err(); err != nil {
+		t.Fatalf("want app to keep running after reload, got %v", err)
+	}
+
+	if app.Cfg.LogLevel != "INFO" {
+		t.Fatalf("want running config unchanged, got %q", app.Cfg.LogLevel)
+	}
+
+	app.signalCh <- testSignal{}
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+}
+
+type notifyWriter chan string
+
+func (w notifyWriter) Write(p []byte) (int, error) {
+	w <- string(p)
+
+	return len(p), nil
+}
+
+func TestAppConfigDumpSignalLogsRedactedConfig(t *testing.T) {
+	t.Parallel()
+
+	type config struct {
+		Port     int    `def:"8080"`
+		Password string `def:"hunter2" secret:"true"`
+	}
+
+	logs := make(notifyWriter, 10)
+	app := New("maia", &config{},
+		WithOutput(io.Discard),
+		WithErrorHandling(flag.ContinueOnError),
+		WithLogger(slog.New(slog.NewJSONHandler(logs, nil))),
+		WithConfigDumpSignal(testSignal{}),
+	)
+
+	entered := make(chan struct{})
+	app.Root("Run app", func(ctx *Ctx[config]) error {
+		ctx.Go("worker", func(run context.Context) error {
+			<-run.Done()
+
+			return nil
+		})
+		close(entered)
+
+		return nil
+	})
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- app.RunE()
+	}()
+
+	<-entered
+	app.handlerCh <- testSignal{}
+
+	got := receiveString(t, logs, time.Second, "config dump")
+	if strings.Contains(got, `"msg":"app started"`) {
+		got = receiveString(t, logs, time.Second, "config dump")
+	}
+
+	if !strings.Contains(got, `"msg":"config","config":{"port":8080,"password":"[REDACTED]"}`) {
+		t.Fatalf("want redacted config dump, got %s", got)
+	}
+
+	app.signalCh <- testSignal{}
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAppGoReceivesAppContextAndFailsFast(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApp(t, appTestConfig{}, &bytes.Buffer{})
+	boom := errors.New("boom")
+	gotContext := make(chan bool, 1)
+	app.Root("Run app", func(ctx *Ctx[appTestConfig]) error {
+		ctx.Go("worker", func(run context.Context) error {
+			gotContext <- run == ctx.Ctx
+
+			return boom
+		})
+
+		<-ctx.Ctx.Done()
+
+		return nil
+	})
+
+	err := app.RunE()
+	if err == nil {
+		t.Fatal("want goroutine error")
+	}
+
+	if !errors.Is(err, boom) {
+		t.Fatalf("want goroutine error %v, got %v", boom, err)
+	}
+
+	if !<-gotContext {
+		t.Fatal("Go did not receive app context")
+	}
+
+	if app.Ctx.Err() == nil {
+		t.Fatal("want app context cancelled after goroutine failure")
+	}
+}
+
+func TestAppGoRecoversPanic(t *testing.T) {
+	t.Parallel()
+
+	var logs bytes.Buffer
+	app := newTestApp(t, appTestConfig{}, &bytes.Buffer{},
+		WithLogger(slog.New(slog.NewJSONHandler(&logs, nil))),
+	)
+
+	closed := false
+	app.Root("Run app", func(ctx *Ctx[appTestConfig]) error {
+		ctx.Register("database", func(context.Context) error {
+			closed = true
+
+			return nil
+		})
+		ctx.Go("worker", func(context.Context) error {
+			panic("boom")
+		})
+
+		<-ctx.Ctx.Done()
+
+		return nil
+	})
+
+	err := app.RunE()
+	if err == nil || err.Error() != "goroutine worker panicked: boom" {
+		t.Fatalf("want goroutine panic error, got %v", err)
+	}
+
+	if !closed {
+		t.Fatal("want closers run after goroutine panic")
+	}
+
+	if !strings.Contains(logs.String(), `"msg":"goroutine panic","name":"worker","panic":"boom","stack":"goroutine `) {
+		t.Fatalf("want panic logged with stack, got %s", logs.String())
+	}
+}
+
+func TestAppGoExitsCleanlyOnContextCancellation(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApp(t, appTestConfig{}, &bytes.Buffer{})
+	stopped := make(chan struct{})
+	app.Root("Run app", func(ctx *Ctx[appTestConfig]) error {
+		ctx.Go("worker", func(run context.Context) error {
+			<-run.Done()
+			close(stopped)
+
+			return nil
+		})
+
+		app.cancel()
+
+		return nil
+	})
+
+	if err := app.RunE(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("worker did not stop after context cancellation")
+	}
+}
+
+func TestAppClosersRunAfterGoroutinesStopInReverseOrder(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApp(t, appTestConfig{}, &bytes.Buffer{})
+	var calls []string
+	app.Root("Run app", func(ctx *Ctx[appTestConfig]) error {
+		ctx.Register("first", func(run context.Context) error {
+			if run.Err() != nil {
+				t.Fatalf("first closer got cancelled shutdown context: %v", run.Err())
+			}
+
+			calls = append(calls, "first")
+
+			return nil
+		})
+		ctx.Register("second", func(run context.Context) error {
+			if run.Err() != nil {
+				t.Fatalf("second closer got cancelled shutdown context: %v", run.Err())
+			}
 
 			calls = append(calls, "second")
 
-			return errors.New("close second")
-		})
-		ctx.Go("worker", func(run context.Context) error {
-			<-run.Done()
-			calls = append(calls, "worker stopped")
+			return errors.New("close second")
+		})
+		ctx.Go("worker", func(run context.Context) error {
+			<-run.Done()
+			calls = append(calls, "worker stopped")
+
+			return nil
+		})
+
+		app.cancel()
+
+		return nil
+	})
+
+	err := app.RunE()
+	if err == nil {
+		t.Fatal("want closer error")
+	}
+
+	if !strings.Contains(err.Error(), "close second") {
+		t.Fatalf("want closer error recorded, got %v", err)
+	}
+
+	want := []string{"worker stopped", "second", "first"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("want calls %v, got %v", want, calls)
+	}
+}
+
+func TestAppSubContextSharesShutdown(t *testing.T) {
+	t.Parallel()
+
+	logs := &bytes.Buffer{}
+	app := newTestApp(t, appTestConfig{}, &bytes.Buffer{},
+		WithLogger(slog.New(slog.NewJSONHandler(logs, nil))))
+	var calls []string
+	app.Root("Run app", func(ctx *Ctx[appTestConfig]) error {
+		ctx.Register("app", func(context.Context) error {
+			calls = append(calls, "app")
+
+			return nil
+		})
+
+		sub := ctx.Sub("worker")
+		if sub.Cfg != ctx.Cfg || sub.Ctx != ctx.Ctx {
+			t.Error("want sub context to share config and context")
+		}
+
+		sub.Log.Info("sub started")
+		sub.Register("queue", func(run context.Context) error {
+			if run.Err() != nil {
+				t.Errorf("queue closer got cancelled shutdown context: %v", run.Err())
+			}
+
+			calls = append(calls, "queue")
+
+			return nil
+		})
+
+		app.cancel()
+
+		return nil
+	})
+
+	if err := app.RunE(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"queue", "app"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("want calls %v, got %v", want, calls)
+	}
+
+	var entry map[string]any
+	if err := json.NewDecoder(logs).Decode(&entry); err != nil {
+		t.Fatal(err)
+	}
+
+	if entry["msg"] != "sub started" || entry["subcommand"] != "worker" {
+		t.Fatalf("want sub log with subcommand attribute, got %v", entry)
+	}
+}
+
+func TestAppRunContextShutsDownOnCancel(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApp(t, appTestConfig{}, &bytes.Buffer{})
+	parent, cancel := context.WithCancel(context.Background())
+
+	stopped := make(chan struct{})
+	app.Root("Run app", func(ctx *Ctx[appTestConfig]) error {
+		ctx.Register("database", func(context.Context) error {
+			return errors.New("close database")
+		})
+		ctx.Go("worker", func(run context.Context) error {
+			<-run.Done()
+			close(stopped)
+
+			return nil
+		})
+
+		cancel()
+
+		return nil
+	})
+
+	err := app.RunContext(parent)
+	if err == nil || !strings.Contains(err.Error(), "close database") {
+		t.Fatalf("want closer error, got %v", err)
+	}
+
+	select {
+	case <-stopped:
+	default:
+		t.Fatal("want worker stopped")
+	}
+}
+
+func TestAppRunTwiceReturnsFirstResult(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApp(t, appTestConfig{}, &bytes.Buffer{})
+	boom := errors.New("boom")
+	calls := 0
+	app.Root("Run app", func(*Ctx[appTestConfig]) error {
+		calls++
+
+		return boom
+	})
+
+	if err := app.RunE(); !errors.Is(err, boom) {
+		t.Fatalf("want first run error %v, got %v", boom, err)
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- app.RunE()
+	}()
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, boom) {
+			t.Fatalf("want second run error %v, got %v", boom, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("second run blocked")
+	}
+
+	if calls != 1 {
+		t.Fatalf("want handler called once, got %d", calls)
+	}
+}
+
+func TestAppRunWhileRunningReturnsError(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApp(t, appTestConfig{}, &bytes.Buffer{})
+	entered := make(chan struct{})
+	app.Root("Run app", func(ctx *Ctx[appTestConfig]) error {
+		close(entered)
+		<-ctx.Ctx.Done()
+
+		return nil
+	})
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- app.RunE()
+	}()
+
+	<-entered
+
+	if err := app.RunE(); !errors.Is(err, ErrAlreadyRunning) {
+		t.Fatalf("want already running error, got %v", err)
+	}
+
+	app.cancel()
+
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAppRegisterWithTimeoutDoesNotStarveOtherClosers(t *testing.T) {
+	t.Parallel()
+
+	var logs bytes.Buffer
+	app := newTestApp(t, appTestConfig{}, &bytes.Buffer{},
+		WithShutdownTimeout(time.Minute),
+		WithLogger(slog.New(slog.NewJSONHandler(&logs, nil))),
+	)
+
+	var calls []string
+	app.Root("Run app", func(ctx *Ctx[appTestConfig]) error {
+		ctx.Register("database", func(run context.Context) error {
+			if err := run.Err(); err != nil {
+				t.Errorf("database closer got expired context: %v", err)
+			}
+
+			calls = append(calls, "database")
+
+			return errors.New("close database")
+		})
+		ctx.RegisterWithTimeout("queue", 10*time.Millisecond, func(run context.Context) error {
+			<-run.Done()
+			calls = append(calls, "queue")
+
+			return run.Err()
+		})
+
+		return nil
+	})
+
+	err := app.RunE()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("want deadline exceeded error, got %v", err)
+	}
+
+	if want := []string{"queue", "database"}; !reflect.DeepEqual(calls, want) {
+		t.Fatalf("want calls %v, got %v", want, calls)
+	}
+
+	if !strings.Contains(logs.String(), `"msg":"closer queue timed out","timeout":10000000`) {
+		t.Fatalf("want timeout warning for queue, got %s", logs.String())
+	}
+
+	if !strings.Contains(logs.String(), `"msg":"closer database","error":"close database"`) {
+		t.Fatalf("want error warning for database, got %s", logs.String())
+	}
+}
+
+func TestAppNonCriticalCloserDoesNotDelayExit(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+
+	var logs bytes.Buffer
+	app := newTestApp(t, appTestConfig{}, &bytes.Buffer{},
+		WithShutdownTimeout(20*time.Millisecond),
+		WithLogger(slog.New(slog.NewJSONHandler(&logs, nil))),
+	)
+
+	var calls []string
+	app.Root("Run app", func(ctx *Ctx[appTestConfig]) error {
+		ctx.Register("database", func(context.Context) error {
+			calls = append(calls, "database")
+
+			return nil
+		})
+		ctx.RegisterWithCriticality("metrics", false, func(context.Context) error {
+			<-release
+
+			return nil
+		})
+		ctx.RegisterWithCriticality("cache", false, func(context.Context) error {
+			calls = append(calls, "cache")
+
+			return errors.New("close cache")
+		})
+
+		return nil
+	})
+
+	start := time.Now()
+
+	err := app.RunE()
+	if err == nil || err.Error() != "close cache" {
+		t.Fatalf("want cache closer error, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("want exit without waiting on metrics closer, took %s", elapsed)
+	}
+
+	if want := []string{"cache", "database"}; !reflect.DeepEqual(calls, want) {
+		t.Fatalf("want calls %v, got %v", want, calls)
+	}
+
+	if !strings.Contains(logs.String(), `"msg":"closer metrics detached","timeout":20000000`) {
+		t.Fatalf("want detached warning for metrics, got %s", logs.String())
+	}
+}
+
+func TestAppCriticalCloserHoldsShutdown(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApp(t, appTestConfig{}, &bytes.Buffer{}, WithShutdownTimeout(10*time.Millisecond))
+
+	closed := false
+	app.Root("Run app", func(ctx *Ctx[appTestConfig]) error {
+		ctx.RegisterWithCriticality("database", true, func(run context.Context) error {
+			<-run.Done()
+			time.Sleep(50 * time.Millisecond)
+
+			closed = true
+
+			return nil
+		})
+
+		return nil
+	})
+
+	if err := app.RunE(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !closed {
+		t.Fatal("want exit to wait for critical closer")
+	}
+}
+
+func TestAppParallelShutdownRunsClosersConcurrently(t *testing.T) {
+	t.Parallel()
+
+	var logs bytes.Buffer
+	app := newTestApp(t, appTestConfig{}, &bytes.Buffer{},
+		WithParallelShutdown(),
+		WithLogger(slog.New(slog.NewJSONHandler(&logs, nil))),
+	)
+
+	var started sync.WaitGroup
+	started.Add(2)
+
+	var mu sync.Mutex
+	var calls []string
+	closer := func(name string) func(context.Context) error {
+		return func(run context.Context) error {
+			started.Done()
+
+			waited := make(chan struct{})
+			go func() {
+				started.Wait()
+				close(waited)
+			}()
+
+			select {
+			case <-waited:
+			case <-run.Done():
+				return run.Err()
+			}
+
+			mu.Lock()
+			calls = append(calls, name)
+			mu.Unlock()
+
+			return errors.New("close " + name)
+		}
+	}
+
+	app.Root("Run app", func(ctx *Ctx[appTestConfig]) error {
+		ctx.Register("database", func(context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			calls = append(calls, "database")
 
 			return nil
 		})
-
-		app.cancel()
+		ctx.RegisterWithDeps("http", []string{"database"}, closer("http"))
+		ctx.RegisterWithDeps("consumer", []string{"database"}, closer("consumer"))
 
 		return nil
 	})
 
 	err := app.RunE()
-	if err == nil {
-		t.Fatal("want closer error")
+	if err == nil || !strings.Contains(err.Error(), "close http") || !strings.Contains(err.Error(), "close consumer") {
+		t.Fatalf("want both closer errors, got %v", err)
 	}
 
-	if !strings.Contains(err.Error(), "close second") {
-		t.Fatalf("want closer error recorded, got %v", err)
+	if len(calls) != 3 || calls[2] != "database" {
+		t.Fatalf("want database closed after its dependents, got %v", calls)
 	}
 
-	want := []string{"worker stopped", "second", "first"}
+	for _, name := range []string{"http", "consumer"} {
+		if !strings.Contains(logs.String(), `"msg":"closer `+name+`","error":"close `+name+`"`) {
+			t.Fatalf("want error warning for %s, got %s", name, logs.String())
+		}
+	}
+}
+
+func TestAppClosersRunInDependencyOrder(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApp(t, appTestConfig{}, &bytes.Buffer{})
+	var calls []string
+	closer := func(name string) func(context.Context) error {
+		return func(context.Context) error {
+			calls = append(calls, name)
+
+			return nil
+		}
+	}
+
+	app.Root("Run app", func(ctx *Ctx[appTestConfig]) error {
+		ctx.RegisterWithDeps("db", []string{"migrations", "tracing"}, closer("db"))
+		ctx.Register("tracing", closer("tracing"))
+		ctx.Register("migrations", closer("migrations"))
+		ctx.RegisterWithDeps("api", []string{"db"}, closer("api"))
+		ctx.Register("metrics", closer("metrics"))
+
+		return nil
+	})
+
+	if err := app.RunE(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"metrics", "api", "db", "migrations", "tracing"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("want calls %v, got %v", want, calls)
+	}
+}
+
+func TestAppClosersDependencyErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		register func(ctx *Ctx[appTestConfig], closer func(string) func(context.Context) error)
+		wantErr  string
+		want     []string
+	}{
+		"cycle": {
+			register: func(ctx *Ctx[appTestConfig], closer func(string) func(context.Context) error) {
+				ctx.Register("cache", closer("cache"))
+				ctx.RegisterWithDeps("db", []string{"migrations"}, closer("db"))
+				ctx.RegisterWithDeps("migrations", []string{"db"}, closer("migrations"))
+			},
+			wantErr: "closer dependency cycle between migrations, db",
+			want:    []string{"migrations", "db", "cache"},
+		},
+		"unknown": {
+			register: func(ctx *Ctx[appTestConfig], closer func(string) func(context.Context) error) {
+				ctx.Register("cache", closer("cache"))
+				ctx.RegisterWithDeps("db", []string{"migrations"}, closer("db"))
+			},
+			wantErr: "closer db depends on unknown closer migrations",
+			want:    []string{"db", "cache"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			app := newTestApp(t, appTestConfig{}, &bytes.Buffer{})
+			var calls []string
+			closer := func(name string) func(context.Context) error {
+				return func(context.Context) error {
+					calls = append(calls, name)
+
+					return nil
+				}
+			}
+
+			app.Root("Run app", func(ctx *Ctx[appTestConfig]) error {
+				tt.register(ctx, closer)
+
+				return nil
+			})
+
+			err := app.RunE()
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("want error %q, got %v", tt.wantErr, err)
+			}
+
+			if !reflect.DeepEqual(calls, tt.want) {
+				t.Fatalf("want calls in reverse registration order %v, got %v", tt.want, calls)
+			}
+		})
+	}
+}
+
+func TestAppPreShutdownHooksRunBeforeGracePeriod(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApp(t, appTestConfig{}, &bytes.Buffer{})
+	app.timeout = time.Minute
+
+	var calls []string
+	var preDone time.Time
+	app.Root("Run app", func(ctx *Ctx[appTestConfig]) error {
+		ctx.Register("closer", func(run context.Context) error {
+			deadline, ok := run.Deadline()
+			if !ok {
+				t.Fatal("want closer context with deadline")
+			}
+			if started := deadline.Add(-app.timeout); started.Before(preDone) {
+				t.Fatalf("want grace period to start after pre-shutdown, started %s before %s", started, preDone)
+			}
+
+			calls = append(calls, "closer")
+
+			return nil
+		})
+		ctx.RegisterPreShutdown("first", func(run context.Context) error {
+			if _, ok := run.Deadline(); ok {
+				t.Fatal("want pre-shutdown context without deadline")
+			}
+
+			calls = append(calls, "first")
+			preDone = time.Now()
+
+			return nil
+		})
+		ctx.RegisterPreShutdown("second", func(context.Context) error {
+			time.Sleep(10 * time.Millisecond)
+			calls = append(calls, "second")
+
+			return errors.New("pre-shutdown second")
+		})
+
+		return nil
+	})
+
+	err := app.RunE()
+	if err == nil || !strings.Contains(err.Error(), "pre-shutdown second") {
+		t.Fatalf("want pre-shutdown error recorded, got %v", err)
+	}
+
+	want := []string{"second", "first", "closer"}
 	if !reflect.DeepEqual(calls, want) {
 		t.Fatalf("want calls %v, got %v", want, calls)
 	}
 }
 
+func TestAppHTTPServerServesDuringPreShutdown(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApp(t, appTestConfig{}, &bytes.Buffer{})
+	app.timeout = time.Second
+
+	probe, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := probe.Addr().String()
+	if err := probe.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/readyz", app.HealthHandler())
+
+	app.Root("Run app", func(ctx *Ctx[appTestConfig]) error {
+		ctx.HTTPServer("http api", &http.Server{Addr: addr, Handler: mux}) //nolint:gosec,exhaustruct
+		ctx.RegisterPreShutdown("deregister", func(context.Context) error {
+			// Give the server time to stop if it would not wait for pre-shutdown hooks.
+			time.Sleep(50 * time.Millisecond)
+
+			client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}} //nolint:exhaustruct
+			resp, err := client.Get("http://" + addr + "/readyz")
+			if err != nil {
+				return fmt.Errorf("want server serving during pre-shutdown: %w", err)
+			}
+			_ = resp.Body.Close()
+
+			if resp.StatusCode != http.StatusServiceUnavailable {
+				return fmt.Errorf("want readiness 503 during pre-shutdown, got %d", resp.StatusCode)
+			}
+
+			return nil
+		})
+
+		go func() {
+			if err := getUntilStatus("http://"+addr+"/readyz", http.StatusOK); err != nil {
+				t.Error(err)
+			}
+
+			app.cancel()
+		}()
+
+		return nil
+	})
+
+	if err := app.RunE(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAppUnboundedShutdownClosersHaveNoDeadline(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApp(t, appTestConfig{}, &bytes.Buffer{}, WithShutdownTimeout(0))
+	var closed bool
+	app.Root("Run app", func(ctx *Ctx[appTestConfig]) error {
+		ctx.Register("batch", func(run context.Context) error {
+			if _, ok := run.Deadline(); ok {
+				return errors.New("want closer context without deadline")
+			}
+			if err := run.Err(); err != nil {
+				return err
+			}
+
+			closed = true
+
+			return nil
+		})
+
+		return nil
+	})
+
+	if err := app.RunE(); err != nil {
+		t.Fatal(err)
+	}
+	if !closed {
+		t.Fatal("want closer to run")
+	}
+}
+
+func TestAppPreShutdownTimeout(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApp(t, appTestConfig{}, &bytes.Buffer{}, WithPreShutdownTimeout(time.Hour))
+	app.Root("Run app", func(ctx *Ctx[appTestConfig]) error {
+		ctx.RegisterPreShutdown("batch", func(run context.Context) error {
+			deadline, ok := run.Deadline()
+			if !ok {
+				return errors.New("missing deadline")
+			}
+			if time.Until(deadline) <= app.timeout {
+				return fmt.Errorf("want pre-shutdown deadline beyond grace period, got %s", time.Until(deadline))
+			}
+
+			return nil
+		})
+
+		return nil
+	})
+
+	if err := app.RunE(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAppHealthHandler(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApp(t, appTestConfig{}, &bytes.Buffer{})
+
+	var ready, live *httptest.ResponseRecorder
+
+	app.Root("Run app", func(ctx *Ctx[appTestConfig]) error {
+		ctx.RegisterHealthCheck("db", func(context.Context) error { return nil })
+		ctx.RegisterHealthCheck("cache", func(context.Context) error { return errors.New("connection refused") })
+
+		ready = httptest.NewRecorder()
+		ctx.HealthHandler().ServeHTTP(ready, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+		live = httptest.NewRecorder()
+		ctx.LivenessHandler().ServeHTTP(live, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+		return nil
+	})
+
+	if err := app.RunE(); err != nil {
+		t.Fatal(err)
+	}
+
+	if ready.Code != http.StatusServiceUnavailable {
+		t.Errorf("want readiness status %d, got %d", http.StatusServiceUnavailable, ready.Code)
+	}
+
+	want := `{"status":"fail","checks":[{"name":"db","status":"ok"},` +
+		`{"name":"cache","status":"fail","error":"connection refused"}]}`
+	if got := strings.TrimSpace(ready.Body.String()); got != want {
+		t.Errorf("want readiness body %s, got %s", want, got)
+	}
+
+	if got := ready.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("want json content type, got %q", got)
+	}
+
+	if live.Code != http.StatusOK {
+		t.Errorf("want liveness status %d, got %d", http.StatusOK, live.Code)
+	}
+
+	if want, got := `{"status":"ok"}`, strings.TrimSpace(live.Body.String()); got != want {
+		t.Errorf("want liveness body %s, got %s", want, got)
+	}
+}
+
+func TestAppHealthHandlerFailsDuringShutdown(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApp(t, appTestConfig{}, &bytes.Buffer{})
+
+	var checked bool
+
+	app.RegisterHealthCheck("db", func(context.Context) error {
+		checked = true
+
+		return nil
+	})
+
+	res := httptest.NewRecorder()
+	app.HealthHandler().ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	if res.Code != http.StatusOK || !checked {
+		t.Fatalf("want ready before shutdown, got %d", res.Code)
+	}
+
+	checked = false
+
+	app.RegisterPreShutdown("readiness", func(context.Context) error {
+		res = httptest.NewRecorder()
+		app.HealthHandler().ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+		return nil
+	})
+	app.Root("Run app", func(*Ctx[appTestConfig]) error { return nil })
+
+	if err := app.RunE(); err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Code != http.StatusServiceUnavailable {
+		t.Errorf("want status %d, got %d", http.StatusServiceUnavailable, res.Code)
+	}
+
+	if want, got := `{"status":"shutting down"}`, strings.TrimSpace(res.Body.String()); got != want {
+		t.Errorf("want body %s, got %s", want, got)
+	}
+
+	if checked {
+		t.Error("want checks skipped during shutdown")
+	}
+}
+
+func TestAppRejectWhenShuttingDown(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApp(t, appTestConfig{}, &bytes.Buffer{})
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	handler := app.RejectWhenShuttingDown()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(entered)
+			<-release
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("want status %d before shutdown, got %d", http.StatusOK, res.Code)
+	}
+
+	inFlight := httptest.NewRecorder()
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		handler.ServeHTTP(inFlight, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	}()
+
+	<-entered
+	app.cancel()
+
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if res.Code != http.StatusServiceUnavailable {
+		t.Errorf("want status %d during shutdown, got %d", http.StatusServiceUnavailable, res.Code)
+	}
+
+	if got := res.Header().Get("Connection"); got != "close" {
+		t.Errorf("want Connection close, got %q", got)
+	}
+
+	close(release)
+	<-done
+
+	if inFlight.Code != http.StatusOK {
+		t.Errorf("want in-flight request to finish with %d, got %d", http.StatusOK, inFlight.Code)
+	}
+}
+
 func TestAppExitRecordsFatalError(t *testing.T) {
 	t.Parallel()
 
@@ -1006,3 +2963,11 @@ func (testSignal) String() string {
 }
 
 func (testSignal) Signal() {}
+
+type otherTestSignal struct{}
+
+func (otherTestSignal) String() string {
+	return "other test signal"
+}
+
+func (otherTestSignal) Signal() {}
@@ -1,18 +1,28 @@
 package bee
 
 import (
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"io/fs"
+	"maps"
 	"math"
+	"net"
+	"net/netip"
 	"os"
+	"path/filepath"
 	"reflect"
 	"regexp"
+	"runtime/debug"
 	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/iancoleman/strcase"
 )
@@ -23,22 +33,72 @@ var durationType = reflect.TypeFor[time.Duration]()
 var (
 	ErrInvalidConfigType = errors.New("invalid config type")
 	ErrUnsupportedType   = errors.New("type not supported")
+	ErrMissingRequired   = errors.New("missing required value")
+	ErrValidation        = errors.New("validation failed")
+	ErrAlreadyRunning    = errors.New("app is already running")
 )
 
 type requiredField struct {
 	fieldName string
+	tag       string
 	flagName  string
 	envName   string
 }
 
 type commandLine struct {
-	flagSet       *flag.FlagSet
-	output        io.Writer
-	lookupEnvFunc func(string) (string, bool)
-	name          string
-	errorHandling flag.ErrorHandling
-	help          bool
-	required      []requiredField
+	flagSet        *flag.FlagSet
+	output         io.Writer
+	lookupEnvFunc  func(string) (string, bool)
+	buildInfoFunc  func() (*debug.BuildInfo, bool)
+	name           string
+	errorHandling  flag.ErrorHandling
+	errorFormat    func(io.Writer, error)
+	help           bool
+	required       []requiredField
+	version        string
+	secretsDir     string
+	strictEnv      bool
+	envErrors      []error
+	configFile     string
+	configOptional bool
+	configFlag     string
+	configEnv      string
+	cmdConfig      any
+	cmdEnvPrefix   string
+	fileFields     map[string]struct{}
+	sources        map[string]string
+	secrets        map[string]struct{}
+	secretScope    bool
+	experimental   map[string]struct{}
+	specs          map[string]flagSpec
+	shorts         map[string]shortFlag
+	refs           []fieldRef
+	beforeExit     func()
+}
+
+// fieldRef is a field tagged with ref, set to the value of the referenced field after parsing.
+type fieldRef struct {
+	value reflect.Value
+	src   reflect.Value
+}
+
+// shortFlag is a single-character alias of the flag of the field.
+type shortFlag struct {
+	fieldName string
+	flagName  string
+}
+
+// flagSpec describes one flag in machine-readable form, i.e. for shell completion tools.
+type flagSpec struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Default string `json:"default"`
+	Env     string `json:"env"`
+	Help    string `json:"help"`
+	Short   string `json:"short,omitempty"`
+
+	// def is the unparsed default value, as written in the def tag, used by env template.
+	def string
 }
 
 func newCommandLine(name string) *commandLine {
@@ -46,8 +106,10 @@ func newCommandLine(name string) *commandLine {
 		flagSet:       flag.NewFlagSet(name, flag.ContinueOnError),
 		output:        os.Stderr,
 		lookupEnvFunc: os.LookupEnv,
+		buildInfoFunc: debug.ReadBuildInfo,
 		name:          name,
 		errorHandling: flag.ExitOnError,
+		errorFormat:   formatError,
 	}
 
 	a.flagSet.SetOutput(a.output)
@@ -55,565 +117,1393 @@ func newCommandLine(name string) *commandLine {
 	return a
 }
 
+// reloadCommandLine returns command line with the same config sources as cl, which returns errors
+// instead of exiting and doesn't write usage, to parse the config again on reload.
+func (cl *commandLine) reloadCommandLine() *commandLine {
+	r := newCommandLine(cl.name)
+	r.output = io.Discard
+	r.flagSet.SetOutput(io.Discard)
+	r.errorHandling = flag.ContinueOnError
+	r.lookupEnvFunc = cl.lookupEnvFunc
+	r.buildInfoFunc = cl.buildInfoFunc
+	r.secretsDir = cl.secretsDir
+	r.strictEnv = cl.strictEnv
+	r.configFile = cl.configFile
+	r.configOptional = cl.configOptional
+	r.configFlag = cl.configFlag
+	r.configEnv = cl.configEnv
+	r.cmdEnvPrefix = cl.cmdEnvPrefix
+
+	if cl.cmdConfig != nil {
+		r.cmdConfig = reflect.New(reflect.TypeOf(cl.cmdConfig).Elem()).Interface()
+	}
+
+	return r
+}
+
 func (cl *commandLine) parse(config any, flags []string) error {
-	cl.required = nil
-	cl.help = false
+	cl.reset()
+
+	if err := cl.resolveVersion(config, flags); err != nil {
+		return cl.exit(err)
+	}
+
+	path, optional := cl.resolveConfigFile(flags)
+	if path != "" {
+		fields, err := cl.loadConfigFile(config, path, optional)
+		if err != nil {
+			return cl.exit(err)
+		}
+
+		cl.fileFields = fields
+	}
+
+	if err := cl.subParse(config, flags, "", cl.name); err != nil {
+		return cl.exit(err)
+	}
+
+	if cl.cmdConfig != nil {
+		if err := cl.subParse(cl.cmdConfig, flags, "", cl.cmdEnvPrefix); err != nil {
+			return cl.exit(err)
+		}
+	}
 
-	if err := cl.subParse(config, flags, ""); err != nil {
+	if err := cl.registerShorts(); err != nil {
 		return cl.exit(err)
 	}
 
+	cl.maskSecretDefaults()
+
+	if len(cl.envErrors) > 0 {
+		return cl.exit(errors.Join(cl.envErrors...))
+	}
+
 	if err := cl.flagSet.Parse(flags); err != nil {
 		return cl.exit(err)
 	}
 
+	for name := range cl.setFlags() {
+		cl.sources[name] = "flag"
+	}
+
 	if err := cl.validateRequired(); err != nil {
 		return cl.exit(err)
 	}
 
+	if err := cl.normalize(); err != nil {
+		return cl.exit(err)
+	}
+
+	cl.resolveRefs()
+
 	if err := cl.validate(config); err != nil {
 		return cl.exit(err)
 	}
 
+	if cl.cmdConfig != nil {
+		if err := cl.validate(cl.cmdConfig); err != nil {
+			return cl.exit(err)
+		}
+	}
+
 	return nil
 }
 
-func (cl *commandLine) subParse(config any, flags []string, prefix string) error { //nolint:cyclop
-	cl.parseHelp(flags)
+var (
+	defaultFuncsMu sync.RWMutex
+	defaultFuncs   = map[string]func() (string, error){}
+)
 
-	v := reflect.ValueOf(config)
+// RegisterDefaultFunc registers function computing default value, i.e. host name, which can be
+// used by fields tagged with default-func:"name" instead of def tag. The function is not called
+// when the value is supplied by environment variable, secret or config file, while command line
+// flags override the computed value.
+func RegisterDefaultFunc(name string, fn func() (string, error)) {
+	defaultFuncsMu.Lock()
+	defer defaultFuncsMu.Unlock()
 
-	if !v.IsValid() {
-		return ErrInvalidConfigType
-	}
+	defaultFuncs[name] = fn
+}
 
-	if v.Kind() != reflect.Pointer || v.IsNil() {
-		return ErrInvalidConfigType
+// defaultValue returns the value computed by registered function named by default-func tag or
+// the value of def tag.
+func defaultValue(field reflect.StructField) (string, error) {
+	name, ok := field.Tag.Lookup("default-func")
+	if !ok {
+		return field.Tag.Get("def"), nil
 	}
 
-	t := v.Type()
+	defaultFuncsMu.RLock()
+	fn, ok := defaultFuncs[name]
+	defaultFuncsMu.RUnlock()
 
-	if t.Elem().Kind() != reflect.Struct {
-		return ErrInvalidConfigType
+	if !ok {
+		return "", fmt.Errorf("unknown default func %q", name)
 	}
 
-	v = v.Elem()
+	return fn()
+}
 
-	for i := 0; i < v.NumField(); i++ {
-		field := t.Elem().Field(i)
+// hasDefault reports whether the field has def or default-func tag.
+func hasDefault(field reflect.StructField) bool {
+	_, def := field.Tag.Lookup("def")
+	_, defFunc := field.Tag.Lookup("default-func")
 
-		flagName := cl.flagName(field, prefix)
+	return def || defFunc
+}
+
+// buildSetting returns the value of build setting key, i.e. vcs.revision, from the build info
+// embedded in the binary. Keys version, path and go select the main module version, the main
+// module path and the Go version. Version "(devel)" of binaries built outside of module mode is
+// not reported.
+func (cl *commandLine) buildSetting(key string) (string, bool) {
+	info, ok := cl.buildInfoFunc()
+	if !ok || info == nil {
+		return "", false
+	}
+
+	var value string
+
+	switch key {
+	case "version":
+		value = info.Main.Version
+	case "path":
+		value = info.Main.Path
+	case "go":
+		value = info.GoVersion
+	default:
+		for _, setting := range info.Settings {
+			if setting.Key == key {
+				value = setting.Value
+			}
+		}
+	}
 
-		envVarName := cl.envVarName(field, prefix)
+	if value == "" || value == "(devel)" {
+		return "", false
+	}
 
-		usage := cl.usage(field, envVarName, prefix)
+	return value, true
+}
 
-		fieldValue := v.Field(i)
-		if field.PkgPath != "" || !fieldValue.CanAddr() || !fieldValue.Addr().CanInterface() {
-			return ErrInvalidConfigType
-		}
+var (
+	normalizersMu sync.RWMutex
+	normalizers   = map[string]func(string) (string, error){}
+)
 
-		p := fieldValue.Addr().Interface()
+// RegisterNormalizer registers function transforming the resolved value of the string field with
+// the given flag name, i.e. trimming or lowercasing it. Normalizers are applied before validation.
+func RegisterNormalizer(flagName string, fn func(string) (string, error)) {
+	normalizersMu.Lock()
+	defer normalizersMu.Unlock()
 
-		// Recurse if got struct which is not of URL type
-		_, oku := p.(*URL)
-		_, okt := p.(*Time)
+	normalizers[flagName] = fn
+}
 
-		if field.Type.Kind() == reflect.Struct && !oku && !okt {
-			if err := cl.subParse(p, flags, cl.newPrefix(field, prefix)); err != nil {
-				return err
-			}
+// normalize applies registered normalizers to the resolved values of string flags.
+func (cl *commandLine) normalize() error {
+	normalizersMu.RLock()
+	defer normalizersMu.RUnlock()
 
+	for name, fn := range normalizers {
+		f := cl.flagSet.Lookup(name)
+		if f == nil {
 			continue
 		}
 
-		if err := cl.parseRequired(field, flagName, envVarName); err != nil {
-			return err
+		getter, ok := f.Value.(flag.Getter)
+		if !ok {
+			continue
 		}
 
-		envVarValue, ok := cl.lookupEnvFunc(envVarName)
-		if ok && !cl.help {
-			if err := cl.validateFlagName(flagName); err != nil {
-				return fmt.Errorf("%s env: %w", field.Name, err)
-			}
-
-			if err := cl.parseValue(field.Type.Kind(), p, flagName, envVarValue, usage); err != nil {
-				return fmt.Errorf("%s env: %w", field.Name, err)
-			}
-
-			continue
+		value, ok := getter.Get().(string)
+		if !ok {
+			return fmt.Errorf("normalizing %s: %w", name, ErrUnsupportedType)
 		}
 
-		if err := cl.validateFlagName(flagName); err != nil {
-			return fmt.Errorf("%s def: %w", field.Name, err)
+		normalized, err := fn(value)
+		if err != nil {
+			return fmt.Errorf("normalizing %s: %w", name, err)
 		}
 
-		if err := cl.parseValue(field.Type.Kind(), p, flagName, field.Tag.Get("def"), usage); err != nil {
-			return fmt.Errorf("%s def: %w", field.Name, err)
+		if err := f.Value.Set(normalized); err != nil {
+			return fmt.Errorf("normalizing %s: %w", name, err)
 		}
 	}
 
 	return nil
 }
 
-func (cl *commandLine) parseRequired(field reflect.StructField, flagName string, envName string) error {
-	if _, ok := field.Tag.Lookup("req"); !ok {
-		return nil
+func (cl *commandLine) reset() {
+	cl.required = nil
+	cl.envErrors = nil
+	cl.fileFields = nil
+	cl.sources = map[string]string{}
+	cl.secrets = map[string]struct{}{}
+	cl.secretScope = false
+	cl.experimental = map[string]struct{}{}
+	cl.specs = map[string]flagSpec{}
+	cl.shorts = map[string]shortFlag{}
+	cl.refs = nil
+	cl.help = false
+	cl.version = ""
+}
+
+// flagSpecs returns specs of the flags config would be parsed into, sorted by flag name, with
+// default values instead of values of environment variables, secrets and config file.
+func (cl *commandLine) flagSpecs(config any) ([]flagSpec, error) {
+	cl.reset()
+	cl.lookupEnvFunc = func(string) (string, bool) { return "", false }
+	cl.secretsDir = ""
+
+	if err := cl.resolveVersion(config, nil); err != nil {
+		return nil, err
 	}
 
-	if _, ok := field.Tag.Lookup("def"); ok {
-		return fmt.Errorf("%s req: cannot combine req and def tags", field.Name)
+	if err := cl.subParse(config, nil, "", cl.name); err != nil {
+		return nil, err
 	}
 
-	if _, ok := cl.lookupEnvFunc(envName); ok && !cl.help {
-		return nil
+	if err := cl.registerShorts(); err != nil {
+		return nil, err
 	}
 
-	cl.required = append(cl.required, requiredField{
-		fieldName: field.Name,
-		flagName:  flagName,
-		envName:   envName,
+	cl.maskSecretDefaults()
+
+	specs := make([]flagSpec, 0, len(cl.specs))
+
+	cl.flagSet.VisitAll(func(f *flag.Flag) {
+		if spec, ok := cl.specs[f.Name]; ok {
+			spec.Default = f.DefValue
+			specs = append(specs, spec)
+		}
 	})
 
-	return nil
+	return specs, nil
 }
 
-func (cl *commandLine) validateRequired() error {
-	if cl.help {
-		return nil
+// writeEnvTemplate writes sample .env file with KEY=default # help line for each field of config,
+// sorted by flag name. Values of fields tagged with secret are left empty.
+func (cl *commandLine) writeEnvTemplate(w io.Writer, config any) error {
+	specs, err := cl.flagSpecs(config)
+	if err != nil {
+		return err
 	}
 
-	setFlags := map[string]struct{}{}
-	cl.flagSet.Visit(func(f *flag.Flag) {
-		setFlags[f.Name] = struct{}{}
-	})
+	for _, spec := range specs {
+		value, help := spec.def, spec.Help
+		if _, secret := cl.secrets[spec.Name]; secret {
+			value, help = "", help+" (secret)"
+		}
 
-	for _, field := range cl.required {
-		if _, ok := setFlags[field.flagName]; ok {
-			continue
+		if strings.ContainsAny(value, " \t#\"'") {
+			value = strconv.Quote(value)
 		}
 
-		return fmt.Errorf(
-			"%s req: required value missing; set %s or -%s",
-			field.fieldName,
-			field.envName,
-			field.flagName,
-		)
+		if _, err := fmt.Fprintf(w, "%s=%s # %s\n", spec.Env, value, help); err != nil {
+			return fmt.Errorf("writing env template: %w", err)
+		}
 	}
 
 	return nil
 }
 
-func (cl *commandLine) validate(config any) error {
-	if cl.help {
-		return nil
+// resolveConfigFile returns path of the config file selected by the config file flag or
+// environment variable, falling back to the static path, and whether a missing file is skipped.
+// The config file flag is registered, so it is accepted by the flag set and shown in usage.
+func (cl *commandLine) resolveConfigFile(flags []string) (string, bool) {
+	if cl.configFlag == "" {
+		return cl.configFile, cl.configOptional
+	}
+
+	usage := "config file"
+	if cl.configEnv != "" {
+		usage = fmt.Sprintf("%s (env %s)", usage, cl.configEnv)
+	}
+
+	cl.flagSet.String(cl.configFlag, cl.configFile, usage)
+
+	if value, ok := lookupFlag(flags, cl.configFlag); ok {
+		return value, false
 	}
 
+	if cl.configEnv != "" {
+		if value, ok := cl.lookupEnvFunc(cl.configEnv); ok {
+			return value, false
+		}
+	}
+
+	return cl.configFile, cl.configOptional
+}
+
+func (cl *commandLine) subParse(config any, flags []string, prefix, envPrefix string) error { //nolint:cyclop
+	cl.parseHelp(flags)
+
 	v := reflect.ValueOf(config)
-	if !v.IsValid() || v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+
+	if !v.IsValid() {
 		return ErrInvalidConfigType
 	}
 
-	return cl.validateStruct(v.Elem())
-}
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return ErrInvalidConfigType
+	}
 
-func (cl *commandLine) validateStruct(v reflect.Value) error {
 	t := v.Type()
+
+	if t.Elem().Kind() != reflect.Struct {
+		return ErrInvalidConfigType
+	}
+
+	v = v.Elem()
+
 	for i := 0; i < v.NumField(); i++ {
-		field := t.Field(i)
-		value := v.Field(i)
-		if field.PkgPath != "" {
-			return ErrInvalidConfigType
+		field := t.Elem().Field(i)
+		if ignoredField(field) {
+			continue
 		}
 
-		if value.Kind() == reflect.Struct && !isSpecialStructValue(value) {
-			if err := cl.validateStruct(value); err != nil {
+		if name, ok := field.Tag.Lookup("ref"); ok {
+			src, err := refField(v, field, name)
+			if err != nil {
 				return err
 			}
 
+			cl.refs = append(cl.refs, fieldRef{value: v.Field(i), src: src})
+
 			continue
 		}
 
-		if err := cl.validateField(field, value); err != nil {
-			return err
+		flagName := cl.flagName(field, prefix)
+
+		envVarNames := cl.envVarNames(field, envPrefix)
+		envVarName := envVarNames[0]
+
+		usage := cl.usage(field, strings.Join(envVarNames, ", "), prefix)
+		cl.specs[flagName] = flagSpec{
+			Name:    flagName,
+			Type:    field.Type.String(),
+			Default: "",
+			Env:     envVarName,
+			Help:    cl.description(field, prefix),
 		}
-	}
 
-	return nil
-}
+		if _, experimental := field.Tag.Lookup("experimental"); experimental {
+			usage += " [experimental]"
+			cl.experimental[flagName] = struct{}{}
+		}
 
-func isSpecialStructValue(v reflect.Value) bool {
-	if !v.CanAddr() {
-		return false
-	}
+		if short := field.Tag.Get("short"); short != "" {
+			if utf8.RuneCountInString(short) != 1 {
+				return fmt.Errorf("%s short: invalid short flag %q: %w", field.Name, short, ErrInvalidConfigType)
+			}
 
-	switch v.Addr().Interface().(type) {
-	case *URL, *Time:
-		return true
-	default:
-		return false
-	}
-}
+			if other, ok := cl.shorts[short]; ok {
+				return fmt.Errorf("%s short: flag -%s already used by %s: %w", field.Name, short, other.fieldName,
+					ErrInvalidConfigType)
+			}
 
-func (cl *commandLine) validateField(field reflect.StructField, value reflect.Value) error {
-	if err := validateMinMax(field, value); err != nil {
-		return err
-	}
+			cl.shorts[short] = shortFlag{fieldName: field.Name, flagName: flagName}
+		}
 
-	if err := validateOneOf(field, value); err != nil {
-		return err
-	}
+		fieldValue := v.Field(i)
+		if !fieldValue.CanAddr() || !fieldValue.Addr().CanInterface() {
+			return ErrInvalidConfigType
+		}
 
-	if err := validateLengths(field, value); err != nil {
-		return err
-	}
+		p := fieldValue.Addr().Interface()
 
-	if err := validateRegex(field, value); err != nil {
-		return err
-	}
+		if version := field.Tag.Get("version"); version != "" && prefix == "" && field.Type.Kind() == reflect.Struct {
+			if version != cl.version {
+				continue
+			}
 
-	if err := validatePrefixSuffix(field, value); err != nil {
-		return err
-	}
+			if err := cl.subParse(p, flags, "", envPrefix); err != nil {
+				return err
+			}
 
-	if err := validateNonzero(field, value); err != nil {
-		return err
-	}
+			continue
+		}
 
-	return nil
-}
+		// Recurse if got struct which is not of special type, like URL
+		if field.Type.Kind() == reflect.Struct && !isSpecialStructType(field.Type) {
+			secretScope := cl.secretScope
+			if _, secret := field.Tag.Lookup("secret"); secret {
+				cl.secretScope = true
+			}
 
-func validateMinMax(field reflect.StructField, value reflect.Value) error {
-	if min := field.Tag.Get("min"); min != "" {
-		if err := compareMinimum(field, value, min); err != nil {
-			return err
+			newPrefix, newEnvPrefix := cl.newPrefix(field, prefix), cl.newEnvPrefix(field, envPrefix)
+			if field.Anonymous {
+				newPrefix = prefix
+			}
+
+			err := cl.subParse(p, flags, newPrefix, newEnvPrefix)
+			cl.secretScope = secretScope
+
+			if err != nil {
+				return err
+			}
+
+			continue
 		}
-	}
 
-	if max := field.Tag.Get("max"); max != "" {
-		if err := compareMaximum(field, value, max); err != nil {
-			return err
+		if _, secret := field.Tag.Lookup("secret"); secret || cl.secretScope {
+			cl.secrets[flagName] = struct{}{}
 		}
-	}
 
-	return nil
-}
+		envVarName, envVarValue, ok := cl.lookupEnvNames(envVarNames)
+		if _, indexed := field.Tag.Lookup("env-indexed"); indexed && !ok {
+			if kind := field.Type.Kind(); kind != reflect.Slice && kind != reflect.Array {
+				return fmt.Errorf("%s env-indexed: %w", field.Name, ErrInvalidConfigType)
+			}
 
-func compareMinimum(field reflect.StructField, value reflect.Value, min string) error { //nolint:cyclop
-	if value.Type() == durationType {
-		limit, err := time.ParseDuration(min)
-		if err != nil {
-			return fmt.Errorf("%s min: parsing duration %q: %w", field.Name, min, err)
+			value, found, err := cl.lookupIndexedEnv(envVarName, separator(field))
+			if err != nil {
+				return fmt.Errorf("%s env: %w", field.Name, err)
+			}
+
+			if found {
+				envVarValue, ok = value, true
+			}
 		}
 
-		got := value.Interface().(time.Duration)
-		if got < limit {
-			return fmt.Errorf("%s min: value %s must be >= %s", field.Name, got, limit)
+		if field.Type.Kind() == reflect.Bool {
+			value, negated, err := cl.lookupNegatedEnv(field, envPrefix, envVarName, ok)
+			if err != nil && !cl.help {
+				return fmt.Errorf("%s env: %w", field.Name, err)
+			}
+
+			if negated {
+				envVarValue, ok = value, true
+			}
 		}
 
-		return nil
-	}
+		source := "env"
+		if !ok && cl.secretsDir != "" {
+			value, found, err := cl.lookupSecretFile(envVarName)
+			if err != nil {
+				return fmt.Errorf("%s secret: %w", field.Name, err)
+			}
 
-	switch value.Kind() { //nolint:exhaustive
-	case reflect.Int, reflect.Int64:
-		limit, err := strconv.ParseInt(min, 10, 64) //nolint:gomnd
-		if err != nil {
-			return fmt.Errorf("%s min: parsing int %q: %w", field.Name, min, err)
+			if found {
+				envVarValue, ok, source = value, true, "secret"
+			}
 		}
 
-		got := value.Int()
-		if got < limit {
-			return fmt.Errorf("%s min: value %d must be >= %d", field.Name, got, limit)
-		}
-	case reflect.Uint, reflect.Uint64:
-		limit, err := strconv.ParseUint(min, 10, 64) //nolint:gomnd
-		if err != nil {
-			return fmt.Errorf("%s min: parsing uint %q: %w", field.Name, min, err)
+		_, inFile := cl.fileFields[flagName]
+		if err := cl.parseRequired(field, flagName, envVarName, ok || inFile); err != nil {
+			return err
 		}
 
-		got := value.Uint()
-		if got < limit {
-			return fmt.Errorf("%s min: value %d must be >= %d", field.Name, got, limit)
-		}
-	case reflect.Float64:
-		limit, err := strconv.ParseFloat(min, 64) //nolint:gomnd
-		if err != nil {
-			return fmt.Errorf("%s min: parsing float %q: %w", field.Name, min, err)
-		}
+		if ok && !cl.help {
+			if err := cl.validateFlagName(flagName); err != nil {
+				return fmt.Errorf("%s %s: %w", field.Name, source, err)
+			}
 
-		got := value.Float()
-		if math.IsNaN(got) {
-			return fmt.Errorf("%s min: value NaN must be a number", field.Name)
-		}
+			if _, indirect := field.Tag.Lookup("env-indirect"); indirect && source == "env" {
+				value, err := cl.lookupIndirectEnv(envVarName, envVarValue)
+				if err != nil {
+					return fmt.Errorf("%s env: %w", field.Name, err)
+				}
 
-		if got < limit {
-			return fmt.Errorf("%s min: value %g must be >= %g", field.Name, got, limit)
-		}
-	default:
-		return fmt.Errorf("%s min: unsupported type %s", field.Name, value.Type())
-	}
+				envVarValue = value
+			}
 
-	return nil
-}
+			if err := cl.parseEnvValue(field, p, flagName, envVarValue, usage); err != nil {
+				if cl.strictEnv && source == "env" {
+					cl.envErrors = append(cl.envErrors, fmt.Errorf("%s %s: %w", field.Name, source, err))
 
-func compareMaximum(field reflect.StructField, value reflect.Value, max string) error { //nolint:cyclop
-	if value.Type() == durationType {
-		limit, err := time.ParseDuration(max)
-		if err != nil {
-			return fmt.Errorf("%s max: parsing duration %q: %w", field.Name, max, err)
-		}
+					continue
+				}
 
-		got := value.Interface().(time.Duration)
-		if got > limit {
-			return fmt.Errorf("%s max: value %s must be <= %s", field.Name, got, limit)
-		}
+				return fmt.Errorf("%s %s: %w", field.Name, source, err)
+			}
 
-		return nil
-	}
+			cl.sources[flagName] = source
 
-	switch value.Kind() { //nolint:exhaustive
-	case reflect.Int, reflect.Int64:
-		limit, err := strconv.ParseInt(max, 10, 64) //nolint:gomnd
-		if err != nil {
-			return fmt.Errorf("%s max: parsing int %q: %w", field.Name, max, err)
+			continue
 		}
 
-		got := value.Int()
-		if got > limit {
-			return fmt.Errorf("%s max: value %d must be <= %d", field.Name, got, limit)
-		}
-	case reflect.Uint, reflect.Uint64:
-		limit, err := strconv.ParseUint(max, 10, 64) //nolint:gomnd
-		if err != nil {
-			return fmt.Errorf("%s max: parsing uint %q: %w", field.Name, max, err)
+		if err := cl.validateFlagName(flagName); err != nil {
+			return fmt.Errorf("%s def: %w", field.Name, err)
 		}
 
-		got := value.Uint()
-		if got > limit {
-			return fmt.Errorf("%s max: value %d must be <= %d", field.Name, got, limit)
+		if _, ok := cl.fileFields[flagName]; ok {
+			fileValue := reflect.New(field.Type).Elem()
+			fileValue.Set(fieldValue)
+
+			if err := cl.parseValue(field.Type.Kind(), p, flagName, "", usage, separator(field)); err != nil {
+				return fmt.Errorf("%s file: %w", field.Name, err)
+			}
+
+			fieldValue.Set(fileValue)
+			cl.sources[flagName] = "file"
+
+			continue
 		}
-	case reflect.Float64:
-		limit, err := strconv.ParseFloat(max, 64) //nolint:gomnd
+
+		def, err := defaultValue(field)
 		if err != nil {
-			return fmt.Errorf("%s max: parsing float %q: %w", field.Name, max, err)
+			return fmt.Errorf("%s default-func: %w", field.Name, err)
 		}
 
-		got := value.Float()
-		if math.IsNaN(got) {
-			return fmt.Errorf("%s max: value NaN must be a number", field.Name)
+		if key, ok := field.Tag.Lookup("buildinfo"); ok {
+			if value, found := cl.buildSetting(key); found {
+				def = value
+			}
 		}
 
-		if got > limit {
-			return fmt.Errorf("%s max: value %g must be <= %g", field.Name, got, limit)
+		spec := cl.specs[flagName]
+		spec.def = def
+		cl.specs[flagName] = spec
+
+		if err := cl.parseValue(field.Type.Kind(), p, flagName, def, usage, separator(field)); err != nil {
+			return fmt.Errorf("%s def: %w", field.Name, err)
 		}
-	default:
-		return fmt.Errorf("%s max: unsupported type %s", field.Name, value.Type())
+
+		cl.sources[flagName] = "default"
 	}
 
 	return nil
 }
 
-func validateOneOf(field reflect.StructField, value reflect.Value) error {
-	tag, ok := field.Tag.Lookup("oneof")
-	if !ok {
-		return nil
+// parseEnvValue parses value of environment variable or secret file. Unlike empty default, empty
+// value of bool field is an error instead of false, as the variable is set.
+func (cl *commandLine) parseEnvValue(field reflect.StructField, p any, flagName, value, usage string) error {
+	if value == "" && field.Type.Kind() == reflect.Bool {
+		return fmt.Errorf("parsing bool %q: %w", value, strconv.ErrSyntax)
 	}
 
-	allowed, err := requireTagList(field, "oneof", tag)
-	if err != nil {
-		return err
-	}
+	return cl.parseValue(field.Type.Kind(), p, flagName, value, usage, separator(field))
+}
 
-	got := validationString(value)
-	if slices.Contains(allowed, got) {
-		return nil
-	}
+// lookupIndexedEnv collects values of environment variables name_0, name_1 and so on, up to the
+// first missing index, and joins them with sep.
+func (cl *commandLine) lookupIndexedEnv(name, sep string) (string, bool, error) {
+	var values []string
 
-	return fmt.Errorf("%s oneof: value %q must be one of %s", field.Name, got, strings.Join(allowed, ", "))
-}
+	for i := 0; ; i++ {
+		indexed := name + "_" + strconv.Itoa(i)
 
-func validateLengths(field reflect.StructField, value reflect.Value) error {
-	for _, tag := range []string{"len", "minlen", "maxlen"} {
-		limit, ok, err := validationLength(field, tag)
-		if err != nil {
-			return err
+		value, ok := cl.lookupEnvFunc(indexed)
+		if !ok {
+			break
 		}
 
-		if !ok {
-			continue
+		if strings.Contains(value, sep) {
+			return "", false, fmt.Errorf("%s contains separator %q", indexed, sep)
 		}
 
-		got, ok := lengthValue(value)
+		values = append(values, value)
+	}
+
+	return strings.Join(values, sep), len(values) > 0, nil
+}
+
+// lookupIndirectEnv follows environment variables whose values name other
+// existing environment variables and returns the final value.
+func (cl *commandLine) lookupIndirectEnv(name, value string) (string, error) {
+	chain := []string{name}
+
+	for {
+		next, ok := cl.lookupEnvFunc(value)
 		if !ok {
-			return fmt.Errorf("%s %s: unsupported type %s", field.Name, tag, value.Type())
+			return value, nil
 		}
 
-		switch tag {
-		case "len":
-			if got != limit {
-				return fmt.Errorf("%s len: length %d must equal %d", field.Name, got, limit)
-			}
-		case "minlen":
-			if got < limit {
-				return fmt.Errorf("%s minlen: length %d must be >= %d", field.Name, got, limit)
-			}
-		case "maxlen":
-			if got > limit {
-				return fmt.Errorf("%s maxlen: length %d must be <= %d", field.Name, got, limit)
-			}
+		chain = append(chain, value)
+		if slices.Contains(chain[:len(chain)-1], value) {
+			return "", fmt.Errorf("env indirection cycle: %s", strings.Join(chain, " -> "))
 		}
-	}
 
-	return nil
+		value = next
+	}
 }
 
-func validationLength(field reflect.StructField, tag string) (int, bool, error) {
-	raw, ok := field.Tag.Lookup(tag)
-	if !ok {
-		return 0, false, nil
+// resolveVersion selects among top-level struct fields tagged with version
+// using the value of the string field tagged with an empty version tag. The
+// discriminator is resolved from flags, environment and default before any
+// other field is parsed.
+func (cl *commandLine) resolveVersion(config any, flags []string) error { //nolint:cyclop
+	v := reflect.ValueOf(config)
+	if !v.IsValid() || v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil
 	}
 
-	limit, err := strconv.Atoi(raw)
-	if err != nil {
-		return 0, true, fmt.Errorf("%s %s: parsing length %q: %w", field.Name, tag, raw, err)
-	}
+	t := v.Elem().Type()
+	versions := []string{}
 
-	return limit, true, nil
-}
+	var discriminator *reflect.StructField
 
-func lengthValue(value reflect.Value) (int, bool) {
-	switch value.Kind() { //nolint:exhaustive
-	case reflect.String:
-		return value.Len(), true
-	case reflect.Slice:
-		switch value.Interface().(type) {
-		case StringSlice, IntSlice:
-			return value.Len(), true
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		version, ok := field.Tag.Lookup("version")
+		switch {
+		case !ok:
+		case version == "" && field.Type.Kind() == reflect.String:
+			discriminator = &field
+		case version != "" && field.Type.Kind() == reflect.Struct:
+			versions = append(versions, version)
 		default:
-			return 0, false
+			return fmt.Errorf("%s version: unsupported type %s", field.Name, field.Type)
 		}
-	default:
-		return 0, false
 	}
-}
 
-func validateRegex(field reflect.StructField, value reflect.Value) error {
-	pattern, ok := field.Tag.Lookup("regex")
-	if !ok {
+	if len(versions) == 0 {
 		return nil
 	}
 
-	if value.Kind() != reflect.String {
-		return fmt.Errorf("%s regex: unsupported type %s", field.Name, value.Type())
+	if discriminator == nil {
+		return errors.New("version: missing string field tagged with empty version tag")
 	}
 
-	re, err := regexp.Compile(pattern)
-	if err != nil {
-		return fmt.Errorf("%s regex: compiling %q: %w", field.Name, pattern, err)
+	flagName := cl.flagName(*discriminator, "")
+	value, ok := lookupFlag(flags, flagName)
+	if !ok {
+		value, ok = cl.lookupEnvFunc(cl.envVarName(*discriminator, cl.name))
+	}
+	if !ok {
+		value = discriminator.Tag.Get("def")
 	}
 
-	got := value.String()
-	if !re.MatchString(got) {
-		return fmt.Errorf("%s regex: value %q must match %s", field.Name, got, pattern)
+	if !slices.Contains(versions, value) {
+		if cl.help {
+			return nil
+		}
+
+		slices.Sort(versions)
+
+		return fmt.Errorf(
+			"%s version: unknown config version %q; supported versions: %s",
+			discriminator.Name,
+			value,
+			strings.Join(versions, ", "),
+		)
 	}
 
+	cl.version = value
+
 	return nil
 }
 
-func validatePrefixSuffix(field reflect.StructField, value reflect.Value) error {
-	got, ok := stringLikeValue(value)
-
-	if tag, present := field.Tag.Lookup("prefix"); present {
-		if !ok {
-			return fmt.Errorf("%s prefix: unsupported type %s", field.Name, value.Type())
-		}
+// lookupFlag returns the last value of the named flag found in flags.
+func lookupFlag(flags []string, name string) (string, bool) {
+	value, found := "", false
 
-		prefixes, err := requireTagList(field, "prefix", tag)
-		if err != nil {
-			return err
+	for i := 0; i < len(flags); i++ {
+		arg := flags[i]
+		if arg == "--" {
+			break
 		}
 
-		if !hasAnyPrefix(got, prefixes) {
-			return fmt.Errorf("%s prefix: value %q must start with one of %s", field.Name, got, strings.Join(prefixes, ", "))
+		trimmed := strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		if trimmed == arg {
+			continue
 		}
-	}
 
-	if tag, present := field.Tag.Lookup("suffix"); present {
-		if !ok {
-			return fmt.Errorf("%s suffix: unsupported type %s", field.Name, value.Type())
-		}
+		if v, ok := strings.CutPrefix(trimmed, name+"="); ok {
+			value, found = v, true
 
-		suffixes, err := requireTagList(field, "suffix", tag)
-		if err != nil {
-			return err
+			continue
 		}
 
-		if !hasAnySuffix(got, suffixes) {
-			return fmt.Errorf("%s suffix: value %q must end with one of %s", field.Name, got, strings.Join(suffixes, ", "))
+		if trimmed == name && i+1 < len(flags) {
+			i++
+			value, found = flags[i], true
 		}
 	}
 
-	return nil
+	return value, found
 }
 
-func stringLikeValue(value reflect.Value) (string, bool) {
-	if value.Kind() == reflect.String {
-		return value.String(), true
+// lookupSecretFile reads trimmed content of the file named after the
+// environment variable in the secrets directory, if the file exists.
+func (cl *commandLine) lookupSecretFile(envName string) (string, bool, error) {
+	if !filepath.IsLocal(envName) {
+		return "", false, nil
 	}
 
-	if value.CanAddr() {
-		if v, ok := value.Addr().Interface().(*URL); ok {
-			return v.String(), true
-		}
+	b, err := os.ReadFile(filepath.Join(cl.secretsDir, envName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", false, nil
 	}
 
-	return "", false
-}
-
-func hasAnyPrefix(value string, prefixes []string) bool {
-	for _, prefix := range prefixes {
-		if strings.HasPrefix(value, prefix) {
-			return true
-		}
+	if err != nil {
+		return "", false, fmt.Errorf("reading secret file: %w", err)
 	}
 
-	return false
+	return strings.TrimSpace(string(b)), true, nil
 }
 
-func hasAnySuffix(value string, suffixes []string) bool {
-	for _, suffix := range suffixes {
-		if strings.HasSuffix(value, suffix) {
-			return true
-		}
+// lookupNegatedEnv looks up the NO_ prefixed environment variable of a bool
+// field and returns the negated value if it is set.
+func (cl *commandLine) lookupNegatedEnv(
+	field reflect.StructField,
+	envPrefix string,
+	envName string,
+	envSet bool,
+) (string, bool, error) {
+	negatedName := cl.negatedEnvVarName(field, envPrefix)
+
+	value, ok := cl.lookupEnvFunc(negatedName)
+	if !ok {
+		return "", false, nil
 	}
 
-	return false
-}
-
-func validateNonzero(field reflect.StructField, value reflect.Value) error {
-	if _, ok := field.Tag.Lookup("nonzero"); !ok {
-		return nil
+	if envSet {
+		return "", false, fmt.Errorf("conflicting %s and %s", envName, negatedName)
 	}
 
-	if value.CanAddr() {
-		switch v := value.Addr().Interface().(type) {
-		case *URL:
-			if v == nil || v.URL == nil || v.String() == "" {
-				return fmt.Errorf("%s nonzero: value must not be zero", field.Name)
-			}
+	val, err := strconv.ParseBool(value)
+	if err != nil {
+		return "", false, fmt.Errorf("parsing bool %q: %w", value, err)
+	}
 
-			return nil
-		case *Time:
-			if v == nil || v.Time == nil || v.IsZero() {
-				return fmt.Errorf("%s nonzero: value must not be zero", field.Name)
-			}
+	return strconv.FormatBool(!val), true, nil
+}
 
+// parseRequired records field tagged with req, or its alias required, unless its value is already
+// supplied by environment variable, secret or config file.
+func (cl *commandLine) parseRequired(field reflect.StructField, flagName string, envName string, supplied bool) error {
+	tag := "req"
+	if _, ok := field.Tag.Lookup(tag); !ok {
+		tag = "required"
+		if _, ok := field.Tag.Lookup(tag); !ok {
 			return nil
 		}
 	}
 
-	if value.IsZero() {
-		return fmt.Errorf("%s nonzero: value must not be zero", field.Name)
+	if hasDefault(field) {
+		return fmt.Errorf("%s %s: cannot combine %s and def tags", field.Name, tag, tag)
+	}
+
+	if supplied && !cl.help {
+		return nil
+	}
+
+	cl.required = append(cl.required, requiredField{
+		fieldName: field.Name,
+		tag:       tag,
+		flagName:  flagName,
+		envName:   envName,
+	})
+
+	return nil
+}
+
+// registerShorts registers short flags sharing the value of the flags they alias. Short flags of
+// secret fields are secret as well.
+func (cl *commandLine) registerShorts() error {
+	shorts := slices.Sorted(maps.Keys(cl.shorts))
+
+	for _, short := range shorts {
+		sf := cl.shorts[short]
+
+		f := cl.flagSet.Lookup(sf.flagName)
+		if f == nil {
+			continue
+		}
+
+		if err := cl.validateFlagName(short); err != nil {
+			return fmt.Errorf("%s short: %w", sf.fieldName, err)
+		}
+
+		cl.flagSet.Var(f.Value, short, "short for -"+sf.flagName)
+		cl.flagSet.Lookup(short).DefValue = f.DefValue
+
+		if _, secret := cl.secrets[sf.flagName]; secret {
+			cl.secrets[short] = struct{}{}
+		}
+
+		if spec, ok := cl.specs[sf.flagName]; ok {
+			spec.Short = short
+			cl.specs[sf.flagName] = spec
+		}
+	}
+
+	return nil
+}
+
+// setFlags returns names of flags set on the command line, with short flags replaced by the
+// flags they alias.
+func (cl *commandLine) setFlags() map[string]struct{} {
+	set := map[string]struct{}{}
+
+	cl.flagSet.Visit(func(f *flag.Flag) {
+		name := f.Name
+		if sf, ok := cl.shorts[name]; ok {
+			name = sf.flagName
+		}
+
+		set[name] = struct{}{}
+	})
+
+	return set
+}
+
+// validateRequired reports all required fields not supplied by any source as a single error
+// matching ErrMissingRequired.
+func (cl *commandLine) validateRequired() error {
+	if cl.help {
+		return nil
+	}
+
+	setFlags := cl.setFlags()
+
+	var errs missingErrors
+
+	for _, field := range cl.required {
+		if _, ok := setFlags[field.flagName]; ok {
+			continue
+		}
+
+		errs = append(errs, fmt.Errorf(
+			"%s %s: required value missing; set %s or -%s",
+			field.fieldName,
+			field.tag,
+			field.envName,
+			field.flagName,
+		))
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// missingErrors reports all missing required values as a single error matching
+// ErrMissingRequired.
+type missingErrors []error
+
+func (e missingErrors) Error() string {
+	return validationErrors(e).Error()
+}
+
+func (e missingErrors) Unwrap() []error {
+	return append([]error{ErrMissingRequired}, e...)
+}
+
+func (cl *commandLine) validate(config any) error {
+	if cl.help {
+		return nil
+	}
+
+	v := reflect.ValueOf(config)
+	if !v.IsValid() || v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return ErrInvalidConfigType
+	}
+
+	if errs := cl.validateStruct(v.Elem()); len(errs) > 0 {
+		return validationErrors(errs)
+	}
+
+	return nil
+}
+
+// validationErrors reports violations of validation tags of all fields as a single error matching
+// ErrValidation, so misconfiguration is reported at once instead of one field per run.
+type validationErrors []error
+
+func (e validationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+func (e validationErrors) Unwrap() []error {
+	return append([]error{ErrValidation}, e...)
+}
+
+// validateStruct returns the first violated validation tag of each field.
+func (cl *commandLine) validateStruct(v reflect.Value) []error {
+	var errs []error
+
+	t := v.Type()
+	for i := 0; i < v.NumField(); i++ {
+		field := t.Field(i)
+		value := v.Field(i)
+		if ignoredField(field) {
+			continue
+		}
+
+		if version := field.Tag.Get("version"); version != "" && version != cl.version {
+			continue
+		}
+
+		if _, ref := field.Tag.Lookup("ref"); ref {
+			continue
+		}
+
+		if value.Kind() == reflect.Struct && !isSpecialStructValue(value) {
+			errs = append(errs, cl.validateStruct(value)...)
+
+			continue
+		}
+
+		if err := validateEquals(field, value, v); err != nil {
+			errs = append(errs, err)
+
+			continue
+		}
+
+		if value.Kind() == reflect.Pointer {
+			if value.IsNil() {
+				if err := validateNonzero(field, value); err != nil {
+					errs = append(errs, err)
+				}
+
+				continue
+			}
+
+			value = value.Elem()
+		}
+
+		if err := cl.validateField(field, value); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+func validateEquals(field reflect.StructField, value reflect.Value, parent reflect.Value) error {
+	name, ok := field.Tag.Lookup("equals")
+	if !ok {
+		return nil
+	}
+
+	other := parent.FieldByName(name)
+	if !other.IsValid() {
+		return fmt.Errorf("%s equals: unknown field %q", field.Name, name)
+	}
+
+	if !reflect.DeepEqual(value.Interface(), other.Interface()) {
+		return fmt.Errorf("%s equals: value must equal %s", field.Name, name)
+	}
+
+	return nil
+}
+
+// refField returns the field named name of the struct v, referenced by field tagged with ref. The
+// referenced field must be of the same type and must not be a reference itself.
+func refField(v reflect.Value, field reflect.StructField, name string) (reflect.Value, error) {
+	src, ok := v.Type().FieldByName(name)
+	if !ok || ignoredField(src) {
+		return reflect.Value{}, fmt.Errorf("%s ref: unknown field %q", field.Name, name)
+	}
+
+	if _, ref := src.Tag.Lookup("ref"); ref {
+		return reflect.Value{}, fmt.Errorf("%s ref: field %q is a reference", field.Name, name)
+	}
+
+	if src.Type != field.Type {
+		return reflect.Value{}, fmt.Errorf("%s ref: type %s does not match %s of %s", field.Name, field.Type, src.Type, name)
+	}
+
+	return v.FieldByIndex(src.Index), nil
+}
+
+// resolveRefs sets fields tagged with ref to the parsed values of the referenced fields.
+func (cl *commandLine) resolveRefs() {
+	for _, ref := range cl.refs {
+		ref.value.Set(ref.src)
+	}
+}
+
+// ignoredField reports whether the field is unexported or tagged with bee:"-", i.e. runtime state
+// kept in the config struct, and should not be parsed, validated or logged.
+func ignoredField(field reflect.StructField) bool {
+	return field.PkgPath != "" || field.Tag.Get("bee") == "-"
+}
+
+func isSpecialStructType(t reflect.Type) bool {
+	switch t {
+	case reflect.TypeFor[URL](), reflect.TypeFor[Time](), reflect.TypeFor[time.Time](),
+		reflect.TypeFor[net.IPNet](), reflect.TypeFor[netip.Prefix]():
+		return true
+	default:
+		return false
+	}
+}
+
+func isSpecialStructValue(v reflect.Value) bool {
+	if !v.CanAddr() {
+		return false
+	}
+
+	switch v.Addr().Interface().(type) {
+	case *URL, *Time, *time.Time, *net.IPNet, *netip.Prefix:
+		return true
+	default:
+		return false
+	}
+}
+
+func (cl *commandLine) validateField(field reflect.StructField, value reflect.Value) error {
+	if err := validateMinMax(field, value); err != nil {
+		return err
+	}
+
+	if err := validateOneOf(field, value); err != nil {
+		return err
+	}
+
+	if err := validateLengths(field, value); err != nil {
+		return err
+	}
+
+	if err := validateRegex(field, value); err != nil {
+		return err
+	}
+
+	if err := validatePrefixSuffix(field, value); err != nil {
+		return err
+	}
+
+	if err := validateNonzero(field, value); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func validateMinMax(field reflect.StructField, value reflect.Value) error {
+	if min := field.Tag.Get("min"); min != "" {
+		if err := compareMinimum(field, value, min); err != nil {
+			return err
+		}
+	}
+
+	if max := field.Tag.Get("max"); max != "" {
+		if err := compareMaximum(field, value, max); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func compareMinimum(field reflect.StructField, value reflect.Value, min string) error { //nolint:cyclop
+	if value.Type() == durationType {
+		limit, err := time.ParseDuration(min)
+		if err != nil {
+			return fmt.Errorf("%s min: parsing duration %q: %w", field.Name, min, err)
+		}
+
+		got := value.Interface().(time.Duration)
+		if got < limit {
+			return fmt.Errorf("%s min: value %s must be >= %s", field.Name, got, limit)
+		}
+
+		return nil
+	}
+
+	switch value.Kind() { //nolint:exhaustive
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		limit, err := strconv.ParseInt(min, 10, 64) //nolint:gomnd
+		if err != nil {
+			return fmt.Errorf("%s min: parsing int %q: %w", field.Name, min, err)
+		}
+
+		got := value.Int()
+		if got < limit {
+			return fmt.Errorf("%s min: value %d must be >= %d", field.Name, got, limit)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		limit, err := strconv.ParseUint(min, 10, 64) //nolint:gomnd
+		if err != nil {
+			return fmt.Errorf("%s min: parsing uint %q: %w", field.Name, min, err)
+		}
+
+		got := value.Uint()
+		if got < limit {
+			return fmt.Errorf("%s min: value %d must be >= %d", field.Name, got, limit)
+		}
+	case reflect.Float32, reflect.Float64:
+		limit, err := strconv.ParseFloat(min, 64) //nolint:gomnd
+		if err != nil {
+			return fmt.Errorf("%s min: parsing float %q: %w", field.Name, min, err)
+		}
+
+		got := value.Float()
+		if math.IsNaN(got) {
+			return fmt.Errorf("%s min: value NaN must be a number", field.Name)
+		}
+
+		if got < limit {
+			return fmt.Errorf("%s min: value %g must be >= %g", field.Name, got, limit)
+		}
+	default:
+		return fmt.Errorf("%s min: unsupported type %s", field.Name, value.Type())
+	}
+
+	return nil
+}
+
+func compareMaximum(field reflect.StructField, value reflect.Value, max string) error { //nolint:cyclop
+	if value.Type() == durationType {
+		limit, err := time.ParseDuration(max)
+		if err != nil {
+			return fmt.Errorf("%s max: parsing duration %q: %w", field.Name, max, err)
+		}
+
+		got := value.Interface().(time.Duration)
+		if got > limit {
+			return fmt.Errorf("%s max: value %s must be <= %s", field.Name, got, limit)
+		}
+
+		return nil
+	}
+
+	switch value.Kind() { //nolint:exhaustive
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		limit, err := strconv.ParseInt(max, 10, 64) //nolint:gomnd
+		if err != nil {
+			return fmt.Errorf("%s max: parsing int %q: %w", field.Name, max, err)
+		}
+
+		got := value.Int()
+		if got > limit {
+			return fmt.Errorf("%s max: value %d must be <= %d", field.Name, got, limit)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		limit, err := strconv.ParseUint(max, 10, 64) //nolint:gomnd
+		if err != nil {
+			return fmt.Errorf("%s max: parsing uint %q: %w", field.Name, max, err)
+		}
+
+		got := value.Uint()
+		if got > limit {
+			return fmt.Errorf("%s max: value %d must be <= %d", field.Name, got, limit)
+		}
+	case reflect.Float32, reflect.Float64:
+		limit, err := strconv.ParseFloat(max, 64) //nolint:gomnd
+		if err != nil {
+			return fmt.Errorf("%s max: parsing float %q: %w", field.Name, max, err)
+		}
+
+		got := value.Float()
+		if math.IsNaN(got) {
+			return fmt.Errorf("%s max: value NaN must be a number", field.Name)
+		}
+
+		if got > limit {
+			return fmt.Errorf("%s max: value %g must be <= %g", field.Name, got, limit)
+		}
+	default:
+		return fmt.Errorf("%s max: unsupported type %s", field.Name, value.Type())
+	}
+
+	return nil
+}
+
+func validateOneOf(field reflect.StructField, value reflect.Value) error {
+	tag, ok := field.Tag.Lookup("oneof")
+	if !ok {
+		return nil
+	}
+
+	allowed, err := requireTagList(field, "oneof", tag)
+	if err != nil {
+		return err
+	}
+
+	got := validationString(value)
+	if slices.Contains(allowed, got) {
+		return nil
+	}
+
+	return fmt.Errorf("%s oneof: value %q must be one of %s", field.Name, got, strings.Join(allowed, ", "))
+}
+
+func validateLengths(field reflect.StructField, value reflect.Value) error {
+	for _, tag := range []string{"len", "minlen", "maxlen"} {
+		limit, ok, err := validationLength(field, tag)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			continue
+		}
+
+		got, ok := lengthValue(value)
+		if !ok {
+			return fmt.Errorf("%s %s: unsupported type %s", field.Name, tag, value.Type())
+		}
+
+		switch tag {
+		case "len":
+			if got != limit {
+				return fmt.Errorf("%s len: length %d must equal %d", field.Name, got, limit)
+			}
+		case "minlen":
+			if got < limit {
+				return fmt.Errorf("%s minlen: length %d must be >= %d", field.Name, got, limit)
+			}
+		case "maxlen":
+			if got > limit {
+				return fmt.Errorf("%s maxlen: length %d must be <= %d", field.Name, got, limit)
+			}
+		}
+	}
+
+	return nil
+}
+
+func validationLength(field reflect.StructField, tag string) (int, bool, error) {
+	raw, ok := field.Tag.Lookup(tag)
+	if !ok {
+		return 0, false, nil
+	}
+
+	limit, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, true, fmt.Errorf("%s %s: parsing length %q: %w", field.Name, tag, raw, err)
+	}
+
+	return limit, true, nil
+}
+
+func lengthValue(value reflect.Value) (int, bool) {
+	switch value.Kind() { //nolint:exhaustive
+	case reflect.String:
+		return value.Len(), true
+	case reflect.Slice:
+		switch value.Interface().(type) {
+		case StringSlice, IntSlice, Float64Slice, BoolSlice, DurationSlice, TimeSlice, []time.Time:
+			return value.Len(), true
+		default:
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+}
+
+func validateRegex(field reflect.StructField, value reflect.Value) error {
+	pattern, ok := field.Tag.Lookup("regex")
+	if !ok {
+		return nil
+	}
+
+	if value.Kind() != reflect.String {
+		return fmt.Errorf("%s regex: unsupported type %s", field.Name, value.Type())
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("%s regex: compiling %q: %w", field.Name, pattern, err)
+	}
+
+	got := value.String()
+	if !re.MatchString(got) {
+		return fmt.Errorf("%s regex: value %q must match %s", field.Name, got, pattern)
+	}
+
+	return nil
+}
+
+func validatePrefixSuffix(field reflect.StructField, value reflect.Value) error {
+	got, ok := stringLikeValue(value)
+
+	if tag, present := field.Tag.Lookup("prefix"); present {
+		if !ok {
+			return fmt.Errorf("%s prefix: unsupported type %s", field.Name, value.Type())
+		}
+
+		prefixes, err := requireTagList(field, "prefix", tag)
+		if err != nil {
+			return err
+		}
+
+		if !hasAnyPrefix(got, prefixes) {
+			return fmt.Errorf("%s prefix: value %q must start with one of %s", field.Name, got, strings.Join(prefixes, ", "))
+		}
+	}
+
+	if tag, present := field.Tag.Lookup("suffix"); present {
+		if !ok {
+			return fmt.Errorf("%s suffix: unsupported type %s", field.Name, value.Type())
+		}
+
+		suffixes, err := requireTagList(field, "suffix", tag)
+		if err != nil {
+			return err
+		}
+
+		if !hasAnySuffix(got, suffixes) {
+			return fmt.Errorf("%s suffix: value %q must end with one of %s", field.Name, got, strings.Join(suffixes, ", "))
+		}
+	}
+
+	return nil
+}
+
+func stringLikeValue(value reflect.Value) (string, bool) {
+	if value.Kind() == reflect.String {
+		return value.String(), true
+	}
+
+	if value.CanAddr() {
+		if v, ok := value.Addr().Interface().(*URL); ok {
+			return v.String(), true
+		}
+	}
+
+	return "", false
+}
+
+func hasAnyPrefix(value string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasAnySuffix(value string, suffixes []string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(value, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func validateNonzero(field reflect.StructField, value reflect.Value) error {
+	if _, ok := field.Tag.Lookup("nonzero"); !ok {
+		return nil
+	}
+
+	if value.CanAddr() {
+		switch v := value.Addr().Interface().(type) {
+		case *URL:
+			if v == nil || v.URL == nil || v.String() == "" {
+				return fmt.Errorf("%s nonzero: value must not be zero", field.Name)
+			}
+
+			return nil
+		case *Time:
+			if v == nil || v.Time == nil || v.IsZero() {
+				return fmt.Errorf("%s nonzero: value must not be zero", field.Name)
+			}
+
+			return nil
+		}
+	}
+
+	if value.IsZero() {
+		return fmt.Errorf("%s nonzero: value must not be zero", field.Name)
 	}
 
 	return nil
@@ -625,357 +1515,784 @@ func requireTagList(field reflect.StructField, tagName string, raw string) ([]st
 		return nil, fmt.Errorf("%s %s: empty value list", field.Name, tagName)
 	}
 
-	return values, nil
+	return values, nil
+}
+
+func splitTagList(tag string) []string {
+	parts := strings.Split(tag, ",")
+	values := make([]string, 0, len(parts))
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			values = append(values, part)
+		}
+	}
+
+	return values
+}
+
+func validationString(value reflect.Value) string {
+	if value.Type() == durationType {
+		return value.Interface().(time.Duration).String()
+	}
+
+	if value.CanAddr() {
+		switch v := value.Addr().Interface().(type) {
+		case *URL:
+			return v.String()
+		case *Time:
+			return v.String()
+		case *time.Time:
+			return v.Format(time.RFC3339)
+		}
+	}
+
+	switch value.Kind() { //nolint:exhaustive
+	case reflect.String:
+		return value.String()
+	case reflect.Bool:
+		return strconv.FormatBool(value.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(value.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(value.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(value.Float(), 'g', -1, value.Type().Bits())
+	default:
+		return fmt.Sprint(value.Interface())
+	}
+}
+
+func (cl *commandLine) validateFlagName(flagName string) error {
+	if cl.flagSet.Lookup(flagName) != nil {
+		return fmt.Errorf("duplicate flag %q: %w", flagName, ErrInvalidConfigType)
+	}
+
+	return nil
+}
+
+func (*commandLine) flagName(sf reflect.StructField, prefix string) string {
+	if f := sf.Tag.Get("flag"); f != "" {
+		return f
+	}
+
+	n := sf.Name
+
+	if prefix != "" {
+		n = fmt.Sprintf("%s-%s", prefix, n)
+	}
+
+	return strcase.ToKebab(n)
+}
+
+func (cl *commandLine) envVarName(sf reflect.StructField, envPrefix string) string {
+	return cl.envVarNames(sf, envPrefix)[0]
+}
+
+// envVarNames returns environment variable names of the field. The env tag may list several
+// comma separated names, i.e. `env:"DB_URL,LEGACY_DATABASE_URL"`, looked up in order.
+func (*commandLine) envVarNames(sf reflect.StructField, envPrefix string) []string {
+	if e := sf.Tag.Get("env"); e != "" {
+		names := strings.Split(e, ",")
+		for i := range names {
+			names[i] = strings.TrimSpace(names[i])
+		}
+
+		return names
+	}
+
+	if e := sf.Tag.Get("shared-env"); e != "" {
+		return []string{e}
+	}
+
+	return []string{strcase.ToScreamingSnake(fmt.Sprintf("%s_%s", envPrefix, sf.Name))}
+}
+
+// lookupEnvNames returns the name and the value of the first set environment variable of names,
+// or the first name if none is set.
+func (cl *commandLine) lookupEnvNames(names []string) (string, string, bool) {
+	for _, name := range names {
+		if value, ok := cl.lookupEnvFunc(name); ok {
+			return name, value, true
+		}
+	}
+
+	return names[0], "", false
+}
+
+func (cl *commandLine) negatedEnvVarName(sf reflect.StructField, envPrefix string) string {
+	if sf.Tag.Get("env") != "" {
+		return "NO_" + cl.envVarName(sf, envPrefix)
+	}
+
+	if e := sf.Tag.Get("shared-env"); e != "" {
+		return "NO_" + e
+	}
+
+	sf.Name = "No" + sf.Name
+
+	return cl.envVarName(sf, envPrefix)
+}
+
+func (cl *commandLine) usage(sf reflect.StructField, env string, prefix string) string {
+	kind := "env"
+	if sf.Tag.Get("env") == "" && sf.Tag.Get("shared-env") != "" {
+		kind = "shared env"
+	}
+
+	return fmt.Sprintf("%s (%s %s)", cl.description(sf, prefix), kind, env)
+}
+
+// description returns help tag of the field or description generated from the field name.
+func (*commandLine) description(sf reflect.StructField, prefix string) string {
+	if u := sf.Tag.Get("help"); u != "" {
+		return u
+	}
+
+	n := sf.Name
+	if prefix != "" {
+		n = fmt.Sprintf("%s %s", prefix, sf.Name)
+	}
+
+	return strcase.ToDelimited(n, ' ')
+}
+
+func (cl *commandLine) parseHelp(flags []string) {
+	if len(flags) == 0 {
+		return
+	}
+
+	for _, f := range flags {
+		if f == "--" {
+			return
+		}
+
+		if f == "--help" || f == "-help" || f == "--h" || f == "-h" {
+			cl.help = true
+
+			return
+		}
+	}
+}
+
+// newEnvPrefix returns environment variables prefix of nested struct fields. The env-prefix
+// tag replaces the inherited prefix for the whole subtree. Fields of embedded structs are promoted,
+// so they inherit the prefix unchanged.
+func (*commandLine) newEnvPrefix(sf reflect.StructField, envPrefix string) string {
+	if p := sf.Tag.Get("env-prefix"); p != "" {
+		return p
+	}
+
+	if sf.Anonymous {
+		return envPrefix
+	}
+
+	return fmt.Sprintf("%s_%s", envPrefix, sf.Name)
 }
 
-func splitTagList(tag string) []string {
-	parts := strings.Split(tag, ",")
-	values := make([]string, 0, len(parts))
-	for _, part := range parts {
-		part = strings.TrimSpace(part)
-		if part != "" {
-			values = append(values, part)
-		}
+func (*commandLine) newPrefix(sf reflect.StructField, prefix string) string {
+	if prefix != "" {
+		return fmt.Sprintf("%s-%s", prefix, sf.Name)
 	}
 
-	return values
+	return sf.Name
 }
 
-func validationString(value reflect.Value) string {
-	if value.Type() == durationType {
-		return value.Interface().(time.Duration).String()
+// separator returns the separator of slice elements set by sep tag, comma by default.
+func separator(field reflect.StructField) string {
+	if sep := field.Tag.Get("sep"); sep != "" {
+		return sep
 	}
 
-	if value.CanAddr() {
-		switch v := value.Addr().Interface().(type) {
+	return ","
+}
+
+func (cl *commandLine) parseValue(kind reflect.Kind, varPointer any, flag, value, usage, sep string) error { //nolint:cyclop,lll
+	switch kind { //nolint:exhaustive
+	case reflect.Bool:
+		return cl.parseBool(varPointer.(*bool), flag, value, usage) //nolint:forcetypeassert
+	case reflect.String:
+		cl.flagSet.StringVar(varPointer.(*string), flag, value, usage) //nolint:forcetypeassert
+
+		return nil
+	case reflect.Uint:
+		return cl.parseUint(varPointer.(*uint), flag, value, usage) //nolint:forcetypeassert
+	case reflect.Uint64:
+		return cl.parseUint64(varPointer.(*uint64), flag, value, usage) //nolint:forcetypeassert
+	case reflect.Int:
+		switch varPointer := varPointer.(type) {
+		case *Counter:
+			return cl.parseCounter(varPointer, flag, value, usage)
+		case *int:
+			return cl.parseInt(varPointer, flag, value, usage)
+		}
+	case reflect.Int64:
+		switch varPointer := varPointer.(type) {
+		case *time.Duration:
+			return cl.parseDuration(varPointer, flag, value, usage)
+		case *int64:
+			return cl.parseInt64(varPointer, flag, value, usage)
+		}
+	case reflect.Float64:
+		return cl.parseFloat64(varPointer.(*float64), flag, value, usage) //nolint:forcetypeassert
+	case reflect.Complex128:
+		switch varPointer := varPointer.(type) {
+		case *Complex:
+			return cl.parseComplex(varPointer, flag, value, usage)
+		case *complex128:
+			return cl.parseComplex((*Complex)(varPointer), flag, value, usage)
+		default:
+			return cl.parseSized(reflect.ValueOf(varPointer).Elem(), flag, value, usage)
+		}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Float32,
+		reflect.Complex64:
+		return cl.parseSized(reflect.ValueOf(varPointer).Elem(), flag, value, usage)
+	case reflect.Struct:
+		switch varPointer := varPointer.(type) {
 		case *URL:
-			return v.String()
+			return cl.parseURL(varPointer, flag, value, usage)
 		case *Time:
-			return v.String()
+			return cl.parseTime(varPointer, flag, value, usage)
+		case *time.Time:
+			return cl.parseStdTime(varPointer, flag, value, usage)
+		case *net.IPNet:
+			return cl.parseIPNet(varPointer, flag, value, usage)
+		case *netip.Prefix:
+			return cl.parsePrefix(varPointer, flag, value, usage)
+		}
+	case reflect.Slice:
+		switch varPointer := varPointer.(type) {
+		case *StringSlice:
+			return cl.parseStringSlice(varPointer, flag, value, usage, sep)
+		case *IntSlice:
+			return cl.parseIntSlice(varPointer, flag, value, usage, sep)
+		case *Float64Slice:
+			return cl.parseFloat64Slice(varPointer, flag, value, usage, sep)
+		case *BoolSlice:
+			return cl.parseBoolSlice(varPointer, flag, value, usage, sep)
+		case *DurationSlice:
+			return cl.parseDurationSlice(varPointer, flag, value, usage, sep)
+		case *TimeSlice:
+			return cl.parseTimeSlice(varPointer, flag, value, usage, sep)
+		case *[]time.Time:
+			return cl.parseTimeSlice((*TimeSlice)(varPointer), flag, value, usage, sep)
+		case *json.RawMessage:
+			return cl.parseRawJSON(varPointer, flag, value, usage)
+		case *net.IP:
+			return cl.parseIP(varPointer, flag, value, usage)
 		}
+
+		v := reflect.ValueOf(varPointer).Elem()
+		if parse, ok := lookupSliceParser(v.Type().Elem()); ok {
+			return cl.parseSlice(v, parse, flag, value, usage, sep)
+		}
+	case reflect.Map:
+		if varPointer, ok := varPointer.(*DurationMap); ok {
+			return cl.parseDurationMap(varPointer, flag, value, usage)
+		}
+	case reflect.Array:
+		return cl.parseArray(reflect.ValueOf(varPointer).Elem(), flag, value, usage, sep)
+	case reflect.Pointer:
+		return cl.parsePointer(reflect.ValueOf(varPointer).Elem(), flag, value, usage)
 	}
 
-	switch value.Kind() { //nolint:exhaustive
-	case reflect.String:
-		return value.String()
-	case reflect.Bool:
-		return strconv.FormatBool(value.Bool())
-	case reflect.Int, reflect.Int64:
-		return strconv.FormatInt(value.Int(), 10)
-	case reflect.Uint, reflect.Uint64:
-		return strconv.FormatUint(value.Uint(), 10)
-	case reflect.Float64:
-		return strconv.FormatFloat(value.Float(), 'g', -1, 64) //nolint:gomnd
-	default:
-		return fmt.Sprint(value.Interface())
+	return fmt.Errorf("parsing value: %w: %v", ErrUnsupportedType, kind)
+}
+
+func (cl *commandLine) parseBool(p *bool, flag, value, usage string) error {
+	if value == "" {
+		cl.flagSet.BoolVar(p, flag, false, usage)
+
+		return nil
+	}
+
+	val, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("parsing bool %q: %w", value, err)
+	}
+
+	cl.flagSet.BoolVar(p, flag, val, usage)
+
+	return nil
+}
+
+func (cl *commandLine) parseUint(p *uint, flag, value, usage string) error {
+	if value == "" {
+		cl.flagSet.UintVar(p, flag, 0, usage)
+
+		return nil
+	}
+
+	val, err := strconv.ParseUint(value, 0, 32) //nolint:gomnd
+	if err != nil {
+		return fmt.Errorf("parsing uint %q: %w", value, err)
 	}
+
+	cl.flagSet.UintVar(p, flag, uint(val), usage)
+
+	return nil
 }
 
-func (cl *commandLine) validateFlagName(flagName string) error {
-	if cl.flagSet.Lookup(flagName) != nil {
-		return fmt.Errorf("duplicate flag %q: %w", flagName, ErrInvalidConfigType)
+func (cl *commandLine) parseUint64(p *uint64, flag, value, usage string) error {
+	if value == "" {
+		cl.flagSet.Uint64Var(p, flag, 0, usage)
+
+		return nil
+	}
+
+	val, err := strconv.ParseUint(value, 0, 64) //nolint:gomnd
+	if err != nil {
+		return fmt.Errorf("parsing uint64 %q: %w", value, err)
+	}
+
+	cl.flagSet.Uint64Var(p, flag, val, usage)
+
+	return nil
+}
+
+func (cl *commandLine) parseInt(p *int, flag, value, usage string) error {
+	if value == "" {
+		cl.flagSet.IntVar(p, flag, 0, usage)
+
+		return nil
+	}
+
+	val, err := strconv.ParseInt(value, 0, strconv.IntSize)
+	if err != nil {
+		return fmt.Errorf("parsing int %q: %w", value, err)
+	}
+
+	cl.flagSet.IntVar(p, flag, int(val), usage)
+
+	return nil
+}
+
+func (cl *commandLine) parseCounter(p *Counter, flag, value, usage string) error {
+	*p = 0
+
+	if value != "" {
+		if err := p.Set(value); err != nil {
+			return err
+		}
+	}
+
+	cl.flagSet.Var(p, flag, usage)
+
+	return nil
+}
+
+func (cl *commandLine) parseComplex(p *Complex, flag, value, usage string) error {
+	*p = 0
+
+	if value != "" {
+		if err := p.Set(value); err != nil {
+			return err
+		}
+	}
+
+	cl.flagSet.Var(p, flag, usage)
+
+	return nil
+}
+
+func (cl *commandLine) parseInt64(p *int64, flag, value, usage string) error {
+	if value == "" {
+		cl.flagSet.Int64Var(p, flag, 0, usage)
+
+		return nil
+	}
+
+	val, err := strconv.ParseInt(value, 0, 64) //nolint:gomnd
+	if err != nil {
+		return fmt.Errorf("parsing int64 %q: %w", value, err)
+	}
+
+	cl.flagSet.Int64Var(p, flag, val, usage)
+
+	return nil
+}
+
+func (cl *commandLine) parseDuration(p *time.Duration, flag, value, usage string) error {
+	if value == "" {
+		cl.flagSet.DurationVar(p, flag, 0, usage)
+
+		return nil
+	}
+
+	val, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("parsing duration %q: %w", value, err)
+	}
+
+	cl.flagSet.DurationVar(p, flag, val, usage)
+
+	return nil
+}
+
+func (cl *commandLine) parseFloat64(p *float64, flag, value, usage string) error {
+	if value == "" {
+		cl.flagSet.Float64Var(p, flag, 0, usage)
+
+		return nil
+	}
+
+	val, err := strconv.ParseFloat(value, 64) //nolint:gomnd
+	if err != nil {
+		return fmt.Errorf("parsing float64 %q: %w", value, err)
+	}
+
+	cl.flagSet.Float64Var(p, flag, val, usage)
+
+	return nil
+}
+
+func (cl *commandLine) parseStringSlice(p *StringSlice, flag, value, usage, sep string) error {
+	if value == "" {
+		*p = StringSlice{}
+		cl.separatedVar(p, flag, usage, sep)
+
+		return nil
+	}
+
+	ss := &StringSlice{}
+
+	_ = ss.setSeparated(value, sep)
+
+	*p = *ss
+	cl.separatedVar(p, flag, usage, sep)
+
+	return nil
+}
+
+func (cl *commandLine) parseIntSlice(p *IntSlice, flag, value, usage, sep string) error {
+	if value == "" {
+		*p = IntSlice{}
+		cl.separatedVar(p, flag, usage, sep)
+
+		return nil
+	}
+
+	is := &IntSlice{}
+
+	if err := is.setSeparated(value, sep); err != nil {
+		return err
+	}
+
+	*p = *is
+	cl.separatedVar(p, flag, usage, sep)
+
+	return nil
+}
+
+func (cl *commandLine) parseFloat64Slice(p *Float64Slice, flag, value, usage, sep string) error {
+	if value == "" {
+		*p = Float64Slice{}
+		cl.separatedVar(p, flag, usage, sep)
+
+		return nil
+	}
+
+	fs := &Float64Slice{}
+
+	if err := fs.setSeparated(value, sep); err != nil {
+		return err
 	}
 
+	*p = *fs
+	cl.separatedVar(p, flag, usage, sep)
+
 	return nil
 }
 
-func (*commandLine) flagName(sf reflect.StructField, prefix string) string {
-	if f := sf.Tag.Get("flag"); f != "" {
-		return f
+func (cl *commandLine) parseBoolSlice(p *BoolSlice, flag, value, usage, sep string) error {
+	if value == "" {
+		*p = BoolSlice{}
+		cl.separatedVar(p, flag, usage, sep)
+
+		return nil
 	}
 
-	n := sf.Name
+	bs := &BoolSlice{}
 
-	if prefix != "" {
-		n = fmt.Sprintf("%s-%s", prefix, n)
+	if err := bs.setSeparated(value, sep); err != nil {
+		return err
 	}
 
-	return strcase.ToKebab(n)
+	*p = *bs
+	cl.separatedVar(p, flag, usage, sep)
+
+	return nil
 }
 
-func (cl *commandLine) envVarName(sf reflect.StructField, prefix string) string {
-	if e := sf.Tag.Get("env"); e != "" {
-		return e
-	}
+func (cl *commandLine) parseDurationSlice(p *DurationSlice, flag, value, usage, sep string) error {
+	if value == "" {
+		*p = DurationSlice{}
+		cl.separatedVar(p, flag, usage, sep)
 
-	n := fmt.Sprintf("%s_%s", cl.name, sf.Name)
-	if prefix != "" {
-		n = fmt.Sprintf("%s_%s_%s", cl.name, prefix, sf.Name)
+		return nil
 	}
 
-	return strcase.ToScreamingSnake(n)
-}
+	ds := &DurationSlice{}
 
-func (*commandLine) usage(sf reflect.StructField, env string, prefix string) string {
-	if u := sf.Tag.Get("help"); u != "" {
-		return fmt.Sprintf("%s (env %s)", u, env)
+	if err := ds.setSeparated(value, sep); err != nil {
+		return err
 	}
 
-	n := sf.Name
-	if prefix != "" {
-		n = fmt.Sprintf("%s %s", prefix, sf.Name)
-	}
+	*p = *ds
+	cl.separatedVar(p, flag, usage, sep)
 
-	return fmt.Sprintf("%s (env %s)", strcase.ToDelimited(n, ' '), env)
+	return nil
 }
 
-func (cl *commandLine) parseHelp(flags []string) {
-	if len(flags) == 0 {
-		return
+func (cl *commandLine) parseTimeSlice(p *TimeSlice, flag, value, usage, sep string) error {
+	if value == "" {
+		*p = TimeSlice{}
+		cl.separatedVar(p, flag, usage, sep)
+
+		return nil
 	}
 
-	for _, f := range flags {
-		if f == "--help" || f == "-help" || f == "--h" || f == "-h" {
-			cl.help = true
+	ts := &TimeSlice{}
 
-			return
-		}
+	if err := ts.setSeparated(value, sep); err != nil {
+		return err
 	}
-}
 
-func (*commandLine) newPrefix(sf reflect.StructField, prefix string) string {
-	if prefix != "" {
-		return fmt.Sprintf("%s-%s", prefix, sf.Name)
-	}
+	*p = *ts
+	cl.separatedVar(p, flag, usage, sep)
 
-	return sf.Name
+	return nil
 }
 
-func (cl *commandLine) parseValue(kind reflect.Kind, varPointer any, flag, value, usage string) error { //nolint:cyclop
-	switch kind { //nolint:exhaustive
-	case reflect.Bool:
-		return cl.parseBool(varPointer.(*bool), flag, value, usage) //nolint:forcetypeassert
-	case reflect.String:
-		cl.flagSet.StringVar(varPointer.(*string), flag, value, usage) //nolint:forcetypeassert
+// separatedVar registers slice flag which is split using the given separator.
+func (cl *commandLine) separatedVar(p separatedSetter, flag, usage, sep string) {
+	if sep == "," {
+		cl.flagSet.Var(p, flag, usage)
 
-		return nil
-	case reflect.Uint:
-		return cl.parseUint(varPointer.(*uint), flag, value, usage) //nolint:forcetypeassert
-	case reflect.Uint64:
-		return cl.parseUint64(varPointer.(*uint64), flag, value, usage) //nolint:forcetypeassert
-	case reflect.Int:
-		return cl.parseInt(varPointer.(*int), flag, value, usage) //nolint:forcetypeassert
-	case reflect.Int64:
-		switch varPointer := varPointer.(type) {
-		case *time.Duration:
-			return cl.parseDuration(varPointer, flag, value, usage)
-		case *int64:
-			return cl.parseInt64(varPointer, flag, value, usage)
-		}
-	case reflect.Float64:
-		return cl.parseFloat64(varPointer.(*float64), flag, value, usage) //nolint:forcetypeassert
-	case reflect.Struct:
-		switch varPointer := varPointer.(type) {
-		case *URL:
-			return cl.parseURL(varPointer, flag, value, usage)
-		case *Time:
-			return cl.parseTime(varPointer, flag, value, usage)
-		}
-	case reflect.Slice:
-		switch varPointer := varPointer.(type) {
-		case *StringSlice:
-			return cl.parseStringSlice(varPointer, flag, value, usage)
-		case *IntSlice:
-			return cl.parseIntSlice(varPointer, flag, value, usage)
-		}
+		return
 	}
 
-	return fmt.Errorf("parsing value: %w: %v", ErrUnsupportedType, kind)
+	cl.flagSet.Var(&separatedValue{value: p, sep: sep}, flag, usage)
 }
 
-func (cl *commandLine) parseBool(p *bool, flag, value, usage string) error {
+func (cl *commandLine) parseDurationMap(p *DurationMap, flag, value, usage string) error {
 	if value == "" {
-		cl.flagSet.BoolVar(p, flag, false, usage)
+		*p = DurationMap{}
+		cl.flagSet.Var(p, flag, usage)
 
 		return nil
 	}
 
-	val, err := strconv.ParseBool(value)
-	if err != nil {
-		return fmt.Errorf("parsing bool %q: %w", value, err)
+	dm := &DurationMap{}
+
+	if err := dm.Set(value); err != nil {
+		return err
 	}
 
-	cl.flagSet.BoolVar(p, flag, val, usage)
+	*p = *dm
+	cl.flagSet.Var(p, flag, usage)
 
 	return nil
 }
 
-func (cl *commandLine) parseUint(p *uint, flag, value, usage string) error {
-	if value == "" {
-		cl.flagSet.UintVar(p, flag, 0, usage)
-
-		return nil
+func (cl *commandLine) parsePointer(v reflect.Value, flag, value, usage string) error {
+	if !isElementType(v.Type().Elem()) {
+		return fmt.Errorf("parsing value: %w: %v", ErrUnsupportedType, v.Type())
 	}
 
-	val, err := strconv.ParseUint(value, 10, 32) //nolint:gomnd
-	if err != nil {
-		return fmt.Errorf("parsing uint %q: %w", value, err)
+	p := &pointerValue{value: v}
+
+	if value == "" {
+		v.SetZero()
+	} else if err := p.Set(value); err != nil {
+		return err
 	}
 
-	cl.flagSet.UintVar(p, flag, uint(val), usage)
+	cl.flagSet.Var(p, flag, usage)
 
 	return nil
 }
 
-func (cl *commandLine) parseUint64(p *uint64, flag, value, usage string) error {
-	if value == "" {
-		cl.flagSet.Uint64Var(p, flag, 0, usage)
+func (cl *commandLine) parseSized(v reflect.Value, flag, value, usage string) error {
+	s := &sizedValue{value: v}
 
-		return nil
-	}
+	v.SetZero()
 
-	val, err := strconv.ParseUint(value, 10, 64) //nolint:gomnd
-	if err != nil {
-		return fmt.Errorf("parsing uint64 %q: %w", value, err)
+	if value != "" {
+		if err := s.Set(value); err != nil {
+			return err
+		}
 	}
 
-	cl.flagSet.Uint64Var(p, flag, val, usage)
+	cl.flagSet.Var(s, flag, usage)
 
 	return nil
 }
 
-func (cl *commandLine) parseInt(p *int, flag, value, usage string) error {
-	if value == "" {
-		cl.flagSet.IntVar(p, flag, 0, usage)
+func (cl *commandLine) parseSlice(v reflect.Value, parse func(string) (any, error), flag, value, usage, sep string) error { //nolint:lll
+	sv := &sliceValue{value: v, parse: parse, sep: sep}
 
-		return nil
+	v.Set(reflect.MakeSlice(v.Type(), 0, 0))
+
+	if err := sv.Set(value); err != nil {
+		return err
 	}
 
-	val, err := strconv.Atoi(value)
-	if err != nil {
-		return fmt.Errorf("parsing int %q: %w", value, err)
+	cl.flagSet.Var(sv, flag, usage)
+
+	return nil
+}
+
+func (cl *commandLine) parseArray(v reflect.Value, flag, value, usage, sep string) error {
+	a := &arrayValue{value: v, sep: sep}
+
+	if value != "" {
+		if err := a.Set(value); err != nil {
+			return err
+		}
 	}
 
-	cl.flagSet.IntVar(p, flag, val, usage)
+	cl.flagSet.Var(a, flag, usage)
 
 	return nil
 }
 
-func (cl *commandLine) parseInt64(p *int64, flag, value, usage string) error {
+func (cl *commandLine) parseURL(p *URL, flag, value, usage string) error {
 	if value == "" {
-		cl.flagSet.Int64Var(p, flag, 0, usage)
+		*p = URL{} //nolint:exhaustruct
+		cl.flagSet.Var(p, flag, usage)
 
 		return nil
 	}
 
-	val, err := strconv.ParseInt(value, 10, 64) //nolint:gomnd
-	if err != nil {
-		return fmt.Errorf("parsing int64 %q: %w", value, err)
+	u := &URL{} //nolint:exhaustruct
+
+	if err := u.Set(value); err != nil {
+		return err
 	}
 
-	cl.flagSet.Int64Var(p, flag, val, usage)
+	*p = *u
+	cl.flagSet.Var(p, flag, usage)
 
 	return nil
 }
 
-func (cl *commandLine) parseDuration(p *time.Duration, flag, value, usage string) error {
+func (cl *commandLine) parseTime(p *Time, flag, value, usage string) error {
 	if value == "" {
-		cl.flagSet.DurationVar(p, flag, 0, usage)
+		*p = Time{} //nolint:exhaustruct
+		cl.flagSet.Var(p, flag, usage)
 
 		return nil
 	}
 
-	val, err := time.ParseDuration(value)
-	if err != nil {
-		return fmt.Errorf("parsing duration %q: %w", value, err)
+	t := &Time{} //nolint:exhaustruct
+
+	if err := t.Set(value); err != nil {
+		return err
 	}
 
-	cl.flagSet.DurationVar(p, flag, val, usage)
+	*p = *t
+	cl.flagSet.Var(p, flag, usage)
 
 	return nil
 }
 
-func (cl *commandLine) parseFloat64(p *float64, flag, value, usage string) error {
+func (cl *commandLine) parseStdTime(p *time.Time, flag, value, usage string) error {
 	if value == "" {
-		cl.flagSet.Float64Var(p, flag, 0, usage)
+		*p = time.Time{}
+		cl.flagSet.Var((*timeValue)(p), flag, usage)
 
 		return nil
 	}
 
-	val, err := strconv.ParseFloat(value, 64) //nolint:gomnd
-	if err != nil {
-		return fmt.Errorf("parsing float64 %q: %w", value, err)
+	t := new(timeValue)
+
+	if err := t.Set(value); err != nil {
+		return err
 	}
 
-	cl.flagSet.Float64Var(p, flag, val, usage)
+	*p = time.Time(*t)
+	cl.flagSet.Var((*timeValue)(p), flag, usage)
 
 	return nil
 }
 
-func (cl *commandLine) parseStringSlice(p *StringSlice, flag, value, usage string) error {
+func (cl *commandLine) parseIP(p *net.IP, flag, value, usage string) error {
 	if value == "" {
-		*p = StringSlice{}
-		cl.flagSet.Var(p, flag, usage)
+		*p = nil
+		cl.flagSet.Var((*ipValue)(p), flag, usage)
 
 		return nil
 	}
 
-	ss := &StringSlice{}
+	ip := new(ipValue)
 
-	_ = ss.Set(value)
+	if err := ip.Set(value); err != nil {
+		return err
+	}
 
-	*p = *ss
-	cl.flagSet.Var(p, flag, usage)
+	*p = net.IP(*ip)
+	cl.flagSet.Var((*ipValue)(p), flag, usage)
 
 	return nil
 }
 
-func (cl *commandLine) parseIntSlice(p *IntSlice, flag, value, usage string) error {
+func (cl *commandLine) parseIPNet(p *net.IPNet, flag, value, usage string) error {
 	if value == "" {
-		*p = IntSlice{}
-		cl.flagSet.Var(p, flag, usage)
+		*p = net.IPNet{} //nolint:exhaustruct
+		cl.flagSet.Var((*ipNetValue)(p), flag, usage)
 
 		return nil
 	}
 
-	is := &IntSlice{}
+	ipNet := new(ipNetValue)
 
-	if err := is.Set(value); err != nil {
+	if err := ipNet.Set(value); err != nil {
 		return err
 	}
 
-	*p = *is
-	cl.flagSet.Var(p, flag, usage)
+	*p = net.IPNet(*ipNet)
+	cl.flagSet.Var((*ipNetValue)(p), flag, usage)
 
 	return nil
 }
 
-func (cl *commandLine) parseURL(p *URL, flag, value, usage string) error {
+func (cl *commandLine) parsePrefix(p *netip.Prefix, flag, value, usage string) error {
 	if value == "" {
-		*p = URL{} //nolint:exhaustruct
-		cl.flagSet.Var(p, flag, usage)
+		*p = netip.Prefix{}
+		cl.flagSet.Var((*prefixValue)(p), flag, usage)
 
 		return nil
 	}
 
-	u := &URL{} //nolint:exhaustruct
+	prefix := new(prefixValue)
 
-	if err := u.Set(value); err != nil {
+	if err := prefix.Set(value); err != nil {
 		return err
 	}
 
-	*p = *u
-	cl.flagSet.Var(p, flag, usage)
+	*p = netip.Prefix(*prefix)
+	cl.flagSet.Var((*prefixValue)(p), flag, usage)
 
 	return nil
 }
 
-func (cl *commandLine) parseTime(p *Time, flag, value, usage string) error {
+func (cl *commandLine) parseRawJSON(p *json.RawMessage, flag, value, usage string) error {
 	if value == "" {
-		*p = Time{} //nolint:exhaustruct
-		cl.flagSet.Var(p, flag, usage)
+		*p = nil
+		cl.flagSet.Var((*rawJSONValue)(p), flag, usage)
 
 		return nil
 	}
 
-	t := &Time{} //nolint:exhaustruct
+	raw := new(rawJSONValue)
 
-	if err := t.Set(value); err != nil {
+	if err := raw.Set(value); err != nil {
 		return err
 	}
 
-	*p = *t
-	cl.flagSet.Var(p, flag, usage)
+	*p = json.RawMessage(*raw)
+	cl.flagSet.Var((*rawJSONValue)(p), flag, usage)
 
 	return nil
 }
@@ -993,11 +2310,15 @@ func (cl *commandLine) exit(err error) error {
 
 		return err
 	case flag.ExitOnError:
+		if cl.beforeExit != nil {
+			cl.beforeExit()
+		}
+
 		if cl.help || errors.Is(err, flag.ErrHelp) {
 			osExit(0)
 		}
 
-		_, _ = fmt.Fprintf(cl.output, "bee: %v\n", err)
+		cl.errorFormat(cl.output, err)
 		osExit(2) //nolint:gomnd
 	case flag.PanicOnError:
 		panic(err)
@@ -1005,3 +2326,150 @@ func (cl *commandLine) exit(err error) error {
 
 	return nil
 }
+
+// maskSecretDefaults hides default values of secret fields from usage by resetting them to the
+// representation of zero value.
+func (cl *commandLine) maskSecretDefaults() {
+	for name := range cl.secrets {
+		f := cl.flagSet.Lookup(name)
+		if f == nil {
+			continue
+		}
+
+		zero := reflect.New(reflect.TypeOf(f.Value).Elem()).Interface().(flag.Value) //nolint:forcetypeassert
+		f.DefValue = zero.String()
+	}
+}
+
+// usedExperimental returns sorted flag names of experimental fields with values supplied by
+// command line flag, environment variable, secret file or config file.
+func (cl *commandLine) usedExperimental() []string {
+	names := []string{}
+
+	for name := range cl.experimental {
+		if source, ok := cl.sources[name]; ok && source != "default" {
+			names = append(names, name)
+		}
+	}
+
+	slices.Sort(names)
+
+	return names
+}
+
+// dumpConfig writes flag name, resolved value and its source, i.e. default, file, secret, env or
+// flag, of every parsed field. Values of fields tagged with secret are masked.
+func (cl *commandLine) dumpConfig(w io.Writer) {
+	cl.flagSet.VisitAll(func(f *flag.Flag) {
+		source, ok := cl.sources[f.Name]
+		if !ok {
+			return
+		}
+
+		value := f.Value.String()
+		if _, secret := cl.secrets[f.Name]; secret {
+			value = "****"
+		}
+
+		_, _ = fmt.Fprintf(w, "%s=%s (%s)\n", f.Name, value, source)
+	})
+}
+
+// expandArgsFiles replaces arguments of the form @file with arguments read from the file. Each
+// line of the file may contain several arguments separated by whitespace, which may be quoted
+// with single or double quotes. Empty lines and lines starting with # are skipped. Arguments
+// after "--" terminator are not expanded.
+func expandArgsFiles(args []string) ([]string, error) {
+	expanded := make([]string, 0, len(args))
+
+	for i, arg := range args {
+		if arg == "--" {
+			return append(expanded, args[i:]...), nil
+		}
+
+		path, ok := strings.CutPrefix(arg, "@")
+		if !ok || path == "" {
+			expanded = append(expanded, arg)
+
+			continue
+		}
+
+		fileArgs, err := readArgsFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("args file %s: %w", path, err)
+		}
+
+		expanded = append(expanded, fileArgs...)
+	}
+
+	return expanded, nil
+}
+
+func readArgsFile(path string) ([]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading: %w", err)
+	}
+
+	args := []string{}
+
+	for n, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		lineArgs, err := splitArgs(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n+1, err)
+		}
+
+		args = append(args, lineArgs...)
+	}
+
+	return args, nil
+}
+
+// splitArgs splits line into whitespace separated arguments honoring single and double quotes.
+func splitArgs(line string) ([]string, error) {
+	var (
+		args    []string
+		current strings.Builder
+		quote   rune
+		inArg   bool
+	)
+
+	for _, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote, inArg = r, true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote %c", quote)
+	}
+
+	if inArg {
+		args = append(args, current.String())
+	}
+
+	return args, nil
+}
+
+func formatError(w io.Writer, err error) {
+	_, _ = fmt.Fprintf(w, "bee: %v\n", err)
+}
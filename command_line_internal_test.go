@@ -2,13 +2,21 @@ package bee
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"net"
+	"net/netip"
 	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
 	"regexp"
+	"runtime/debug"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -60,10 +68,10 @@ func TestParse_errors(t *testing.T) {
 		},
 		"unsupported-field-type": {
 			in: &struct {
-				Port int16
+				Port chan int
 			}{},
 			flags:   []string{""},
-			wantErr: "Port def: parsing value: type not supported: int16",
+			wantErr: "Port def: parsing value: type not supported: chan",
 		},
 		"---help": {
 			in: &struct {
@@ -88,26 +96,51 @@ func TestParse_errors(t *testing.T) {
 	}
 }
 
-func TestParse_unexportedFieldReturnsError(t *testing.T) {
+func TestParse_ignoredFields(t *testing.T) {
 	t.Parallel()
 
+	type client struct {
+		conn chan struct{}
+	}
+
+	cfg := &struct {
+		Public  string `def:"a"`
+		private string
+		Client  *client `bee:"-"`
+		Cache   struct {
+			Items map[string]complex128
+		} `bee:"-"`
+		Limit int `bee:"-" min:"1"`
+	}{}
 	cl := newCommandLine("test")
 	cl.errorHandling = flag.ContinueOnError
+	cl.output = io.Discard
 
 	defer func() {
 		if r := recover(); r != nil {
-			t.Fatalf("parse panicked for unexported field: %v", r)
+			t.Fatalf("parse panicked for ignored field: %v", r)
 		}
 	}()
 
-	err := cl.parse(&struct {
-		Public  string
-		private string
-	}{}, []string{})
+	err := cl.parse(cfg, []string{"-public", "b"})
+	assertError(t, err, "")
 
-	if err == nil {
-		t.Fatal("want error for unexported field, got nil")
+	if want := "b"; cfg.Public != want {
+		t.Errorf("want public %q, got %q", want, cfg.Public)
+	}
+
+	for _, name := range []string{"private", "client", "cache-items", "limit"} {
+		if cl.flagSet.Lookup(name) != nil {
+			t.Errorf("want no flag %s", name)
+		}
 	}
+
+	cl = newCommandLine("test")
+	cl.errorHandling = flag.ContinueOnError
+	cl.output = io.Discard
+
+	err = cl.parse(cfg, []string{"-limit", "2"})
+	assertError(t, err, "flag provided but not defined: -limit")
 }
 
 func TestParse_duplicateFlagReturnsError(t *testing.T) {
@@ -190,6 +223,52 @@ func TestParse_requiredTagIsSatisfiedByFlag(t *testing.T) {
 	}
 }
 
+func TestParse_requiredTagIsSatisfiedByConfigFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"DSN": "postgres://file"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &struct {
+		DSN string `req:""`
+	}{}
+	cl := newCommandLine("test")
+	cl.errorHandling = flag.ContinueOnError
+	cl.configFile = path
+	cl.lookupEnvFunc = func(string) (string, bool) {
+		return "", false
+	}
+
+	err := cl.parse(cfg, []string{})
+	assertError(t, err, "")
+
+	if cfg.DSN != "postgres://file" {
+		t.Fatalf("want required value from config file, got %q", cfg.DSN)
+	}
+}
+
+func TestParse_requiredTagIsSatisfiedByNegatedEnv(t *testing.T) {
+	t.Parallel()
+
+	cfg := &struct {
+		TLS bool `req:"true"`
+	}{TLS: true}
+	cl := newCommandLine("test")
+	cl.errorHandling = flag.ContinueOnError
+	cl.lookupEnvFunc = func(env string) (string, bool) {
+		return "true", env == "TEST_NO_TLS"
+	}
+
+	err := cl.parse(cfg, []string{})
+	assertError(t, err, "")
+
+	if cfg.TLS {
+		t.Fatal("want negated env to set TLS false")
+	}
+}
+
 func TestParse_requiredTagWorksForNestedConfig(t *testing.T) {
 	t.Parallel()
 
@@ -221,6 +300,140 @@ func TestParse_requiredTagRejectsDefaultTag(t *testing.T) {
 	assertError(t, err, `DatabaseURL req: cannot combine req and def tags`)
 }
 
+func TestParse_requiredAliasRejectsDefaultTags(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		config any
+		want   string
+	}{
+		"def": {
+			config: &struct {
+				Port int `def:"8080" required:"true"`
+			}{},
+			want: `Port required: cannot combine required and def tags`,
+		},
+		"default-func": {
+			config: &struct {
+				Port int `default-func:"test-port" req:""`
+			}{},
+			want: `Port req: cannot combine req and def tags`,
+		},
+	}
+
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			cl := newCommandLine("test")
+			cl.errorHandling = flag.ContinueOnError
+
+			assertError(t, cl.parse(tt.config, []string{}), tt.want)
+		})
+	}
+}
+
+func TestParse_missingRequiredReportsAllFields(t *testing.T) {
+	t.Parallel()
+
+	cl := newCommandLine("test")
+	cl.errorHandling = flag.ContinueOnError
+	cl.lookupEnvFunc = func(string) (string, bool) {
+		return "", false
+	}
+
+	err := cl.parse(&struct {
+		DatabaseURL string `required:"true"`
+		Mongo       struct {
+			Host string `req:""`
+		}
+	}{}, []string{})
+
+	if !errors.Is(err, ErrMissingRequired) {
+		t.Fatalf("want ErrMissingRequired, got %v", err)
+	}
+
+	assertError(t, err, "DatabaseURL required: required value missing; set TEST_DATABASE_URL or -database-url; "+
+		"Host req: required value missing; set TEST_MONGO_HOST or -mongo-host")
+}
+
+func TestParse_validationReportsAllFields(t *testing.T) {
+	t.Parallel()
+
+	cl := newCommandLine("test")
+	cl.errorHandling = flag.ContinueOnError
+
+	err := cl.parse(&struct {
+		Port  int    `def:"70000" min:"1" max:"65535"`
+		Env   string `def:"dev" oneof:"development,staging,production"`
+		Valid string `def:"ok" minlen:"1"`
+		Mongo struct {
+			Timeout time.Duration `def:"0s" min:"1s"`
+		}
+	}{}, []string{})
+
+	if !errors.Is(err, ErrValidation) {
+		t.Fatalf("want ErrValidation, got %v", err)
+	}
+
+	assertError(t, err, `Port max: value 70000 must be <= 65535; `+
+		`Env oneof: value "dev" must be one of development, staging, production; `+
+		`Timeout min: value 0s must be >= 1s`)
+}
+
+func TestParse_missingRequiredIsSatisfied(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		config any
+		env    map[string]string
+		flags  []string
+	}{
+		"env": {
+			config: &struct {
+				Port int `required:"true"`
+			}{},
+			env: map[string]string{"TEST_PORT": "8080"},
+		},
+		"flag": {
+			config: &struct {
+				Port int `required:"true"`
+			}{},
+			flags: []string{"-port", "8080"},
+		},
+		"explicit-zero-flag": {
+			config: &struct {
+				Port int `required:"true"`
+			}{},
+			flags: []string{"-port", "0"},
+		},
+		"nested-flag": {
+			config: &struct {
+				Mongo struct {
+					Host string `required:"true"`
+				}
+			}{},
+			flags: []string{"-mongo-host", "localhost"},
+		},
+	}
+
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			cl := newCommandLine("test")
+			cl.errorHandling = flag.ContinueOnError
+			cl.lookupEnvFunc = func(env string) (string, bool) {
+				value, ok := tt.env[env]
+
+				return value, ok
+			}
+
+			assertError(t, cl.parse(tt.config, tt.flags), "")
+		})
+	}
+}
+
 func TestExitOnErrorWritesErrorAndExits(t *testing.T) {
 	var output bytes.Buffer
 	cl := newCommandLine("test")
@@ -419,7 +632,7 @@ func TestParse_usage(t *testing.T) { //nolint:funlen
 			config: &struct {
 				Temperature int `def:"a"`
 			}{},
-			wantErr: `Temperature def: parsing int "a": strconv.Atoi: parsing "a": invalid syntax`,
+			wantErr: `Temperature def: parsing int "a": strconv.ParseInt: parsing "a": invalid syntax`,
 		},
 		"int64-help-with-invalid-def": {
 			config: &struct {
@@ -497,7 +710,92 @@ func TestParse_usage(t *testing.T) { //nolint:funlen
 			config: &struct {
 				DailyTemperatures IntSlice `def:"10,-5,a"`
 			}{},
-			wantErr: `DailyTemperatures def: parsing int: strconv.Atoi: parsing "a": invalid syntax`,
+			wantErr: `DailyTemperatures def: parsing int: strconv.ParseInt: parsing "a": invalid syntax`,
+		},
+		"float64-slice-help-with-def": {
+			config: &struct {
+				Weights Float64Slice `def:"0.5,1.5"`
+			}{},
+			want: "Usage of test: -weights value weights (env TEST_WEIGHTS) (default [0.5,1.5])",
+		},
+		"float64-slice-help-with-invalid-def": {
+			config: &struct {
+				Weights Float64Slice `def:"0.5,a"`
+			}{},
+			wantErr: `Weights def: parsing float64 element 1: strconv.ParseFloat: parsing "a": invalid syntax`,
+		},
+		"duration-slice-help-with-def": {
+			config: &struct {
+				Backoffs DurationSlice `def:"1s,5s,30s"`
+			}{},
+			want: "Usage of test: -backoffs value backoffs (env TEST_BACKOFFS) (default [1s,5s,30s])",
+		},
+		"duration-slice-help-with-invalid-def": {
+			config: &struct {
+				Backoffs DurationSlice `def:"1s,a"`
+			}{},
+			wantErr: `Backoffs def: parsing duration element 1: time: invalid duration "a"`,
+		},
+		"string-slice-help-with-sep": {
+			config: &struct {
+				Paths StringSlice `sep:":"`
+			}{},
+			want: "Usage of test: -paths value paths (env TEST_PATHS)",
+		},
+		"secret-help-with-def": {
+			config: &struct {
+				Password string `def:"hunter2" secret:"true"`
+				Port     int    `def:"5432" secret:""`
+			}{},
+			want: "Usage of test: -password string password (env TEST_PASSWORD) -port int port (env TEST_PORT)",
+		},
+		"nested-secret-help-with-def": {
+			config: &struct {
+				DB struct {
+					Token StringSlice `def:"a,b"`
+					Hosts URL         `def:"http://user:pass@db"`
+				} `secret:"true"`
+				Name string `def:"maia"`
+			}{},
+			want: "Usage of test: -db-hosts value db hosts (env TEST_DB_HOSTS) -db-token value db token (env TEST_DB_TOKEN) -name string name (env TEST_NAME) (default \"maia\")", //nolint:lll
+		},
+		"experimental-help": {
+			config: &struct {
+				FastPath bool `experimental:"true"`
+				Workers  int  `def:"4" experimental:"true" help:"number of workers"`
+			}{},
+			want: "Usage of test: -fast-path fast path (env TEST_FAST_PATH) [experimental] -workers int number of workers (env TEST_WORKERS) [experimental] (default 4)", //nolint:lll
+		},
+		"duration-map-help-with-def": {
+			config: &struct {
+				Timeouts DurationMap `def:"read=5s,write=10s"`
+			}{},
+			want: "Usage of test: -timeouts value timeouts (env TEST_TIMEOUTS) (default [read=5s,write=10s])",
+		},
+		"duration-map-help-with-invalid-def": {
+			config: &struct {
+				Timeouts DurationMap `def:"read=a"`
+			}{},
+			wantErr: `Timeouts def: parsing duration for key "read": time: invalid duration "a"`,
+		},
+		"pointer-help": {
+			config: &struct {
+				Port *int `def:"8080"`
+				TLS  *bool
+			}{},
+			want: "Usage of test: -port value port (env TEST_PORT) (default 8080) -tls tls (env TEST_TLS)",
+		},
+		"array-help-without-def": {
+			config: &struct {
+				Pair [2]string
+			}{},
+			want: "Usage of test: -pair value pair (env TEST_PAIR)",
+		},
+		"array-help-with-def": {
+			config: &struct {
+				Pair [2]string `def:"foo,bar"`
+			}{},
+			want: "Usage of test: -pair value pair (env TEST_PAIR) (default [foo,bar])",
 		},
 		"number-in-child-struct-help-with-invalid-def": {
 			config: &struct {
@@ -537,6 +835,46 @@ func TestParse_usage(t *testing.T) { //nolint:funlen
 -number-4 uint number 4 (env TEST_NUMBER_4)
 -number-5 uint number 5 (env TEST_NUMBER_5)`,
 		},
+		"env-prefix": {
+			config: &struct {
+				Mongo struct {
+					Host string
+				} `env-prefix:"MONGO"`
+			}{},
+			want: "Usage of test: -mongo-host string mongo host (env MONGO_HOST)",
+		},
+		"shared-env": {
+			config: &struct {
+				Cache struct {
+					RedisURL string `shared-env:"REDIS_URL"`
+				}
+			}{},
+			want: "Usage of test: -cache-redis-url string cache redis url (shared env REDIS_URL)",
+		},
+		"shared-env-with-help": {
+			config: &struct {
+				RedisURL string `shared-env:"REDIS_URL" help:"redis"`
+			}{},
+			want: "Usage of test: -redis-url string redis (shared env REDIS_URL)",
+		},
+		"std-time-invalid-def": {
+			config: &struct {
+				Start time.Time `def:"a"`
+			}{},
+			wantErr: `Start def: parsing time: parsing time "a" as "2006-01-02T15:04:05Z07:00": cannot parse "a" as "2006"`,
+		},
+		"std-time-without-def": {
+			config: &struct {
+				Start time.Time
+			}{},
+			want: "Usage of test: -start value start (env TEST_START)",
+		},
+		"std-time-valid-def": {
+			config: &struct {
+				Start time.Time `def:"2002-10-02T10:00:00-05:00"`
+			}{},
+			want: "Usage of test: -start value start (env TEST_START) (default 2002-10-02T10:00:00-05:00)",
+		},
 		"time-invalid-def": {
 			config: &struct {
 				Start Time `def:"a"`
@@ -710,6 +1048,58 @@ func TestParseValidationOneOf(t *testing.T) {
 	assertError(t, err, `Env oneof: value "qa" must be one of dev, staging, prod`)
 }
 
+func TestParseValidationEquals(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		cfg     any
+		flags   []string
+		wantErr string
+	}{
+		"matching": {
+			cfg: &struct {
+				Password        string `equals:"PasswordConfirm"`
+				PasswordConfirm string
+			}{},
+			flags: []string{"--password", "s3cr3t", "--password-confirm", "s3cr3t"},
+		},
+		"mismatching": {
+			cfg: &struct {
+				Password        string `equals:"PasswordConfirm"`
+				PasswordConfirm string
+			}{},
+			flags:   []string{"--password", "s3cr3t", "--password-confirm", "secret"},
+			wantErr: `Password equals: value must equal PasswordConfirm`,
+		},
+		"nested-matching-slices": {
+			cfg: &struct {
+				Auth struct {
+					Hosts        StringSlice `equals:"ConfirmHosts"`
+					ConfirmHosts StringSlice
+				}
+			}{},
+			flags: []string{"--auth-hosts", "a,b", "--auth-confirm-hosts", "a,b"},
+		},
+		"unknown-field": {
+			cfg: &struct {
+				Password string `equals:"Missing"`
+			}{},
+			wantErr: `Password equals: unknown field "Missing"`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			cl := newCommandLine("test")
+			cl.errorHandling = flag.ContinueOnError
+			err := cl.parse(tt.cfg, tt.flags)
+			assertError(t, err, tt.wantErr)
+		})
+	}
+}
+
 func TestParseValidationLengthTags(t *testing.T) {
 	t.Parallel()
 
@@ -1200,6 +1590,44 @@ func TestParse_environment_errors(t *testing.T) {
 			},
 			wantErr: `Endpoint env: parsing url: parse "%": invalid URL escape "%"`,
 		},
+		"bool-env-and-negated-env-set": {
+			config: &struct {
+				TLS bool
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "true", true
+			},
+			wantErr: `TLS env: conflicting TEST_TLS and TEST_NO_TLS`,
+		},
+		"bool-negated-env-invalid": {
+			config: &struct {
+				Log struct {
+					TLS bool
+				}
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "a", env == "TEST_LOG_NO_TLS"
+			},
+			wantErr: `TLS env: parsing bool "a": strconv.ParseBool: parsing "a": invalid syntax`,
+		},
+		"bool-empty-env": {
+			config: &struct {
+				TLS bool `def:"true"`
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "", env == "TEST_TLS"
+			},
+			wantErr: `TLS env: parsing bool "": invalid syntax`,
+		},
+		"bool-slice-invalid-env": {
+			config: &struct {
+				Gates BoolSlice
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "true,maybe", env == "TEST_GATES"
+			},
+			wantErr: `Gates env: parsing bool element 1: strconv.ParseBool: parsing "maybe": invalid syntax`,
+		},
 		"int-slice-invalid-env": {
 			config: &struct {
 				DailyTemperatures IntSlice `def:"10,-5,0"`
@@ -1207,7 +1635,7 @@ func TestParse_environment_errors(t *testing.T) {
 			lookupEnvFunc: func(env string) (string, bool) {
 				return "a,-2,3", true
 			},
-			wantErr: `DailyTemperatures env: parsing int: strconv.Atoi: parsing "a": invalid syntax`,
+			wantErr: `DailyTemperatures env: parsing int: strconv.ParseInt: parsing "a": invalid syntax`,
 		},
 	}
 
@@ -1226,408 +1654,3235 @@ func TestParse_environment_errors(t *testing.T) {
 	}
 }
 
-func TestParse_environment(t *testing.T) { //nolint:cyclop,gocognit,funlen,maintidx
+func TestParse_integerLiterals(t *testing.T) {
 	t.Parallel()
 
-	tests := map[string]struct {
-		config          any
-		lookupEnvFunc   func(string) (string, bool)
-		wantString      string
-		wantBool        bool
-		wantUint        uint
-		wantUint64      uint64
-		wantInt         int
-		wantInt64       int64
-		wantFloat64     float64
-		wantDuration    time.Duration
-		wantURL         URL
-		wantStringSlice StringSlice
-		wantIntSlice    IntSlice
+	cfg := &struct {
+		Mask       int    `def:"0xFF"`
+		Mode       uint   `def:"0o644"`
+		Flags      uint64 `def:"0b1010"`
+		Offset     int64  `def:"-42"`
+		EnvMask    int
+		EnvMode    uint64
+		EnvOffset  int64
+		EnvDecimal uint
+	}{}
+	env := map[string]string{
+		"TEST_ENV_MASK":    "0x1F",
+		"TEST_ENV_MODE":    "0o755",
+		"TEST_ENV_OFFSET":  "-0b11",
+		"TEST_ENV_DECIMAL": "1_000",
+	}
+	cl := newCommandLine("test")
+	cl.errorHandling = flag.ContinueOnError
+	cl.lookupEnvFunc = func(name string) (string, bool) {
+		v, ok := env[name]
+
+		return v, ok
+	}
+
+	err := cl.parse(cfg, []string{})
+	assertError(t, err, "")
+
+	if cfg.Mask != 0xFF {
+		t.Errorf("want hex default 255, got %d", cfg.Mask)
+	}
+	if cfg.Mode != 0o644 {
+		t.Errorf("want octal default 420, got %d", cfg.Mode)
+	}
+	if cfg.Flags != 0b1010 {
+		t.Errorf("want binary default 10, got %d", cfg.Flags)
+	}
+	if cfg.Offset != -42 {
+		t.Errorf("want decimal default -42, got %d", cfg.Offset)
+	}
+	if cfg.EnvMask != 0x1F {
+		t.Errorf("want hex env 31, got %d", cfg.EnvMask)
+	}
+	if cfg.EnvMode != 0o755 {
+		t.Errorf("want octal env 493, got %d", cfg.EnvMode)
+	}
+	if cfg.EnvOffset != -3 {
+		t.Errorf("want binary env -3, got %d", cfg.EnvOffset)
+	}
+	if cfg.EnvDecimal != 1000 {
+		t.Errorf("want decimal env 1000, got %d", cfg.EnvDecimal)
+	}
+}
+
+type versionedTestConfig struct {
+	ConfigVersion string `version:"" def:"v2"`
+	V1            struct {
+		Host string `def:"localhost"`
+	} `version:"v1"`
+	V2 struct {
+		Addr string `def:":8080"`
+		Port int    `min:"1"`
+	} `version:"v2"`
+}
+
+func TestParse_fixedWidthNumbers(t *testing.T) {
+	t.Parallel()
+
+	type config struct {
+		Offset   int8    `def:"-8"`
+		Mask     int16   `def:"0x10"`
+		Count    int32   `max:"100"`
+		Level    uint8   `def:"255"`
+		Port     uint16  `def:"8080"`
+		Sequence uint32  `min:"1"`
+		Ratio    float32 `def:"0.5"`
+	}
+
+	cfg := &config{}
+	cl := newCommandLine("test")
+	cl.errorHandling = flag.ContinueOnError
+	cl.lookupEnvFunc = func(env string) (string, bool) {
+		return "32", env == "TEST_COUNT"
+	}
+
+	err := cl.parse(cfg, []string{"-sequence", "4000000000", "-ratio", "1.25"})
+	assertError(t, err, "")
+
+	want := config{Offset: -8, Mask: 16, Count: 32, Level: 255, Port: 8080, Sequence: 4000000000, Ratio: 1.25}
+	if *cfg != want {
+		t.Fatalf("want %+v, got %+v", want, *cfg)
+	}
+}
+
+func TestParse_fixedWidthNumberErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		config  any
+		env     string
+		flags   []string
+		wantErr string
 	}{
-		"string-env-not-set-def-not-set": {
-			config: &struct {
-				LogLevel string
-			}{},
-			lookupEnvFunc: func(env string) (string, bool) {
-				return "", false
-			},
-			wantString: "",
-		},
-		"string-env-not-set-def-set": {
-			config: &struct {
-				LogLevel string `def:"debug"`
-			}{},
-			lookupEnvFunc: func(env string) (string, bool) {
-				return "", false
-			},
-			wantString: "debug",
-		},
-		"string-env-set": {
-			config: &struct {
-				LogLevel string `def:"debug"`
-			}{},
-			lookupEnvFunc: func(env string) (string, bool) {
-				return "info", true
-			},
-			wantString: "info",
-		},
-		"bool-env-not-set-def-not-set": {
-			config: &struct {
-				Verbose bool
-			}{},
-			lookupEnvFunc: func(env string) (string, bool) {
-				return "", false
-			},
-			wantBool: false,
-		},
-		"bool-env-not-set-def-set": {
-			config: &struct {
-				Verbose bool `def:"true"`
-			}{},
-			lookupEnvFunc: func(env string) (string, bool) {
-				return "", false
-			},
-			wantBool: true,
-		},
-		"bool-env-set": {
-			config: &struct {
-				Verbose bool `def:"true"`
-			}{},
-			lookupEnvFunc: func(env string) (string, bool) {
-				return "false", true
-			},
-			wantBool: false,
-		},
-		"uint-env-not-set-def-not-set": {
+		"int16-overflow-env": {
 			config: &struct {
-				Port uint
+				Port int16
 			}{},
-			lookupEnvFunc: func(env string) (string, bool) {
-				return "", false
-			},
-			wantUint: 0,
+			env:     "70000",
+			wantErr: `Port env: parsing int: strconv.ParseInt: parsing "70000": value out of range`,
 		},
-		"uint-env-not-set-def-set": {
+		"uint8-overflow-def": {
 			config: &struct {
-				Port uint `def:"1"`
+				Level uint8 `def:"256"`
 			}{},
-			lookupEnvFunc: func(env string) (string, bool) {
-				return "", false
-			},
-			wantUint: 1,
+			wantErr: `Level def: parsing uint: strconv.ParseUint: parsing "256": value out of range`,
 		},
-		"uint-env-set": {
+		"float32-invalid-env": {
 			config: &struct {
-				Port uint `def:"1"`
+				Ratio float32
 			}{},
-			lookupEnvFunc: func(env string) (string, bool) {
-				return "2", true
-			},
-			wantUint: 2,
+			env:     "a",
+			wantErr: `Ratio env: parsing float: strconv.ParseFloat: parsing "a": invalid syntax`,
 		},
-		"uint64-env-not-set-def-not-set": {
+		"int32-overflow-flag": {
 			config: &struct {
-				Distance uint64
+				Count int32
 			}{},
-			lookupEnvFunc: func(env string) (string, bool) {
-				return "", false
-			},
-			wantUint64: 0,
+			flags: []string{"-count", "3000000000"},
+			wantErr: `invalid value "3000000000" for flag -count: ` +
+				`parsing int: strconv.ParseInt: parsing "3000000000": value out of range`,
 		},
-		"uint64-env-not-set-def-set": {
+		"uint16-max": {
 			config: &struct {
-				Distance uint64 `def:"1"`
+				Port uint16 `max:"1024"`
 			}{},
-			lookupEnvFunc: func(env string) (string, bool) {
-				return "", false
-			},
-			wantUint64: 1,
+			env:     "8080",
+			wantErr: `Port max: value 8080 must be <= 1024`,
 		},
-		"uint64-env-set": {
-			config: &struct {
-				Distance uint64 `def:"1"`
-			}{},
-			lookupEnvFunc: func(env string) (string, bool) {
-				return "2", true
-			},
-			wantUint64: 2,
+	}
+
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			cl := newCommandLine("test")
+			cl.errorHandling = flag.ContinueOnError
+			cl.output = io.Discard
+			cl.lookupEnvFunc = func(string) (string, bool) {
+				return tt.env, tt.env != ""
+			}
+
+			assertError(t, cl.parse(tt.config, tt.flags), tt.wantErr)
+		})
+	}
+}
+
+func TestParse_versionedConfig(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		flags       []string
+		env         map[string]string
+		wantVersion string
+		wantHost    string
+		wantAddr    string
+		wantPort    int
+		wantErr     string
+	}{
+		"v1-from-flag": {
+			flags:       []string{"--config-version", "v1", "--host", "db"},
+			wantVersion: "v1",
+			wantHost:    "db",
 		},
-		"int-env-not-set-def-not-set": {
-			config: &struct {
-				Degrees int
-			}{},
-			lookupEnvFunc: func(env string) (string, bool) {
-				return "", false
-			},
-			wantInt: 0,
+		"v1-from-flag-with-equals": {
+			flags:       []string{"-config-version=v1"},
+			wantVersion: "v1",
+			wantHost:    "localhost",
 		},
-		"int-env-not-set-def-set": {
-			config: &struct {
-				Degrees int `def:"1"`
-			}{},
-			lookupEnvFunc: func(env string) (string, bool) {
-				return "", false
+		"v2-from-env": {
+			env: map[string]string{
+				"TEST_CONFIG_VERSION": "v2",
+				"TEST_PORT":           "9090",
 			},
-			wantInt: 1,
+			flags:       []string{"--addr", ":9090"},
+			wantVersion: "v2",
+			wantAddr:    ":9090",
+			wantPort:    9090,
 		},
-		"int-env-set": {
-			config: &struct {
-				Degrees int `def:"1"`
-			}{},
-			lookupEnvFunc: func(env string) (string, bool) {
-				return "2", true
-			},
-			wantInt: 2,
+		"v2-from-def": {
+			flags:       []string{"--port", "1"},
+			wantVersion: "v2",
+			wantAddr:    ":8080",
+			wantPort:    1,
 		},
-		"int64-env-not-set-def-not-set": {
-			config: &struct {
-				Difference int64
-			}{},
-			lookupEnvFunc: func(env string) (string, bool) {
-				return "", false
-			},
-			wantInt64: 0,
+		"flag-overrides-env": {
+			env:         map[string]string{"TEST_CONFIG_VERSION": "v2"},
+			flags:       []string{"--config-version", "v1"},
+			wantVersion: "v1",
+			wantHost:    "localhost",
 		},
-		"int64-env-not-set-def-set": {
-			config: &struct {
-				Difference int64 `def:"1"`
-			}{},
-			lookupEnvFunc: func(env string) (string, bool) {
-				return "", false
-			},
-			wantInt64: 1,
+		"v1-rejects-v2-flags": {
+			flags:   []string{"--config-version", "v1", "--addr", ":9090"},
+			wantErr: "flag provided but not defined: -addr",
 		},
-		"int64-env-set": {
-			config: &struct {
-				Difference int64 `def:"1"`
-			}{},
-			lookupEnvFunc: func(env string) (string, bool) {
-				return "2", true
-			},
-			wantInt64: 2,
+		"unknown-version": {
+			flags:   []string{"--config-version", "v3"},
+			wantErr: `ConfigVersion version: unknown config version "v3"; supported versions: v1, v2`,
 		},
-		"float64-env-not-set-def-not-set": {
+	}
+
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := &versionedTestConfig{}
+			cl := newCommandLine("test")
+			cl.errorHandling = flag.ContinueOnError
+			cl.output = io.Discard
+			cl.flagSet.SetOutput(io.Discard)
+			cl.lookupEnvFunc = func(name string) (string, bool) {
+				v, ok := tt.env[name]
+
+				return v, ok
+			}
+
+			err := cl.parse(cfg, tt.flags)
+			assertError(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				return
+			}
+
+			if cfg.ConfigVersion != tt.wantVersion {
+				t.Errorf("want version %q, got %q", tt.wantVersion, cfg.ConfigVersion)
+			}
+			if cfg.V1.Host != tt.wantHost {
+				t.Errorf("want host %q, got %q", tt.wantHost, cfg.V1.Host)
+			}
+			if cfg.V2.Addr != tt.wantAddr {
+				t.Errorf("want addr %q, got %q", tt.wantAddr, cfg.V2.Addr)
+			}
+			if cfg.V2.Port != tt.wantPort {
+				t.Errorf("want port %d, got %d", tt.wantPort, cfg.V2.Port)
+			}
+		})
+	}
+}
+
+func TestParse_versionedConfigErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		config  any
+		wantErr string
+	}{
+		"missing-discriminator": {
 			config: &struct {
-				Balance float64
+				V1 struct{ Host string } `version:"v1"`
 			}{},
-			lookupEnvFunc: func(env string) (string, bool) {
-				return "", false
-			},
-			wantFloat64: 0,
+			wantErr: "version: missing string field tagged with empty version tag",
 		},
-		"float64-env-not-set-def-set": {
+		"unsupported-type": {
 			config: &struct {
-				Balance float64 `def:"1.2"`
+				V1 string `version:"v1"`
 			}{},
-			lookupEnvFunc: func(env string) (string, bool) {
-				return "", false
-			},
-			wantFloat64: 1.2,
+			wantErr: "V1 version: unsupported type string",
 		},
-		"float64-env-set": {
-			config: &struct {
-				Balance float64 `def:"1.2"`
-			}{},
-			lookupEnvFunc: func(env string) (string, bool) {
-				return "2.3", true
+	}
+
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			cl := newCommandLine("test")
+			cl.errorHandling = flag.ContinueOnError
+
+			err := cl.parse(tt.config, []string{})
+			assertError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestParse_environmentPrefix(t *testing.T) {
+	t.Parallel()
+
+	env := map[string]string{
+		"MONGO_HOST":          "mongo",
+		"MONGO_REPLICA_NAME":  "rs0",
+		"MONGO_TLS":           "true",
+		"TEST_REDIS_HOST":     "redis",
+		"TEST_MONGO_HOST":     "ignored",
+		"TEST_MONGO_PASSWORD": "ignored",
+		"MONGO_PASSWORD":      "ignored",
+		"MONGO_SECRET":        "s3cr3t",
+	}
+	cfg := &struct {
+		Mongo struct {
+			Host     string
+			TLS      bool
+			Password string `env:"MONGO_SECRET"`
+			Replica  struct {
+				Name string
+			}
+		} `env-prefix:"MONGO"`
+		Redis struct {
+			Host string
+		}
+	}{}
+	cl := newCommandLine("test")
+	cl.errorHandling = flag.ContinueOnError
+	cl.lookupEnvFunc = func(name string) (string, bool) {
+		v, ok := env[name]
+
+		return v, ok
+	}
+
+	err := cl.parse(cfg, []string{})
+	assertError(t, err, "")
+
+	if cfg.Mongo.Host != "mongo" {
+		t.Errorf("want mongo host from MONGO_HOST, got %q", cfg.Mongo.Host)
+	}
+	if !cfg.Mongo.TLS {
+		t.Error("want mongo tls from MONGO_TLS")
+	}
+	if cfg.Mongo.Password != "s3cr3t" {
+		t.Errorf("want env tag to take precedence over env prefix, got %q", cfg.Mongo.Password)
+	}
+	if cfg.Mongo.Replica.Name != "rs0" {
+		t.Errorf("want nested replica name from MONGO_REPLICA_NAME, got %q", cfg.Mongo.Replica.Name)
+	}
+	if cfg.Redis.Host != "redis" {
+		t.Errorf("want redis host from TEST_REDIS_HOST, got %q", cfg.Redis.Host)
+	}
+}
+
+func TestParse_sharedEnvironment(t *testing.T) {
+	t.Parallel()
+
+	cfg := &struct {
+		Cache struct {
+			RedisURL string `shared-env:"REDIS_URL"`
+			Enabled  bool   `shared-env:"CACHE" def:"true"`
+		}
+	}{}
+	cl := newCommandLine("test")
+	cl.errorHandling = flag.ContinueOnError
+	cl.lookupEnvFunc = func(name string) (string, bool) {
+		switch name {
+		case "REDIS_URL":
+			return "redis://shared", true
+		case "NO_CACHE":
+			return "true", true
+		default:
+			return "", false
+		}
+	}
+
+	err := cl.parse(cfg, []string{})
+	assertError(t, err, "")
+
+	if cfg.Cache.RedisURL != "redis://shared" {
+		t.Fatalf("want shared env value, got %q", cfg.Cache.RedisURL)
+	}
+	if cfg.Cache.Enabled {
+		t.Fatal("want negated shared env to set Enabled false")
+	}
+}
+
+func TestParse_envFallback(t *testing.T) {
+	t.Parallel()
+
+	type config struct {
+		DatabaseURL string `env:"NEW_DB_URL, LEGACY_DATABASE_URL" def:"postgres://localhost"`
+		Debug       bool   `env:"NEW_DEBUG,LEGACY_DEBUG"`
+	}
+
+	tests := map[string]struct {
+		env       map[string]string
+		wantURL   string
+		wantDebug bool
+	}{
+		"new": {
+			env:     map[string]string{"NEW_DB_URL": "postgres://new", "LEGACY_DATABASE_URL": "postgres://legacy"},
+			wantURL: "postgres://new",
+		},
+		"legacy": {
+			env:       map[string]string{"LEGACY_DATABASE_URL": "postgres://legacy", "LEGACY_DEBUG": "true"},
+			wantURL:   "postgres://legacy",
+			wantDebug: true,
+		},
+		"default": {
+			env:     map[string]string{},
+			wantURL: "postgres://localhost",
+		},
+		"negated": {
+			env:       map[string]string{"NO_NEW_DEBUG": "false"},
+			wantURL:   "postgres://localhost",
+			wantDebug: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := &config{}
+			cl := newCommandLine("test")
+			cl.errorHandling = flag.ContinueOnError
+			cl.lookupEnvFunc = func(env string) (string, bool) {
+				value, ok := tt.env[env]
+
+				return value, ok
+			}
+
+			err := cl.parse(cfg, []string{})
+			assertError(t, err, "")
+
+			if cfg.DatabaseURL != tt.wantURL {
+				t.Errorf("want database url %q, got %q", tt.wantURL, cfg.DatabaseURL)
+			}
+			if cfg.Debug != tt.wantDebug {
+				t.Errorf("want debug %t, got %t", tt.wantDebug, cfg.Debug)
+			}
+
+			usage := cl.flagSet.Lookup("database-url").Usage
+			if !strings.Contains(usage, "env NEW_DB_URL, LEGACY_DATABASE_URL") {
+				t.Errorf("want usage to mention all env names, got %q", usage)
+			}
+		})
+	}
+}
+
+func TestParse_indexedEnv(t *testing.T) {
+	t.Parallel()
+
+	type config struct {
+		Hosts StringSlice `env-indexed:"" def:"localhost"`
+		Ports IntSlice    `env-indexed:""`
+		Pair  [2]string   `env-indexed:""`
+	}
+
+	tests := map[string]struct {
+		env       map[string]string
+		wantHosts StringSlice
+		wantPorts IntSlice
+		wantPair  [2]string
+	}{
+		"indexed": {
+			env: map[string]string{
+				"TEST_HOSTS_0": "a", "TEST_HOSTS_1": "b",
+				"TEST_PORTS_0": "80", "TEST_PORTS_1": "443",
+				"TEST_PAIR_0": "x", "TEST_PAIR_1": "y",
 			},
-			wantFloat64: 2.3,
+			wantHosts: StringSlice{"a", "b"},
+			wantPorts: IntSlice{80, 443},
+			wantPair:  [2]string{"x", "y"},
 		},
-		"duration-env-not-set-def-not-set": {
+		"plain-precedence": {
+			env:       map[string]string{"TEST_HOSTS": "c,d", "TEST_HOSTS_0": "a"},
+			wantHosts: StringSlice{"c", "d"},
+			wantPorts: IntSlice{},
+		},
+		"gap": {
+			env:       map[string]string{"TEST_HOSTS_0": "a", "TEST_HOSTS_2": "c"},
+			wantHosts: StringSlice{"a"},
+			wantPorts: IntSlice{},
+		},
+		"default": {
+			env:       map[string]string{"TEST_HOSTS_1": "b"},
+			wantHosts: StringSlice{"localhost"},
+			wantPorts: IntSlice{},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := &config{}
+			cl := newCommandLine("test")
+			cl.errorHandling = flag.ContinueOnError
+			cl.lookupEnvFunc = func(env string) (string, bool) {
+				value, ok := tt.env[env]
+
+				return value, ok
+			}
+
+			err := cl.parse(cfg, []string{})
+			assertError(t, err, "")
+
+			if !reflect.DeepEqual(cfg.Hosts, tt.wantHosts) {
+				t.Errorf("want hosts %v, got %v", tt.wantHosts, cfg.Hosts)
+			}
+			if !reflect.DeepEqual(cfg.Ports, tt.wantPorts) {
+				t.Errorf("want ports %v, got %v", tt.wantPorts, cfg.Ports)
+			}
+			if cfg.Pair != tt.wantPair {
+				t.Errorf("want pair %v, got %v", tt.wantPair, cfg.Pair)
+			}
+		})
+	}
+}
+
+func TestParse_indexedEnvErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		config  any
+		env     map[string]string
+		wantErr string
+	}{
+		"not-slice": {
 			config: &struct {
-				Timeout time.Duration
+				Host string `env-indexed:""`
 			}{},
-			lookupEnvFunc: func(env string) (string, bool) {
-				return "", false
-			},
-			wantDuration: time.Duration(0),
+			wantErr: `Host env-indexed: invalid config type`,
 		},
-		"duration-env-not-set-def-set": {
+		"separator": {
 			config: &struct {
-				Timeout time.Duration `def:"1s"`
+				Hosts StringSlice `env-indexed:""`
 			}{},
-			lookupEnvFunc: func(env string) (string, bool) {
-				return "", false
-			},
-			wantDuration: time.Second,
+			env:     map[string]string{"TEST_HOSTS_0": "a", "TEST_HOSTS_1": "b,c"},
+			wantErr: `Hosts env: TEST_HOSTS_1 contains separator ","`,
 		},
-		"duration-env-set": {
+		"invalid-element": {
 			config: &struct {
-				Timeout time.Duration `def:"1s"`
+				Ports IntSlice `env-indexed:""`
 			}{},
-			lookupEnvFunc: func(env string) (string, bool) {
-				return "2s", true
-			},
-			wantDuration: 2 * time.Second,
+			env:     map[string]string{"TEST_PORTS_0": "80", "TEST_PORTS_1": "http"},
+			wantErr: `Ports env: parsing int: strconv.ParseInt: parsing "http": invalid syntax`,
 		},
-		"url-env-not-set-def-not-set": {
+	}
+
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			cl := newCommandLine("test")
+			cl.errorHandling = flag.ContinueOnError
+			cl.lookupEnvFunc = func(env string) (string, bool) {
+				value, ok := tt.env[env]
+
+				return value, ok
+			}
+
+			err := cl.parse(tt.config, []string{})
+			assertError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestParse_secretsDir(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeSecret := func(name, content string) {
+		t.Helper()
+
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeSecret("TEST_DB_PASSWORD", "s3cr3t\n")
+	writeSecret("API_KEY", "  key  ")
+	writeSecret("TEST_HOST", "from-file")
+
+	cfg := &struct {
+		DB struct {
+			Password string `req:"true"`
+		}
+		APIKey string `env:"API_KEY"`
+		Host   string `def:"localhost"`
+		Port   int    `def:"8080"`
+	}{}
+	cl := newCommandLine("test")
+	cl.errorHandling = flag.ContinueOnError
+	cl.secretsDir = dir
+	cl.lookupEnvFunc = func(env string) (string, bool) {
+		return "from-env", env == "TEST_HOST"
+	}
+
+	err := cl.parse(cfg, []string{})
+	assertError(t, err, "")
+
+	if cfg.DB.Password != "s3cr3t" {
+		t.Errorf("want password from secret file, got %q", cfg.DB.Password)
+	}
+	if cfg.APIKey != "key" {
+		t.Errorf("want api key from secret file, got %q", cfg.APIKey)
+	}
+	if cfg.Host != "from-env" {
+		t.Errorf("want env to take precedence over secret file, got %q", cfg.Host)
+	}
+	if cfg.Port != 8080 {
+		t.Errorf("want default port, got %d", cfg.Port)
+	}
+}
+
+func TestParse_secretsDirErrors(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "TEST_PORT"), []byte("a"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "TEST_HOST"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		config  any
+		wantErr string
+	}{
+		"invalid-value": {
 			config: &struct {
-				Endpoint URL
+				Port int
 			}{},
-			lookupEnvFunc: func(env string) (string, bool) {
-				return "", false
-			},
-			wantURL: URL{}, //nolint:exhaustruct
+			wantErr: `Port secret: parsing int "a": strconv.ParseInt: parsing "a": invalid syntax`,
 		},
-		"url-env-not-set-def-set": {
+		"unreadable": {
 			config: &struct {
-				Endpoint URL `def:"http://localhost"`
+				Host string
 			}{},
-			lookupEnvFunc: func(env string) (string, bool) {
+			wantErr: "Host secret: reading secret file: read " + filepath.Join(dir, "TEST_HOST") + ": is a directory",
+		},
+	}
+
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			cl := newCommandLine("test")
+			cl.errorHandling = flag.ContinueOnError
+			cl.secretsDir = dir
+			cl.lookupEnvFunc = func(string) (string, bool) {
 				return "", false
-			},
-			wantURL: URL{URL: &url.URL{Scheme: "http", Host: "localhost"}}, //nolint:exhaustruct
+			}
+
+			err := cl.parse(tt.config, []string{})
+			assertError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestParse_environmentIndirect(t *testing.T) {
+	t.Parallel()
+
+	env := map[string]string{
+		"TEST_PORT": "APP_PORT",
+		"APP_PORT":  "9090",
+		"TEST_NAME": "APP_NAME",
+	}
+	cfg := &struct {
+		Port int    `env-indirect:"true"`
+		Name string `env-indirect:"true"`
+		Host string
+	}{}
+	cl := newCommandLine("test")
+	cl.errorHandling = flag.ContinueOnError
+	cl.lookupEnvFunc = func(name string) (string, bool) {
+		v, ok := env[name]
+
+		return v, ok
+	}
+
+	err := cl.parse(cfg, []string{})
+	assertError(t, err, "")
+
+	if cfg.Port != 9090 {
+		t.Fatalf("want indirect port 9090, got %d", cfg.Port)
+	}
+	if cfg.Name != "APP_NAME" {
+		t.Fatalf("want literal value when referenced var is missing, got %q", cfg.Name)
+	}
+}
+
+func TestParse_environmentIndirectCycle(t *testing.T) {
+	t.Parallel()
+
+	env := map[string]string{
+		"TEST_PORT":  "APP_PORT",
+		"APP_PORT":   "OTHER_PORT",
+		"OTHER_PORT": "APP_PORT",
+	}
+	cl := newCommandLine("test")
+	cl.errorHandling = flag.ContinueOnError
+	cl.lookupEnvFunc = func(name string) (string, bool) {
+		v, ok := env[name]
+
+		return v, ok
+	}
+
+	err := cl.parse(&struct {
+		Port int `env-indirect:"true"`
+	}{}, []string{})
+
+	assertError(t, err, "Port env: env indirection cycle: TEST_PORT -> APP_PORT -> OTHER_PORT -> APP_PORT")
+}
+
+func TestParse_environment(t *testing.T) { //nolint:cyclop,gocognit,funlen,maintidx
+	t.Parallel()
+
+	tests := map[string]struct {
+		config          any
+		lookupEnvFunc   func(string) (string, bool)
+		wantString      string
+		wantBool        bool
+		wantUint        uint
+		wantUint64      uint64
+		wantInt         int
+		wantInt64       int64
+		wantFloat64     float64
+		wantDuration    time.Duration
+		wantURL         URL
+		wantStringSlice StringSlice
+		wantIntSlice    IntSlice
+		wantFloatSlice  Float64Slice
+		wantDurSlice    DurationSlice
+	}{
+		"string-env-not-set-def-not-set": {
+			config: &struct {
+				LogLevel string
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "", false
+			},
+			wantString: "",
+		},
+		"string-env-not-set-def-set": {
+			config: &struct {
+				LogLevel string `def:"debug"`
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "", false
+			},
+			wantString: "debug",
+		},
+		"string-env-set": {
+			config: &struct {
+				LogLevel string `def:"debug"`
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "info", true
+			},
+			wantString: "info",
+		},
+		"bool-env-not-set-def-not-set": {
+			config: &struct {
+				Verbose bool
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "", false
+			},
+			wantBool: false,
+		},
+		"bool-env-not-set-def-set": {
+			config: &struct {
+				Verbose bool `def:"true"`
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "", false
+			},
+			wantBool: true,
+		},
+		"bool-negated-env-set": {
+			config: &struct {
+				Verbose bool `def:"true"`
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "true", env == "TEST_NO_VERBOSE"
+			},
+			wantBool: false,
+		},
+		"bool-negated-env-set-false": {
+			config: &struct {
+				Verbose bool
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "false", env == "TEST_NO_VERBOSE"
+			},
+			wantBool: true,
+		},
+		"bool-negated-env-override-set": {
+			config: &struct {
+				TLS bool `env:"TLS" def:"true"`
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "true", env == "NO_TLS"
+			},
+			wantBool: false,
+		},
+		"bool-env-set": {
+			config: &struct {
+				Verbose bool `def:"true"`
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "false", env == "TEST_VERBOSE"
+			},
+			wantBool: false,
+		},
+		"uint-env-not-set-def-not-set": {
+			config: &struct {
+				Port uint
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "", false
+			},
+			wantUint: 0,
+		},
+		"uint-env-not-set-def-set": {
+			config: &struct {
+				Port uint `def:"1"`
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "", false
+			},
+			wantUint: 1,
+		},
+		"uint-env-set": {
+			config: &struct {
+				Port uint `def:"1"`
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "2", true
+			},
+			wantUint: 2,
+		},
+		"uint64-env-not-set-def-not-set": {
+			config: &struct {
+				Distance uint64
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "", false
+			},
+			wantUint64: 0,
+		},
+		"uint64-env-not-set-def-set": {
+			config: &struct {
+				Distance uint64 `def:"1"`
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "", false
+			},
+			wantUint64: 1,
+		},
+		"uint64-env-set": {
+			config: &struct {
+				Distance uint64 `def:"1"`
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "2", true
+			},
+			wantUint64: 2,
+		},
+		"int-env-not-set-def-not-set": {
+			config: &struct {
+				Degrees int
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "", false
+			},
+			wantInt: 0,
+		},
+		"int-env-not-set-def-set": {
+			config: &struct {
+				Degrees int `def:"1"`
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "", false
+			},
+			wantInt: 1,
+		},
+		"int-env-set": {
+			config: &struct {
+				Degrees int `def:"1"`
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "2", true
+			},
+			wantInt: 2,
+		},
+		"int64-env-not-set-def-not-set": {
+			config: &struct {
+				Difference int64
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "", false
+			},
+			wantInt64: 0,
+		},
+		"int64-env-not-set-def-set": {
+			config: &struct {
+				Difference int64 `def:"1"`
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "", false
+			},
+			wantInt64: 1,
+		},
+		"int64-env-set": {
+			config: &struct {
+				Difference int64 `def:"1"`
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "2", true
+			},
+			wantInt64: 2,
+		},
+		"float64-env-not-set-def-not-set": {
+			config: &struct {
+				Balance float64
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "", false
+			},
+			wantFloat64: 0,
+		},
+		"float64-env-not-set-def-set": {
+			config: &struct {
+				Balance float64 `def:"1.2"`
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "", false
+			},
+			wantFloat64: 1.2,
+		},
+		"float64-env-set": {
+			config: &struct {
+				Balance float64 `def:"1.2"`
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "2.3", true
+			},
+			wantFloat64: 2.3,
+		},
+		"duration-env-not-set-def-not-set": {
+			config: &struct {
+				Timeout time.Duration
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "", false
+			},
+			wantDuration: time.Duration(0),
+		},
+		"duration-env-not-set-def-set": {
+			config: &struct {
+				Timeout time.Duration `def:"1s"`
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "", false
+			},
+			wantDuration: time.Second,
+		},
+		"duration-env-set": {
+			config: &struct {
+				Timeout time.Duration `def:"1s"`
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "2s", true
+			},
+			wantDuration: 2 * time.Second,
+		},
+		"url-env-not-set-def-not-set": {
+			config: &struct {
+				Endpoint URL
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "", false
+			},
+			wantURL: URL{}, //nolint:exhaustruct
+		},
+		"url-env-not-set-def-set": {
+			config: &struct {
+				Endpoint URL `def:"http://localhost"`
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "", false
+			},
+			wantURL: URL{URL: &url.URL{Scheme: "http", Host: "localhost"}}, //nolint:exhaustruct
+		},
+		"url-env-set": {
+			config: &struct {
+				Endpoint URL `def:"http://localhost"`
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "https://api.example.com/v1", true
+			},
+			wantURL: URL{URL: &url.URL{Scheme: "https", Host: "api.example.com", Path: "/v1"}}, //nolint:exhaustruct
+		},
+		"string-slice-env-not-set-def-not-set": {
+			config: &struct {
+				Buckets StringSlice
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "", false
+			},
+			wantStringSlice: StringSlice{},
+		},
+		"string-slice-env-not-set-def-set": {
+			config: &struct {
+				Buckets StringSlice `def:"foo,bar,baz"`
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "", false
+			},
+			wantStringSlice: StringSlice{"foo", "bar", "baz"},
+		},
+		"string-slice-env-set": {
+			config: &struct {
+				Buckets StringSlice `def:"foo,bar,baz"`
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "qux,bar", true
+			},
+			wantStringSlice: StringSlice{"qux", "bar"},
+		},
+		"int-slice-env-not-set-def-not-set": {
+			config: &struct {
+				DailyTemperatures IntSlice
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "", false
+			},
+			wantIntSlice: IntSlice{},
+		},
+		"int-slice-env-not-set-def-set": {
+			config: &struct {
+				DailyTemperatures IntSlice `def:"10,-5,0"`
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "", false
+			},
+			wantIntSlice: IntSlice{10, -5, 0},
+		},
+		"int-slice-env-set": {
+			config: &struct {
+				DailyTemperatures IntSlice `def:"10,-5,0"`
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "-2,3,-1", true
+			},
+			wantIntSlice: IntSlice{-2, 3, -1},
+		},
+		"float64-slice-env-not-set-def-not-set": {
+			config: &struct {
+				Weights Float64Slice
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "", false
+			},
+			wantFloatSlice: Float64Slice{},
+		},
+		"float64-slice-env-set": {
+			config: &struct {
+				Weights Float64Slice `def:"0.5"`
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "0.25,2", true
+			},
+			wantFloatSlice: Float64Slice{0.25, 2},
+		},
+		"duration-slice-env-not-set-def-not-set": {
+			config: &struct {
+				Backoffs DurationSlice
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "", false
+			},
+			wantDurSlice: DurationSlice{},
+		},
+		"duration-slice-env-set": {
+			config: &struct {
+				Backoffs DurationSlice `def:"1s"`
+			}{},
+			lookupEnvFunc: func(env string) (string, bool) {
+				return "2s,1m", true
+			},
+			wantDurSlice: DurationSlice{2 * time.Second, time.Minute},
+		},
+	}
+
+	for n, tt := range tests { //nolint:paralleltest
+
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			cl := newCommandLine("test")
+			cl.errorHandling = flag.ContinueOnError
+			cl.lookupEnvFunc = tt.lookupEnvFunc
+
+			if err := cl.parse(tt.config, []string{}); err != nil {
+				t.Error(err)
+			}
+
+			v := reflect.ValueOf(tt.config).Elem()
+
+			p := v.FieldByIndex([]int{0}).Addr().Interface()
+
+			switch p := p.(type) {
+			case *string:
+				if *p != tt.wantString {
+					t.Errorf("want %q, got %q", tt.wantString, *p)
+				}
+			case *bool:
+				if *p != tt.wantBool {
+					t.Errorf("want %t, got %t", tt.wantBool, *p)
+				}
+			case *uint:
+				if *p != tt.wantUint {
+					t.Errorf("want %d, got %d", tt.wantUint, *p)
+				}
+			case *uint64:
+				if *p != tt.wantUint64 {
+					t.Errorf("want %d, got %d", tt.wantUint64, *p)
+				}
+			case *int:
+				if *p != tt.wantInt {
+					t.Errorf("want %d, got %d", tt.wantInt, *p)
+				}
+			case *int64:
+				if *p != tt.wantInt64 {
+					t.Errorf("want %d, got %d", tt.wantInt64, *p)
+				}
+			case *float64:
+				if *p != tt.wantFloat64 {
+					t.Errorf("want %f, got %f", tt.wantFloat64, *p)
+				}
+			case *time.Duration:
+				if *p != tt.wantDuration {
+					t.Errorf("want %v, got %v", tt.wantDuration, *p)
+				}
+			case *URL:
+				if !reflect.DeepEqual(*p, tt.wantURL) {
+					t.Errorf("want %#v, got %#v", tt.wantURL, *p)
+				}
+			case *StringSlice:
+				if !reflect.DeepEqual(*p, tt.wantStringSlice) {
+					t.Errorf("want %#v, got %#v", tt.wantStringSlice, *p)
+				}
+			case *IntSlice:
+				if !reflect.DeepEqual(*p, tt.wantIntSlice) {
+					t.Errorf("want %#v, got %#v", tt.wantIntSlice, *p)
+				}
+			case *Float64Slice:
+				if !reflect.DeepEqual(*p, tt.wantFloatSlice) {
+					t.Errorf("want %#v, got %#v", tt.wantFloatSlice, *p)
+				}
+			case *DurationSlice:
+				if !reflect.DeepEqual(*p, tt.wantDurSlice) {
+					t.Errorf("want %#v, got %#v", tt.wantDurSlice, *p)
+				}
+			default:
+				t.Errorf("type %T not supported", p)
+			}
+		})
+	}
+}
+
+func TestWithUsage(t *testing.T) {
+	t.Parallel()
+
+	b := &bytes.Buffer{}
+
+	cl := newCommandLine("me")
+	cl.errorHandling = flag.ContinueOnError
+	cl.output = b
+	cl.flagSet.Usage = func() {
+		_, _ = fmt.Fprintf(cl.output, "Usage of %s %s:\n", "test", cl.name)
+		cl.flagSet.PrintDefaults()
+	}
+
+	_ = cl.parse(&struct{}{}, []string{"-h"})
+
+	if want := "Usage of test me:\n"; want != b.String() {
+		t.Errorf("want %q got %q", want, b.String())
+	}
+}
+
+func TestParse_stdTime(t *testing.T) {
+	t.Parallel()
+
+	cfg := &struct {
+		Start  time.Time `def:"2002-10-02T10:00:00-05:00" nonzero:""`
+		End    time.Time
+		Since  time.Time `oneof:"2020-01-01T00:00:00Z"`
+		Unset  time.Time
+		Nested struct {
+			At time.Time
+		}
+	}{}
+	cl := newCommandLine("test")
+	cl.errorHandling = flag.ContinueOnError
+	cl.lookupEnvFunc = func(env string) (string, bool) {
+		switch env {
+		case "TEST_END":
+			return "2003-01-02T15:04:05Z", true
+		case "TEST_SINCE":
+			return "2020-01-01T00:00:00Z", true
+		default:
+			return "", false
+		}
+	}
+
+	err := cl.parse(cfg, []string{"--nested-at", "2004-01-02T15:04:05Z"})
+	assertError(t, err, "")
+
+	if want := time.Date(2002, 10, 2, 15, 0, 0, 0, time.UTC); !cfg.Start.Equal(want) {
+		t.Errorf("want start %s, got %s", want, cfg.Start)
+	}
+	if want := time.Date(2003, 1, 2, 15, 4, 5, 0, time.UTC); !cfg.End.Equal(want) {
+		t.Errorf("want end %s, got %s", want, cfg.End)
+	}
+	if want := time.Date(2004, 1, 2, 15, 4, 5, 0, time.UTC); !cfg.Nested.At.Equal(want) {
+		t.Errorf("want nested at %s, got %s", want, cfg.Nested.At)
+	}
+	if !cfg.Unset.IsZero() {
+		t.Errorf("want zero unset time, got %s", cfg.Unset)
+	}
+}
+
+func TestParse_stdTimeErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		config  any
+		flags   []string
+		wantErr string
+	}{
+		"invalid-env": {
+			config: &struct {
+				Start time.Time
+			}{},
+			wantErr: `Start env: parsing time: parsing time "a" as "2006-01-02T15:04:05Z07:00": cannot parse "a" as "2006"`,
+		},
+		"nonzero": {
+			config: &struct {
+				End time.Time `nonzero:""`
+			}{},
+			wantErr: `End nonzero: value must not be zero`,
+		},
+	}
+
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			cl := newCommandLine("test")
+			cl.errorHandling = flag.ContinueOnError
+			cl.lookupEnvFunc = func(env string) (string, bool) {
+				return "a", env == "TEST_START"
+			}
+
+			err := cl.parse(tt.config, tt.flags)
+			assertError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestParse_relativeTime(t *testing.T) { //nolint:paralleltest
+	now := time.Date(2024, 12, 15, 10, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+
+	t.Cleanup(func() { timeNow = time.Now })
+
+	cfg := &struct {
+		Now      time.Time `def:"now"`
+		Deadline time.Time `def:"now+1mo"`
+		Since    Time      `def:"now-1mo"`
+		Expires  time.Time `def:"now+36h"`
+		Renew    time.Time `def:"now+12mo"`
+	}{}
+	cl := newCommandLine("test")
+	cl.errorHandling = flag.ContinueOnError
+
+	err := cl.parse(cfg, []string{"--renew", "now+2mo"})
+	assertError(t, err, "")
+
+	if !cfg.Now.Equal(now) {
+		t.Errorf("want now %s, got %s", now, cfg.Now)
+	}
+	if want := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC); !cfg.Deadline.Equal(want) {
+		t.Errorf("want deadline %s, got %s", want, cfg.Deadline)
+	}
+	if want := time.Date(2024, 11, 15, 10, 0, 0, 0, time.UTC); !cfg.Since.Equal(want) {
+		t.Errorf("want since %s, got %s", want, cfg.Since)
+	}
+	if want := time.Date(2024, 12, 16, 22, 0, 0, 0, time.UTC); !cfg.Expires.Equal(want) {
+		t.Errorf("want expires %s, got %s", want, cfg.Expires)
+	}
+	if want := time.Date(2025, 2, 15, 10, 0, 0, 0, time.UTC); !cfg.Renew.Equal(want) {
+		t.Errorf("want renew %s, got %s", want, cfg.Renew)
+	}
+
+	tests := map[string]struct {
+		value   string
+		wantErr string
+	}{
+		"missing-sign": {
+			value:   "now1mo",
+			wantErr: `Start env: parsing time "now1mo": invalid relative time`,
+		},
+		"invalid-months": {
+			value:   "now+amo",
+			wantErr: `Start env: parsing time "now+amo": invalid relative time`,
+		},
+		"invalid-duration": {
+			value:   "now+1y",
+			wantErr: `Start env: parsing time: time: unknown unit "y" in duration "+1y"`,
+		},
+	}
+
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			cl := newCommandLine("test")
+			cl.errorHandling = flag.ContinueOnError
+			cl.lookupEnvFunc = func(env string) (string, bool) {
+				return tt.value, env == "TEST_START"
+			}
+
+			err := cl.parse(&struct {
+				Start time.Time
+			}{}, []string{})
+			assertError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestParse_ip(t *testing.T) {
+	t.Parallel()
+
+	cfg := &struct {
+		Bind      net.IP `def:"127.0.0.1"`
+		Advertise net.IP
+		Allowed   net.IPNet `def:"10.0.0.0/8"`
+		Trusted   netip.Prefix
+		Unset     net.IP
+		UnsetNet  net.IPNet
+		Denied    netip.Prefix `def:"192.168.1.0/24"`
+	}{}
+	cl := newCommandLine("test")
+	cl.errorHandling = flag.ContinueOnError
+	cl.lookupEnvFunc = func(env string) (string, bool) {
+		return "2001:db8::1", env == "TEST_ADVERTISE"
+	}
+
+	err := cl.parse(cfg, []string{"--trusted", "172.16.0.0/12", "--allowed", "10.1.0.0/16"})
+	assertError(t, err, "")
+
+	if want := net.ParseIP("127.0.0.1"); !cfg.Bind.Equal(want) {
+		t.Errorf("want bind %s, got %s", want, cfg.Bind)
+	}
+	if want := net.ParseIP("2001:db8::1"); !cfg.Advertise.Equal(want) {
+		t.Errorf("want advertise %s, got %s", want, cfg.Advertise)
+	}
+	if want := "10.1.0.0/16"; cfg.Allowed.String() != want {
+		t.Errorf("want allowed %s, got %s", want, cfg.Allowed.String())
+	}
+	if want := netip.MustParsePrefix("172.16.0.0/12"); cfg.Trusted != want {
+		t.Errorf("want trusted %s, got %s", want, cfg.Trusted)
+	}
+	if want := netip.MustParsePrefix("192.168.1.0/24"); cfg.Denied != want {
+		t.Errorf("want denied %s, got %s", want, cfg.Denied)
+	}
+	if cfg.Unset != nil || cfg.UnsetNet.IP != nil {
+		t.Errorf("want unset values, got %s and %s", cfg.Unset, cfg.UnsetNet.String())
+	}
+
+	for name, want := range map[string]string{
+		"bind":    "127.0.0.1",
+		"allowed": "10.0.0.0/8",
+		"denied":  "192.168.1.0/24",
+		"unset":   "",
+	} {
+		if got := cl.flagSet.Lookup(name).DefValue; got != want {
+			t.Errorf("want %s default %q, got %q", name, want, got)
+		}
+	}
+}
+
+func TestParse_ipErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		config  any
+		flags   []string
+		wantErr string
+	}{
+		"invalid-ip-env": {
+			config: &struct {
+				Bind net.IP
+			}{},
+			wantErr: `Bind env: parsing ip "nope": invalid address`,
+		},
+		"invalid-ip-default": {
+			config: &struct {
+				Advertise net.IP `def:"300.1.1.1"`
+			}{},
+			wantErr: `Advertise def: parsing ip "300.1.1.1": invalid address`,
+		},
+		"invalid-cidr-flag": {
+			config: &struct {
+				Allowed net.IPNet
+			}{},
+			flags:   []string{"--allowed", "10.0.0.0"},
+			wantErr: `invalid value "10.0.0.0" for flag -allowed: parsing cidr: invalid CIDR address: 10.0.0.0`,
+		},
+		"invalid-prefix-default": {
+			config: &struct {
+				Trusted netip.Prefix `def:"10.0.0.0/33"`
+			}{},
+			wantErr: `Trusted def: parsing prefix: netip.ParsePrefix("10.0.0.0/33"): prefix length out of range`,
+		},
+	}
+
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			cl := newCommandLine("test")
+			cl.errorHandling = flag.ContinueOnError
+			cl.output = io.Discard
+			cl.lookupEnvFunc = func(env string) (string, bool) {
+				return "nope", env == "TEST_BIND"
+			}
+
+			err := cl.parse(tt.config, tt.flags)
+			assertError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestParse_boolSlice(t *testing.T) {
+	t.Parallel()
+
+	cfg := &struct {
+		Gates     BoolSlice `def:"true,false,true"`
+		Rollout   BoolSlice
+		Overrides BoolSlice `sep:";"`
+		Empty     BoolSlice
+	}{}
+	cl := newCommandLine("test")
+	cl.errorHandling = flag.ContinueOnError
+	cl.lookupEnvFunc = func(env string) (string, bool) {
+		return "1,0,t", env == "TEST_ROLLOUT"
+	}
+
+	err := cl.parse(cfg, []string{"--overrides", "false;true"})
+	assertError(t, err, "")
+
+	for name, tt := range map[string]struct {
+		got  BoolSlice
+		want BoolSlice
+	}{
+		"gates":     {cfg.Gates, BoolSlice{true, false, true}},
+		"rollout":   {cfg.Rollout, BoolSlice{true, false, true}},
+		"overrides": {cfg.Overrides, BoolSlice{false, true}},
+		"empty":     {cfg.Empty, BoolSlice{}},
+	} {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("want %s %v, got %v", name, tt.want, tt.got)
+		}
+	}
+
+	if want := "[true,false,true]"; cl.flagSet.Lookup("gates").DefValue != want {
+		t.Errorf("want gates default %s, got %s", want, cl.flagSet.Lookup("gates").DefValue)
+	}
+}
+
+func TestParse_complex(t *testing.T) {
+	t.Parallel()
+
+	type phase complex128
+
+	cfg := &struct {
+		Phase     phase      `def:"-1i"`
+		Impedance complex128 `def:"1+2i"`
+		Gain      complex64
+		Pole      Complex
+		Zeros     [2]complex128
+		Offset    *complex128
+	}{}
+	cl := newCommandLine("test")
+	cl.errorHandling = flag.ContinueOnError
+	cl.lookupEnvFunc = func(env string) (string, bool) {
+		return "0.5-1i", env == "TEST_GAIN"
+	}
+
+	err := cl.parse(cfg, []string{"--pole", "3i", "--zeros", "1+1i,-2", "--offset", "2"})
+	assertError(t, err, "")
+
+	if want := phase(complex(0, -1)); cfg.Phase != want {
+		t.Errorf("want phase %v, got %v", want, cfg.Phase)
+	}
+	if want := complex(1, 2); cfg.Impedance != want {
+		t.Errorf("want impedance %v, got %v", want, cfg.Impedance)
+	}
+	if want := complex64(complex(0.5, -1)); cfg.Gain != want {
+		t.Errorf("want gain %v, got %v", want, cfg.Gain)
+	}
+	if want := Complex(complex(0, 3)); cfg.Pole != want {
+		t.Errorf("want pole %v, got %v", want, cfg.Pole)
+	}
+	if want := [2]complex128{complex(1, 1), complex(-2, 0)}; cfg.Zeros != want {
+		t.Errorf("want zeros %v, got %v", want, cfg.Zeros)
+	}
+	if cfg.Offset == nil || *cfg.Offset != complex(2, 0) {
+		t.Errorf("want offset (2+0i), got %v", cfg.Offset)
+	}
+
+	for _, name := range []string{"impedance", "pole"} {
+		if _, ok := cl.flagSet.Lookup(name).Value.(*Complex); !ok {
+			t.Errorf("want %s flag parsed by Complex, got %T", name, cl.flagSet.Lookup(name).Value)
+		}
+	}
+}
+
+func TestParse_complexErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		config  any
+		flags   []string
+		wantErr string
+	}{
+		"invalid-default": {
+			config: &struct {
+				Impedance complex128 `def:"1+"`
+			}{},
+			wantErr: `Impedance def: parsing complex: strconv.ParseComplex: parsing "1+": invalid syntax`,
+		},
+		"invalid-flag": {
+			config: &struct {
+				Pole Complex
+			}{},
+			flags:   []string{"--pole", "i3"},
+			wantErr: `invalid value "i3" for flag -pole: parsing complex: strconv.ParseComplex: parsing "i3": invalid syntax`,
+		},
+	}
+
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			cl := newCommandLine("test")
+			cl.errorHandling = flag.ContinueOnError
+			cl.output = io.Discard
+
+			err := cl.parse(tt.config, tt.flags)
+			assertError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestParse_rawJSON(t *testing.T) {
+	t.Parallel()
+
+	cfg := &struct {
+		Options json.RawMessage `def:"{}"`
+		Payload json.RawMessage
+		Labels  json.RawMessage
+		Unset   json.RawMessage
+	}{}
+	cl := newCommandLine("test")
+	cl.errorHandling = flag.ContinueOnError
+	cl.lookupEnvFunc = func(env string) (string, bool) {
+		return `{"id": 1, "tags": ["a", "b"]}`, env == "TEST_PAYLOAD"
+	}
+
+	err := cl.parse(cfg, []string{"--labels", `["x"]`})
+	assertError(t, err, "")
+
+	if want := `{}`; string(cfg.Options) != want {
+		t.Errorf("want options %s, got %s", want, cfg.Options)
+	}
+	if want := `{"id": 1, "tags": ["a", "b"]}`; string(cfg.Payload) != want {
+		t.Errorf("want payload %s, got %s", want, cfg.Payload)
+	}
+	if want := `["x"]`; string(cfg.Labels) != want {
+		t.Errorf("want labels %s, got %s", want, cfg.Labels)
+	}
+	if cfg.Unset != nil {
+		t.Errorf("want nil unset, got %s", cfg.Unset)
+	}
+}
+
+func TestParse_rawJSONErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		config  any
+		flags   []string
+		wantErr string
+	}{
+		"invalid-env": {
+			config: &struct {
+				Payload json.RawMessage
+			}{},
+			wantErr: `Payload env: parsing json: invalid character 'i' looking for beginning of object key string`,
+		},
+		"invalid-default": {
+			config: &struct {
+				Options json.RawMessage `def:"[1,"`
+			}{},
+			wantErr: `Options def: parsing json: unexpected end of JSON input`,
+		},
+		"invalid-flag": {
+			config: &struct {
+				Labels json.RawMessage
+			}{},
+			flags:   []string{"--labels", "nope"},
+			wantErr: `invalid value "nope" for flag -labels: parsing json: invalid character 'o' in literal null (expecting 'u')`,
+		},
+	}
+
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			cl := newCommandLine("test")
+			cl.errorHandling = flag.ContinueOnError
+			cl.output = io.Discard
+			cl.lookupEnvFunc = func(env string) (string, bool) {
+				return "{invalid}", env == "TEST_PAYLOAD"
+			}
+
+			err := cl.parse(tt.config, tt.flags)
+			assertError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestParse_counter(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		flags     []string
+		env       string
+		want      Counter
+		wantQuiet Counter
+		wantErr   string
+	}{
+		"repeated": {
+			flags:     []string{"-verbose", "-verbose", "-verbose"},
+			want:      3,
+			wantQuiet: 1,
+		},
+		"env": {
+			flags:     []string{"-verbose"},
+			env:       "2",
+			want:      3,
+			wantQuiet: 1,
+		},
+		"default": {
+			flags:     []string{"-quiet"},
+			want:      0,
+			wantQuiet: 2,
+		},
+		"value": {
+			flags:     []string{"-verbose", "-verbose=5"},
+			want:      5,
+			wantQuiet: 1,
+		},
+		"unset": {
+			want:      0,
+			wantQuiet: 1,
+		},
+		"invalid-env": {
+			env:     "a",
+			wantErr: `Verbose env: parsing counter: strconv.ParseInt: parsing "a": invalid syntax`,
+		},
+	}
+
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := &struct {
+				Verbose Counter
+				Quiet   Counter `def:"1"`
+			}{}
+			cl := newCommandLine("test")
+			cl.errorHandling = flag.ContinueOnError
+			cl.output = io.Discard
+			cl.lookupEnvFunc = func(env string) (string, bool) {
+				return tt.env, tt.env != "" && env == "TEST_VERBOSE"
+			}
+
+			err := cl.parse(cfg, tt.flags)
+			assertError(t, err, tt.wantErr)
+
+			if tt.wantErr != "" {
+				return
+			}
+
+			if cfg.Verbose != tt.want {
+				t.Errorf("want verbose %d, got %d", tt.want, cfg.Verbose)
+			}
+			if cfg.Quiet != tt.wantQuiet {
+				t.Errorf("want quiet %d, got %d", tt.wantQuiet, cfg.Quiet)
+			}
+		})
+	}
+}
+
+func TestParse_short(t *testing.T) {
+	t.Parallel()
+
+	cfg := &struct {
+		Mongo struct {
+			ConnectionTimeout time.Duration `def:"5s" short:"t"`
+		}
+		Verbose bool   `short:"v"`
+		Token   string `short:"k" req:"" secret:""`
+		Name    string `short:"n" def:"app"`
+	}{}
+	cl := newCommandLine("test")
+	cl.errorHandling = flag.ContinueOnError
+
+	output := &bytes.Buffer{}
+	cl.output = output
+	cl.flagSet.SetOutput(output)
+
+	err := cl.parse(cfg, []string{"-t", "3s", "-v", "-k", "secret"})
+	assertError(t, err, "")
+
+	if want := 3 * time.Second; cfg.Mongo.ConnectionTimeout != want {
+		t.Errorf("want timeout %s, got %s", want, cfg.Mongo.ConnectionTimeout)
+	}
+	if !cfg.Verbose {
+		t.Error("want verbose set by short flag")
+	}
+	if want := "secret"; cfg.Token != want {
+		t.Errorf("want token %q, got %q", want, cfg.Token)
+	}
+	if want := "app"; cfg.Name != want {
+		t.Errorf("want name %q, got %q", want, cfg.Name)
+	}
+
+	for flagName, want := range map[string]string{
+		"mongo-connection-timeout": "flag",
+		"verbose":                  "flag",
+		"token":                    "flag",
+		"name":                     "default",
+	} {
+		if got := cl.sources[flagName]; got != want {
+			t.Errorf("want %s source %q, got %q", flagName, want, got)
+		}
+	}
+
+	cl.flagSet.PrintDefaults()
+
+	for _, want := range []string{"-t duration\n    \tshort for -mongo-connection-timeout (default 5s)", "-n string"} {
+		if !strings.Contains(output.String(), want) {
+			t.Errorf("want usage to contain %q, got:\n%s", want, output.String())
+		}
+	}
+
+	if strings.Contains(output.String(), "secret") {
+		t.Errorf("want secret hidden from usage, got:\n%s", output.String())
+	}
+}
+
+func TestParse_shortErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		config  any
+		wantErr string
+	}{
+		"duplicate-short": {
+			config: &struct {
+				Timeout time.Duration `short:"t"`
+				Tags    string        `short:"t"`
+			}{},
+			wantErr: `Tags short: flag -t already used by Timeout: invalid config type`,
+		},
+		"conflicting-flag": {
+			config: &struct {
+				Timeout time.Duration `short:"t"`
+				Tags    string        `flag:"t"`
+			}{},
+			wantErr: `Timeout short: duplicate flag "t": invalid config type`,
+		},
+		"long-short": {
+			config: &struct {
+				Timeout time.Duration `short:"to"`
+			}{},
+			wantErr: `Timeout short: invalid short flag "to": invalid config type`,
+		},
+	}
+
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			cl := newCommandLine("test")
+			cl.errorHandling = flag.ContinueOnError
+			cl.output = io.Discard
+
+			err := cl.parse(tt.config, nil)
+			assertError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestParse_pointers(t *testing.T) { //nolint:cyclop
+	t.Parallel()
+
+	cfg := &struct {
+		Name     *string
+		Port     *int `def:"8080"`
+		Offset   *int64
+		Workers  *uint
+		Size     *uint64
+		Rate     *float64 `min:"0"`
+		TLS      *bool
+		Debug    *bool
+		Timeout  *time.Duration `def:"0s"`
+		Interval *time.Duration
+		Limit    *int `min:"1"`
+	}{}
+	cl := newCommandLine("test")
+	cl.errorHandling = flag.ContinueOnError
+	cl.lookupEnvFunc = func(env string) (string, bool) {
+		switch env {
+		case "TEST_NAME":
+			return "", true
+		case "TEST_OFFSET":
+			return "-5", true
+		case "TEST_SIZE":
+			return "0x10", true
+		default:
+			return "", false
+		}
+	}
+
+	err := cl.parse(cfg, []string{"--workers", "4", "--rate", "0.5", "--tls"})
+	assertError(t, err, "")
+
+	if cfg.Name != nil {
+		t.Errorf("want nil name for empty env, got %q", *cfg.Name)
+	}
+	if cfg.Port == nil || *cfg.Port != 8080 {
+		t.Errorf("want port from def, got %v", cfg.Port)
+	}
+	if cfg.Offset == nil || *cfg.Offset != -5 {
+		t.Errorf("want offset from env, got %v", cfg.Offset)
+	}
+	if cfg.Workers == nil || *cfg.Workers != 4 {
+		t.Errorf("want workers from flag, got %v", cfg.Workers)
+	}
+	if cfg.Size == nil || *cfg.Size != 16 {
+		t.Errorf("want size from env, got %v", cfg.Size)
+	}
+	if cfg.Rate == nil || *cfg.Rate != 0.5 {
+		t.Errorf("want rate from flag, got %v", cfg.Rate)
+	}
+	if cfg.TLS == nil || !*cfg.TLS {
+		t.Errorf("want tls from bool flag, got %v", cfg.TLS)
+	}
+	if cfg.Debug != nil {
+		t.Errorf("want nil debug, got %v", *cfg.Debug)
+	}
+	if cfg.Timeout == nil || *cfg.Timeout != 0 {
+		t.Errorf("want explicit zero timeout, got %v", cfg.Timeout)
+	}
+	if cfg.Interval != nil {
+		t.Errorf("want nil interval, got %v", *cfg.Interval)
+	}
+	if cfg.Limit != nil {
+		t.Errorf("want nil limit to skip validation, got %v", *cfg.Limit)
+	}
+}
+
+func TestParse_pointerErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		config  any
+		flags   []string
+		wantErr string
+	}{
+		"invalid-def": {
+			config: &struct {
+				Port *int `def:"a"`
+			}{},
+			wantErr: `Port def: parsing int: strconv.ParseInt: parsing "a": invalid syntax`,
+		},
+		"unsupported": {
+			config: &struct {
+				Hosts *[]string
+			}{},
+			wantErr: `Hosts def: parsing value: type not supported: *[]string`,
+		},
+		"validation": {
+			config: &struct {
+				Port *int `max:"10"`
+			}{},
+			flags:   []string{"--port", "11"},
+			wantErr: `Port max: value 11 must be <= 10`,
+		},
+		"nonzero-nil": {
+			config: &struct {
+				Port *int `nonzero:""`
+			}{},
+			wantErr: `Port nonzero: value must not be zero`,
+		},
+	}
+
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			cl := newCommandLine("test")
+			cl.errorHandling = flag.ContinueOnError
+			cl.lookupEnvFunc = func(string) (string, bool) {
+				return "", false
+			}
+
+			err := cl.parse(tt.config, tt.flags)
+			assertError(t, err, tt.wantErr)
+		})
+	}
+}
+
+type testColor int
+
+const (
+	testColorRed testColor = iota + 1
+	testColorGreen
+)
+
+func parseTestColor(s string) (any, error) {
+	switch s {
+	case "red":
+		return testColorRed, nil
+	case "green":
+		return testColorGreen, nil
+	case "blue":
+		return "blue", nil
+	default:
+		return nil, fmt.Errorf("unknown color %q", s)
+	}
+}
+
+func TestParse_registeredSliceParser(t *testing.T) {
+	t.Parallel()
+
+	RegisterSliceParser(reflect.TypeFor[testColor](), parseTestColor)
+
+	cfg := &struct {
+		Colors   []testColor `def:"red"`
+		Palette  []testColor
+		Fallback []testColor
+	}{}
+	cl := newCommandLine("test")
+	cl.errorHandling = flag.ContinueOnError
+	cl.lookupEnvFunc = func(env string) (string, bool) {
+		return "green,red", env == "TEST_PALETTE"
+	}
+
+	err := cl.parse(cfg, []string{"--colors", "green,green,red"})
+	assertError(t, err, "")
+
+	if want := []testColor{testColorGreen, testColorGreen, testColorRed}; !reflect.DeepEqual(cfg.Colors, want) {
+		t.Errorf("want colors %v, got %v", want, cfg.Colors)
+	}
+	if want := []testColor{testColorGreen, testColorRed}; !reflect.DeepEqual(cfg.Palette, want) {
+		t.Errorf("want palette %v, got %v", want, cfg.Palette)
+	}
+	if want := []testColor{}; !reflect.DeepEqual(cfg.Fallback, want) {
+		t.Errorf("want empty fallback %v, got %v", want, cfg.Fallback)
+	}
+}
+
+func TestParse_normalizer(t *testing.T) {
+	t.Parallel()
+
+	RegisterNormalizer("normalized-region", func(s string) (string, error) {
+		return strings.ToLower(strings.TrimSpace(s)), nil
+	})
+
+	cfg := &struct {
+		NormalizedRegion string `def:"eu" oneof:"eu,us"`
+		Zone             string
+	}{}
+	cl := newCommandLine("test")
+	cl.errorHandling = flag.ContinueOnError
+	cl.lookupEnvFunc = func(env string) (string, bool) {
+		return " US ", env == "TEST_NORMALIZED_REGION"
+	}
+
+	err := cl.parse(cfg, []string{"--zone", "A"})
+	assertError(t, err, "")
+
+	if want := "us"; cfg.NormalizedRegion != want {
+		t.Errorf("want normalized region %q, got %q", want, cfg.NormalizedRegion)
+	}
+	if want := "A"; cfg.Zone != want {
+		t.Errorf("want zone %q, got %q", want, cfg.Zone)
+	}
+}
+
+func TestParse_normalizerErrors(t *testing.T) {
+	t.Parallel()
+
+	RegisterNormalizer("failing-normalized-host", func(string) (string, error) {
+		return "", errors.New("invalid host")
+	})
+	RegisterNormalizer("normalized-port", func(s string) (string, error) {
+		return strings.TrimSpace(s), nil
+	})
+
+	tests := map[string]struct {
+		cfg     any
+		wantErr string
+	}{
+		"normalizer-error": {
+			cfg: &struct {
+				FailingNormalizedHost string `def:"localhost"`
+			}{},
+			wantErr: "normalizing failing-normalized-host: invalid host",
+		},
+		"not-string": {
+			cfg: &struct {
+				NormalizedPort int `def:"80"`
+			}{},
+			wantErr: "normalizing normalized-port: type not supported",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			cl := newCommandLine("test")
+			cl.errorHandling = flag.ContinueOnError
+			cl.output = io.Discard
+
+			assertError(t, cl.parse(tc.cfg, nil), tc.wantErr)
+		})
+	}
+}
+
+func TestParse_defaultFunc(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	RegisterDefaultFunc("test-hostname", func() (string, error) {
+		calls.Add(1)
+
+		return "node-1", nil
+	})
+
+	cfg := &struct {
+		NodeName string `default-func:"test-hostname"`
+		Zone     string `default-func:"test-hostname"`
+		Region   string `default-func:"test-hostname"`
+	}{}
+	cl := newCommandLine("test")
+	cl.errorHandling = flag.ContinueOnError
+	cl.lookupEnvFunc = func(env string) (string, bool) {
+		return "zone-a", env == "TEST_ZONE"
+	}
+
+	err := cl.parse(cfg, []string{"--region", "eu"})
+	assertError(t, err, "")
+
+	if want := "node-1"; cfg.NodeName != want {
+		t.Errorf("want computed node name %q, got %q", want, cfg.NodeName)
+	}
+	if want := "zone-a"; cfg.Zone != want {
+		t.Errorf("want zone %q from env, got %q", want, cfg.Zone)
+	}
+	if want := "eu"; cfg.Region != want {
+		t.Errorf("want region %q from flag, got %q", want, cfg.Region)
+	}
+
+	// flags are registered with computed defaults before the command line is parsed
+	if want := int32(2); calls.Load() != want {
+		t.Errorf("want default func called %d times, got %d", want, calls.Load())
+	}
+}
+
+func TestParse_buildInfo(t *testing.T) {
+	t.Parallel()
+
+	info := &debug.BuildInfo{ //nolint:exhaustruct
+		GoVersion: "go1.23.4",
+		Main:      debug.Module{Path: "example.com/app", Version: "v1.2.3"}, //nolint:exhaustruct
+		Settings: []debug.BuildSetting{
+			{Key: "vcs.revision", Value: "abc123"},
+			{Key: "vcs.modified", Value: "false"},
+		},
+	}
+	devel := &debug.BuildInfo{Main: debug.Module{Version: "(devel)"}} //nolint:exhaustruct
+
+	tests := map[string]struct {
+		info       *debug.BuildInfo
+		env        string
+		wantCommit string
+		wantVer    string
+		wantGo     string
+		wantDirty  bool
+	}{
+		"build-info": {
+			info:       info,
+			wantCommit: "abc123",
+			wantVer:    "v1.2.3",
+			wantGo:     "go1.23.4",
+		},
+		"env-overrides": {
+			info:       info,
+			env:        "def456",
+			wantCommit: "def456",
+			wantVer:    "v1.2.3",
+			wantGo:     "go1.23.4",
+		},
+		"missing-build-info": {
+			wantCommit: "unknown",
+			wantVer:    "dev",
+			wantDirty:  true,
+		},
+		"devel-version": {
+			info:       devel,
+			wantCommit: "unknown",
+			wantVer:    "dev",
+			wantDirty:  true,
+		},
+	}
+
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := &struct {
+				Commit    string `buildinfo:"vcs.revision" def:"unknown"`
+				Version   string `buildinfo:"version" def:"dev"`
+				GoVersion string `buildinfo:"go"`
+				Dirty     bool   `buildinfo:"vcs.modified" def:"true"`
+			}{}
+			cl := newCommandLine("test")
+			cl.errorHandling = flag.ContinueOnError
+			cl.lookupEnvFunc = func(env string) (string, bool) {
+				return tt.env, tt.env != "" && env == "TEST_COMMIT"
+			}
+			cl.buildInfoFunc = func() (*debug.BuildInfo, bool) {
+				return tt.info, tt.info != nil
+			}
+
+			err := cl.parse(cfg, nil)
+			assertError(t, err, "")
+
+			if cfg.Commit != tt.wantCommit {
+				t.Errorf("want commit %q, got %q", tt.wantCommit, cfg.Commit)
+			}
+			if cfg.Version != tt.wantVer {
+				t.Errorf("want version %q, got %q", tt.wantVer, cfg.Version)
+			}
+			if cfg.GoVersion != tt.wantGo {
+				t.Errorf("want go version %q, got %q", tt.wantGo, cfg.GoVersion)
+			}
+			if cfg.Dirty != tt.wantDirty {
+				t.Errorf("want dirty %t, got %t", tt.wantDirty, cfg.Dirty)
+			}
+		})
+	}
+}
+
+func TestParse_defaultFuncErrors(t *testing.T) {
+	t.Parallel()
+
+	RegisterDefaultFunc("test-failing", func() (string, error) {
+		return "", errors.New("no hostname")
+	})
+
+	tests := map[string]struct {
+		config  any
+		wantErr string
+	}{
+		"unknown": {
+			config: &struct {
+				NodeName string `default-func:"test-unknown"`
+			}{},
+			wantErr: `NodeName default-func: unknown default func "test-unknown"`,
+		},
+		"failing": {
+			config: &struct {
+				NodeName string `default-func:"test-failing"`
+			}{},
+			wantErr: `NodeName default-func: no hostname`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			cl := newCommandLine("test")
+			cl.errorHandling = flag.ContinueOnError
+
+			assertError(t, cl.parse(tt.config, nil), tt.wantErr)
+		})
+	}
+}
+
+func TestParse_registeredSliceParserErrors(t *testing.T) {
+	t.Parallel()
+
+	RegisterSliceParser(reflect.TypeFor[testColor](), parseTestColor)
+
+	tests := map[string]struct {
+		env     string
+		wantErr string
+	}{
+		"parse-error": {
+			env:     "red,purple",
+			wantErr: `Colors env: parsing slice element 1: unknown color "purple"`,
+		},
+		"wrong-type": {
+			env:     "blue",
+			wantErr: `Colors env: parsing slice element 0: parser returned string, want bee.testColor`,
+		},
+	}
+
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			cl := newCommandLine("test")
+			cl.errorHandling = flag.ContinueOnError
+			cl.lookupEnvFunc = func(env string) (string, bool) {
+				return tt.env, env == "TEST_COLORS"
+			}
+
+			err := cl.parse(&struct {
+				Colors []testColor
+			}{}, []string{})
+			assertError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestParse_durationMap(t *testing.T) {
+	t.Parallel()
+
+	cfg := &struct {
+		Timeouts DurationMap `def:"read=1s"`
+		Limits   DurationMap
+	}{}
+	cl := newCommandLine("test")
+	cl.errorHandling = flag.ContinueOnError
+	cl.lookupEnvFunc = func(env string) (string, bool) {
+		return "idle=1m", env == "TEST_LIMITS"
+	}
+
+	err := cl.parse(cfg, []string{"-timeouts", "read=5s,write=10s"})
+	assertError(t, err, "")
+
+	if want := (DurationMap{"read": 5 * time.Second, "write": 10 * time.Second}); !reflect.DeepEqual(cfg.Timeouts, want) {
+		t.Fatalf("want timeouts %v, got %v", want, cfg.Timeouts)
+	}
+	if want := (DurationMap{"idle": time.Minute}); !reflect.DeepEqual(cfg.Limits, want) {
+		t.Fatalf("want limits %v, got %v", want, cfg.Limits)
+	}
+}
+
+func TestParse_sliceSeparator(t *testing.T) {
+	t.Parallel()
+
+	RegisterSliceParser(reflect.TypeFor[testColor](), parseTestColor)
+
+	cfg := &struct {
+		Sentences StringSlice `sep:";"`
+		Paths     StringSlice `def:"/usr/bin:/bin" sep:":"`
+		Ports     IntSlice    `sep:" "`
+		Weights   Float64Slice
+		Backoffs  DurationSlice `sep:"|"`
+		Colors    []testColor   `def:"red;green" sep:";"`
+		Tags      StringSlice   `sep:";"`
+		Pair      [2]string     `def:"a,b;c" sep:";"`
+		Hosts     [2]string     `sep:" "`
+	}{}
+	cl := newCommandLine("test")
+	cl.errorHandling = flag.ContinueOnError
+	cl.lookupEnvFunc = func(env string) (string, bool) {
+		switch env {
+		case "TEST_SENTENCES":
+			return "Hello, world;Bye, world", true
+		case "TEST_WEIGHTS":
+			return "0.5,1", true
+		case "TEST_BACKOFFS":
+			return "1s|5s", true
+		default:
+			return "", false
+		}
+	}
+
+	err := cl.parse(cfg, []string{"-ports", "80 443", "-tags", "a,b;c", "-hosts", "a.com b.com"})
+	assertError(t, err, "")
+
+	if want := (StringSlice{"Hello, world", "Bye, world"}); !reflect.DeepEqual(cfg.Sentences, want) {
+		t.Errorf("want sentences %v, got %v", want, cfg.Sentences)
+	}
+	if want := (StringSlice{"/usr/bin", "/bin"}); !reflect.DeepEqual(cfg.Paths, want) {
+		t.Errorf("want paths %v, got %v", want, cfg.Paths)
+	}
+	if want := (IntSlice{80, 443}); !reflect.DeepEqual(cfg.Ports, want) {
+		t.Errorf("want ports %v, got %v", want, cfg.Ports)
+	}
+	if want := (Float64Slice{0.5, 1}); !reflect.DeepEqual(cfg.Weights, want) {
+		t.Errorf("want weights %v, got %v", want, cfg.Weights)
+	}
+	if want := (DurationSlice{time.Second, 5 * time.Second}); !reflect.DeepEqual(cfg.Backoffs, want) {
+		t.Errorf("want backoffs %v, got %v", want, cfg.Backoffs)
+	}
+	if want := []testColor{testColorRed, testColorGreen}; !reflect.DeepEqual(cfg.Colors, want) {
+		t.Errorf("want colors %v, got %v", want, cfg.Colors)
+	}
+	if want := (StringSlice{"a,b", "c"}); !reflect.DeepEqual(cfg.Tags, want) {
+		t.Errorf("want tags %v, got %v", want, cfg.Tags)
+	}
+	if want := [2]string{"a,b", "c"}; cfg.Pair != want {
+		t.Errorf("want pair %v, got %v", want, cfg.Pair)
+	}
+	if want := [2]string{"a.com", "b.com"}; cfg.Hosts != want {
+		t.Errorf("want hosts %v, got %v", want, cfg.Hosts)
+	}
+}
+
+func TestParse_sliceSeparatorErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		config  any
+		wantErr string
+	}{
+		"int-slice": {
+			config: &struct {
+				Ports IntSlice `def:"80;a" sep:";"`
+			}{},
+			wantErr: `Ports def: parsing int: strconv.ParseInt: parsing "a": invalid syntax`,
+		},
+		"float64-slice": {
+			config: &struct {
+				Weights Float64Slice `def:"1;a" sep:";"`
+			}{},
+			wantErr: `Weights def: parsing float64 element 1: strconv.ParseFloat: parsing "a": invalid syntax`,
+		},
+		"duration-slice": {
+			config: &struct {
+				Backoffs DurationSlice `def:"1s;a" sep:";"`
+			}{},
+			wantErr: `Backoffs def: parsing duration element 1: time: invalid duration "a"`,
+		},
+	}
+
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			cl := newCommandLine("test")
+			cl.errorHandling = flag.ContinueOnError
+
+			assertError(t, cl.parse(tt.config, []string{}), tt.wantErr)
+		})
+	}
+}
+
+func TestExpandArgsFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "args")
+	content := "-name \"John Doe\" -tags a,b\n  # comment\n-empty ''\n"
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	unterminated := filepath.Join(dir, "unterminated")
+	if err := os.WriteFile(unterminated, []byte("-a\n-name 'John"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		in      []string
+		want    []string
+		wantErr string
+	}{
+		"expanded": {
+			in:   []string{"-v", "@" + path, "-port", "80"},
+			want: []string{"-v", "-name", "John Doe", "-tags", "a,b", "-empty", "", "-port", "80"},
+		},
+		"terminator": {
+			in:   []string{"@" + path, "--", "@" + path},
+			want: []string{"-name", "John Doe", "-tags", "a,b", "-empty", "", "--", "@" + path},
+		},
+		"lone-at": {
+			in:   []string{"@"},
+			want: []string{"@"},
+		},
+		"unterminated-quote": {
+			in:      []string{"@" + unterminated},
+			wantErr: "args file " + unterminated + ": line 2: unterminated quote '",
+		},
+	}
+
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := expandArgsFiles(tt.in)
+			assertError(t, err, tt.wantErr)
+
+			if tt.wantErr == "" && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("want %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParse_configFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	content := `{
+		"name": "file",
+		"port": 8081,
+		"host": "file-host",
+		"mongo": {"database": "file-db"},
+		"cache": null,
+		"tags": ["a", "b"],
+		"v1": {"addr": "v1-addr"},
+		"V2": {"addr": "v2-addr"}
+	}`
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &struct {
+		Name  string `def:"def"`
+		Port  int    `def:"8080"`
+		Host  string `json:"host"`
+		Level string `def:"info"`
+		Mongo struct {
+			Database string `def:"def-db"`
+			User     string `def:"def-user"`
+		}
+		Cache struct {
+			Size int `def:"10"`
+		}
+		Tags    StringSlice `def:"x"`
+		Skip    string      `def:"skip" json:"-"`
+		Version string      `def:"v2" version:""`
+		V1      struct {
+			Addr string `def:":1"`
+		} `version:"v1"`
+		V2 struct {
+			Addr string `def:":2"`
+		} `version:"v2"`
+	}{}
+	cl := newCommandLine("test")
+	cl.errorHandling = flag.ContinueOnError
+	cl.configFile = path
+	cl.lookupEnvFunc = func(env string) (string, bool) {
+		return "env-host", env == "TEST_HOST"
+	}
+
+	err := cl.parse(cfg, []string{"-port", "9090"})
+	assertError(t, err, "")
+
+	if cfg.Name != "file" {
+		t.Errorf("want name from file, got %q", cfg.Name)
+	}
+	if cfg.Port != 9090 {
+		t.Errorf("want port from flag, got %d", cfg.Port)
+	}
+	if cfg.Host != "env-host" {
+		t.Errorf("want host from env, got %q", cfg.Host)
+	}
+	if cfg.Level != "info" {
+		t.Errorf("want level from def, got %q", cfg.Level)
+	}
+	if cfg.Mongo.Database != "file-db" || cfg.Mongo.User != "def-user" {
+		t.Errorf("want nested database from file and user from def, got %+v", cfg.Mongo)
+	}
+	if want := (StringSlice{"a", "b"}); !reflect.DeepEqual(cfg.Tags, want) {
+		t.Errorf("want tags %v from file, got %v", want, cfg.Tags)
+	}
+	if cfg.Cache.Size != 10 || cfg.Skip != "skip" {
+		t.Errorf("want cache size and skip from def, got %d %q", cfg.Cache.Size, cfg.Skip)
+	}
+	if cfg.V2.Addr != "v2-addr" {
+		t.Errorf("want selected version addr from file, got %q", cfg.V2.Addr)
+	}
+
+	var dump bytes.Buffer
+	cl.dumpConfig(&dump)
+
+	for _, want := range []string{"name=file (file)\n", "host=env-host (env)\n", "port=9090 (flag)\n", "level=info (default)\n"} {
+		if !strings.Contains(dump.String(), want) {
+			t.Errorf("want dump to contain %q, got %q", want, dump.String())
+		}
+	}
+}
+
+func TestParse_configFileErrors(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	unknown := filepath.Join(dir, "unknown.json")
+	if err := os.WriteFile(unknown, []byte(`{"port": 80, "prot": 81}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(invalid, []byte(`{"port": `), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	mistyped := filepath.Join(dir, "mistyped.json")
+	if err := os.WriteFile(mistyped, []byte(`{"port": "80"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	trailing := filepath.Join(dir, "trailing.json")
+	if err := os.WriteFile(trailing, []byte(`{"port": 80} garbage`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	second := filepath.Join(dir, "second.json")
+	if err := os.WriteFile(second, []byte(`{"port": 80}{"port": 81}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	missing := filepath.Join(dir, "missing.json")
+
+	tests := map[string]struct {
+		path    string
+		wantErr string
+	}{
+		"trailing-data": {
+			path:    trailing,
+			wantErr: "config file " + trailing + ": unexpected data after config",
+		},
+		"second-value": {
+			path:    second,
+			wantErr: "config file " + second + ": unexpected data after config",
+		},
+		"unknown-key": {
+			path:    unknown,
+			wantErr: "config file " + unknown + `: json: unknown field "prot"`,
+		},
+		"invalid-json": {
+			path:    invalid,
+			wantErr: "config file " + invalid + ": unexpected EOF",
+		},
+		"mistyped-value": {
+			path:    mistyped,
+			wantErr: "config file " + mistyped + ": json: cannot unmarshal string into Go struct field .port of type int",
+		},
+		"missing-file": {
+			path:    missing,
+			wantErr: "config file: open " + missing + ": no such file or directory",
+		},
+	}
+
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			cl := newCommandLine("test")
+			cl.errorHandling = flag.ContinueOnError
+			cl.configFile = tt.path
+
+			err := cl.parse(&struct {
+				Port int
+			}{}, []string{})
+			assertError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestParse_configFileKeys(t *testing.T) {
+	t.Parallel()
+
+	type db struct {
+		Host string `def:"def"`
+		Name string `def:"def"`
+		Port int    `def:"5432"`
+	}
+
+	tests := map[string]struct {
+		file    string
+		content string
+	}{
+		"json": {
+			file:    "config.json",
+			content: `{"hosts": ["a", "b"], "defaults": {"host": "base"}, "db": {"host": "base", "name": "app"}}`,
+		},
+		"yaml-merge": {
+			file:    "config.yaml",
+			content: "hosts: [a, b]\ndefaults: &defaults\n  host: base\ndb:\n  <<: *defaults\n  name: app\n",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg := &struct {
+				Hosts    StringSlice
+				Defaults struct{ Host string }
+				DB       db
+			}{}
+			cl := newCommandLine("test")
+			cl.errorHandling = flag.ContinueOnError
+			cl.configFile = path
+			cl.lookupEnvFunc = func(string) (string, bool) { return "", false }
+
+			assertError(t, cl.parse(cfg, []string{}), "")
+
+			if cfg.DB.Host != "base" || cfg.DB.Name != "app" || cfg.DB.Port != 5432 || len(cfg.Hosts) != 2 {
+				t.Fatalf("want values from file, got %+v", cfg)
+			}
+
+			for _, flagName := range []string{"hosts", "defaults-host", "db-host", "db-name"} {
+				if got := cl.sources[flagName]; got != "file" {
+					t.Errorf("want %s source file, got %q", flagName, got)
+				}
+			}
+			if got := cl.sources["db-port"]; got != "default" {
+				t.Errorf("want db-port source default, got %q", got)
+			}
+		})
+	}
+}
+
+func TestParse_configFileYAML(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+name: file
+port: 8081
+timeout: 5s
+mongo:
+  db: file-db
+tags: [a, b]
+`
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &struct {
+		Name    string        `def:"def"`
+		Port    int           `def:"8080"`
+		Timeout time.Duration `def:"1s"`
+		Level   string        `def:"info"`
+		Mongo   struct {
+			Database string `def:"def-db" yaml:"db"`
+			User     string `def:"def-user"`
+		}
+		Tags StringSlice `def:"x"`
+	}{}
+	cl := newCommandLine("test")
+	cl.errorHandling = flag.ContinueOnError
+	cl.configFile = path
+	cl.lookupEnvFunc = func(env string) (string, bool) {
+		return "env", env == "TEST_NAME"
+	}
+
+	err := cl.parse(cfg, []string{"-port", "9090"})
+	assertError(t, err, "")
+
+	if cfg.Name != "env" || cfg.Port != 9090 || cfg.Timeout != 5*time.Second || cfg.Level != "info" {
+		t.Errorf("want name from env, port from flag, timeout from file and level from def, got %+v", cfg)
+	}
+	if cfg.Mongo.Database != "file-db" || cfg.Mongo.User != "def-user" {
+		t.Errorf("want nested database from file and user from def, got %+v", cfg.Mongo)
+	}
+	if want := (StringSlice{"a", "b"}); !reflect.DeepEqual(cfg.Tags, want) {
+		t.Errorf("want tags %v from file, got %v", want, cfg.Tags)
+	}
+}
+
+type EmbeddedLogConfig struct {
+	LogLevel string `def:"info"`
+	Verbose  bool
+}
+
+type EmbeddedConnConfig struct {
+	Host string `def:"localhost"`
+	Port int    `def:"5432"`
+}
+
+func TestParse_embedded(t *testing.T) {
+	t.Parallel()
+
+	cfg := &struct {
+		EmbeddedLogConfig
+		DB struct {
+			EmbeddedConnConfig
+			Name string
+		}
+	}{}
+	cl := newCommandLine("test")
+	cl.errorHandling = flag.ContinueOnError
+	cl.lookupEnvFunc = func(env string) (string, bool) {
+		switch env {
+		case "TEST_LOG_LEVEL":
+			return "debug", true
+		case "TEST_DB_HOST":
+			return "db", true
+		default:
+			return "", false
+		}
+	}
+
+	err := cl.parse(cfg, []string{"--verbose", "--db-port", "6432", "--db-name", "app"})
+	assertError(t, err, "")
+
+	if cfg.LogLevel != "debug" || !cfg.Verbose {
+		t.Errorf("want promoted log fields from env and flag, got %+v", cfg.EmbeddedLogConfig)
+	}
+	if cfg.DB.Host != "db" || cfg.DB.Port != 6432 || cfg.DB.Name != "app" {
+		t.Errorf("want promoted db fields with db prefix, got %+v", cfg.DB)
+	}
+	if usage := cl.flagSet.Lookup("db-host").Usage; usage != "db host (env TEST_DB_HOST)" {
+		t.Errorf("want usage of promoted field, got %q", usage)
+	}
+}
+
+func TestParse_ref(t *testing.T) {
+	t.Parallel()
+
+	type mongo struct {
+		URI      string `def:"mongodb://localhost"`
+		Database string `req:""`
+	}
+
+	cfg := &struct {
+		Mongo   mongo
+		Archive mongo `ref:"Mongo"`
+	}{}
+	cl := newCommandLine("test")
+	cl.errorHandling = flag.ContinueOnError
+	cl.lookupEnvFunc = func(env string) (string, bool) {
+		if env == "TEST_MONGO_URI" {
+			return "mongodb://db", true
+		}
+
+		return "", false
+	}
+
+	err := cl.parse(cfg, []string{"--mongo-database", "app"})
+	assertError(t, err, "")
+
+	if cfg.Archive != cfg.Mongo || cfg.Archive.URI != "mongodb://db" || cfg.Archive.Database != "app" {
+		t.Errorf("want archive to resolve to mongo %+v, got %+v", cfg.Mongo, cfg.Archive)
+	}
+	if cl.flagSet.Lookup("archive-database") != nil {
+		t.Error("want no flags for referencing field")
+	}
+}
+
+func TestParse_refErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		config any
+		want   string
+	}{
+		"unknown": {
+			config: &struct {
+				A string `ref:"B"`
+			}{},
+			want: `A ref: unknown field "B"`,
+		},
+		"ignored": {
+			config: &struct {
+				A string `ref:"B"`
+				B string `bee:"-"`
+			}{},
+			want: `A ref: unknown field "B"`,
+		},
+		"type": {
+			config: &struct {
+				A string `ref:"B"`
+				B int
+			}{},
+			want: "A ref: type string does not match int of B",
+		},
+		"reference": {
+			config: &struct {
+				A string `ref:"B"`
+				B string `ref:"C"`
+				C string
+			}{},
+			want: `A ref: field "B" is a reference`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			cl := newCommandLine("test")
+			cl.errorHandling = flag.ContinueOnError
+			cl.lookupEnvFunc = func(string) (string, bool) { return "", false }
+
+			assertError(t, cl.parse(tt.config, nil), tt.want)
+		})
+	}
+}
+
+func TestParse_embeddedConfigFile(t *testing.T) {
+	t.Parallel()
+
+	type config struct {
+		EmbeddedConnConfig `yaml:",inline"`
+		Name               string `def:"def"`
+	}
+
+	tests := map[string]struct {
+		file    string
+		content string
+	}{
+		"json": {
+			file:    "config.json",
+			content: `{"host": "file", "port": 7000}`,
+		},
+		"yaml": {
+			file:    "config.yaml",
+			content: "host: file\nport: 7000\n",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg := &config{}
+			cl := newCommandLine("test")
+			cl.errorHandling = flag.ContinueOnError
+			cl.configFile = path
+			cl.lookupEnvFunc = func(string) (string, bool) { return "", false }
+
+			err := cl.parse(cfg, []string{"--port", "9090"})
+			assertError(t, err, "")
+
+			if cfg.Host != "file" || cfg.Port != 9090 || cfg.Name != "def" {
+				t.Errorf("want host from file, port from flag and name from def, got %+v", cfg)
+			}
+			if got := cl.sources["host"]; got != "file" {
+				t.Errorf("want host source file, got %q", got)
+			}
+		})
+	}
+}
+
+func TestParse_embeddedConfigFileNested(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("embeddedconnconfig:\n  port: 9000\nname: file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &struct {
+		EmbeddedConnConfig
+		Name string
+	}{}
+	cl := newCommandLine("test")
+	cl.errorHandling = flag.ContinueOnError
+	cl.configFile = path
+	cl.lookupEnvFunc = func(string) (string, bool) { return "", false }
+
+	assertError(t, cl.parse(cfg, []string{}), "")
+
+	if cfg.Port != 9000 || cfg.Host != "localhost" || cfg.Name != "file" {
+		t.Errorf("want port from nested file key and host from def, got %+v", cfg)
+	}
+	if got := cl.sources["port"]; got != "file" {
+		t.Errorf("want port source file, got %q", got)
+	}
+}
+func TestParse_configFileYAMLErrors(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	unknown := filepath.Join(dir, "unknown.yml")
+	if err := os.WriteFile(unknown, []byte("port: 80\nprot: 81\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := filepath.Join(dir, "invalid.yaml")
+	if err := os.WriteFile(invalid, []byte("port: [80"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cased := filepath.Join(dir, "cased.yaml")
+	if err := os.WriteFile(cased, []byte("Port: 80\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	second := filepath.Join(dir, "second.yaml")
+	if err := os.WriteFile(second, []byte("port: 80\n---\nport: 81\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		path    string
+		wantErr string
+	}{
+		"second-document": {
+			path:    second,
+			wantErr: "config file " + second + ": unexpected data after config",
 		},
-		"url-env-set": {
-			config: &struct {
-				Endpoint URL `def:"http://localhost"`
-			}{},
-			lookupEnvFunc: func(env string) (string, bool) {
-				return "https://api.example.com/v1", true
-			},
-			wantURL: URL{URL: &url.URL{Scheme: "https", Host: "api.example.com", Path: "/v1"}}, //nolint:exhaustruct
+		"unknown-key": {
+			path:    unknown,
+			wantErr: "config file " + unknown + ": yaml: unmarshal errors:\n  line 2: field prot not found in type struct { Port int }",
 		},
-		"string-slice-env-not-set-def-not-set": {
-			config: &struct {
-				Buckets StringSlice
-			}{},
-			lookupEnvFunc: func(env string) (string, bool) {
-				return "", false
-			},
-			wantStringSlice: StringSlice{},
+		"invalid-yaml": {
+			path:    invalid,
+			wantErr: "config file " + invalid + ": yaml: line 1: did not find expected ',' or ']'",
 		},
-		"string-slice-env-not-set-def-set": {
-			config: &struct {
-				Buckets StringSlice `def:"foo,bar,baz"`
-			}{},
-			lookupEnvFunc: func(env string) (string, bool) {
-				return "", false
-			},
-			wantStringSlice: StringSlice{"foo", "bar", "baz"},
+		"field-name-not-lowercased": {
+			path:    cased,
+			wantErr: "config file " + cased + ": yaml: unmarshal errors:\n  line 1: field Port not found in type struct { Port int }",
 		},
-		"string-slice-env-set": {
-			config: &struct {
-				Buckets StringSlice `def:"foo,bar,baz"`
-			}{},
-			lookupEnvFunc: func(env string) (string, bool) {
-				return "qux,bar", true
-			},
-			wantStringSlice: StringSlice{"qux", "bar"},
+	}
+
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			cl := newCommandLine("test")
+			cl.errorHandling = flag.ContinueOnError
+			cl.configFile = tt.path
+
+			err := cl.parse(&struct {
+				Port int
+			}{}, []string{})
+			assertError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestParse_optionalConfigFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	empty := filepath.Join(dir, "empty.yaml")
+	if err := os.WriteFile(empty, []byte(""), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{filepath.Join(dir, "missing.yaml"), empty} {
+		cfg := &struct {
+			Port int `def:"8080"`
+		}{}
+		cl := newCommandLine("test")
+		cl.errorHandling = flag.ContinueOnError
+		cl.configFile = path
+		cl.configOptional = true
+
+		assertError(t, cl.parse(cfg, []string{}), "")
+
+		if cfg.Port != 8080 {
+			t.Fatalf("want port from def, got %d", cfg.Port)
+		}
+	}
+}
+
+func TestParse_configFileFlag(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"default.json": `{"name": "default", "port": 1}`,
+		"flag.json":    `{"name": "flag", "port": 2}`,
+		"env.yaml":     "name: env\nport: 3\n",
+	}
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := map[string]struct {
+		flags    []string
+		env      string
+		wantName string
+		wantPort int
+	}{
+		"default": {
+			wantName: "default",
+			wantPort: 1,
 		},
-		"int-slice-env-not-set-def-not-set": {
-			config: &struct {
-				DailyTemperatures IntSlice
-			}{},
-			lookupEnvFunc: func(env string) (string, bool) {
-				return "", false
-			},
-			wantIntSlice: IntSlice{},
+		"flag": {
+			flags:    []string{"--config", filepath.Join(dir, "flag.json")},
+			env:      filepath.Join(dir, "env.yaml"),
+			wantName: "flag",
+			wantPort: 2,
 		},
-		"int-slice-env-not-set-def-set": {
+		"flag-with-equals": {
+			flags:    []string{"-config=" + filepath.Join(dir, "flag.json"), "-port", "9090"},
+			wantName: "flag",
+			wantPort: 9090,
+		},
+		"env": {
+			env:      filepath.Join(dir, "env.yaml"),
+			wantName: "env",
+			wantPort: 3,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := &struct {
+				Name string
+				Port int
+			}{}
+			cl := newCommandLine("test")
+			cl.errorHandling = flag.ContinueOnError
+			cl.configFile = filepath.Join(dir, "default.json")
+			cl.configFlag = "config"
+			cl.configEnv = "TEST_CONFIG_FILE"
+			cl.lookupEnvFunc = func(env string) (string, bool) {
+				return tt.env, env == "TEST_CONFIG_FILE" && tt.env != ""
+			}
+
+			assertError(t, cl.parse(cfg, tt.flags), "")
+
+			if cfg.Name != tt.wantName || cfg.Port != tt.wantPort {
+				t.Fatalf("want name %q and port %d, got %q and %d", tt.wantName, tt.wantPort, cfg.Name, cfg.Port)
+			}
+		})
+	}
+}
+
+func TestParse_configFileFlagErrors(t *testing.T) {
+	t.Parallel()
+
+	missing := filepath.Join(t.TempDir(), "missing.json")
+
+	tests := map[string]struct {
+		config  any
+		flags   []string
+		wantErr string
+	}{
+		"missing-selected-file": {
 			config: &struct {
-				DailyTemperatures IntSlice `def:"10,-5,0"`
+				Port int
 			}{},
-			lookupEnvFunc: func(env string) (string, bool) {
-				return "", false
-			},
-			wantIntSlice: IntSlice{10, -5, 0},
+			flags:   []string{"--config", missing},
+			wantErr: "config file: open " + missing + ": no such file or directory",
 		},
-		"int-slice-env-set": {
+		"duplicate-flag": {
 			config: &struct {
-				DailyTemperatures IntSlice `def:"10,-5,0"`
+				Config string
 			}{},
-			lookupEnvFunc: func(env string) (string, bool) {
-				return "-2,3,-1", true
-			},
-			wantIntSlice: IntSlice{-2, 3, -1},
+			wantErr: `Config def: duplicate flag "config": invalid config type`,
 		},
 	}
 
-	for n, tt := range tests { //nolint:paralleltest
-
-		t.Run(n, func(t *testing.T) {
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
 			cl := newCommandLine("test")
 			cl.errorHandling = flag.ContinueOnError
-			cl.lookupEnvFunc = tt.lookupEnvFunc
+			cl.configOptional = true
+			cl.configFlag = "config"
 
-			if err := cl.parse(tt.config, []string{}); err != nil {
-				t.Error(err)
-			}
+			assertError(t, cl.parse(tt.config, tt.flags), tt.wantErr)
+		})
+	}
+}
 
-			v := reflect.ValueOf(tt.config).Elem()
+func TestParse_timeSlice(t *testing.T) {
+	t.Parallel()
 
-			p := v.FieldByIndex([]int{0}).Addr().Interface()
+	first := time.Date(2024, time.January, 6, 22, 0, 0, 0, time.UTC)
+	second := time.Date(2024, time.January, 13, 22, 0, 0, 0, time.UTC)
 
-			switch p := p.(type) {
-			case *string:
-				if *p != tt.wantString {
-					t.Errorf("want %q, got %q", tt.wantString, *p)
-				}
-			case *bool:
-				if *p != tt.wantBool {
-					t.Errorf("want %t, got %t", tt.wantBool, *p)
-				}
-			case *uint:
-				if *p != tt.wantUint {
-					t.Errorf("want %d, got %d", tt.wantUint, *p)
-				}
-			case *uint64:
-				if *p != tt.wantUint64 {
-					t.Errorf("want %d, got %d", tt.wantUint64, *p)
-				}
-			case *int:
-				if *p != tt.wantInt {
-					t.Errorf("want %d, got %d", tt.wantInt, *p)
-				}
-			case *int64:
-				if *p != tt.wantInt64 {
-					t.Errorf("want %d, got %d", tt.wantInt64, *p)
-				}
-			case *float64:
-				if *p != tt.wantFloat64 {
-					t.Errorf("want %f, got %f", tt.wantFloat64, *p)
-				}
-			case *time.Duration:
-				if *p != tt.wantDuration {
-					t.Errorf("want %v, got %v", tt.wantDuration, *p)
-				}
-			case *URL:
-				if !reflect.DeepEqual(*p, tt.wantURL) {
-					t.Errorf("want %#v, got %#v", tt.wantURL, *p)
-				}
-			case *StringSlice:
-				if !reflect.DeepEqual(*p, tt.wantStringSlice) {
-					t.Errorf("want %#v, got %#v", tt.wantStringSlice, *p)
-				}
-			case *IntSlice:
-				if !reflect.DeepEqual(*p, tt.wantIntSlice) {
-					t.Errorf("want %#v, got %#v", tt.wantIntSlice, *p)
-				}
-			default:
-				t.Errorf("type %T not supported", p)
-			}
-		})
+	cfg := &struct {
+		Windows     TimeSlice   `def:"2024-01-06T22:00:00Z"`
+		Maintenance []time.Time `sep:";"`
+		Empty       []time.Time
+	}{}
+	cl := newCommandLine("test")
+	cl.errorHandling = flag.ContinueOnError
+	cl.lookupEnvFunc = func(env string) (string, bool) {
+		return "2024-01-06T22:00:00Z;2024-01-13T22:00:00Z", env == "TEST_MAINTENANCE"
+	}
+
+	err := cl.parse(cfg, []string{"-windows", "2024-01-13T22:00:00Z,2024-01-06T22:00:00Z"})
+	assertError(t, err, "")
+
+	if want := (TimeSlice{second, first}); !reflect.DeepEqual(cfg.Windows, want) {
+		t.Errorf("want windows %v, got %v", want, cfg.Windows)
+	}
+	if want := []time.Time{first, second}; !reflect.DeepEqual(cfg.Maintenance, want) {
+		t.Errorf("want maintenance %v, got %v", want, cfg.Maintenance)
+	}
+	if want := []time.Time{}; !reflect.DeepEqual(cfg.Empty, want) {
+		t.Errorf("want empty %v, got %v", want, cfg.Empty)
 	}
 }
 
-func TestWithUsage(t *testing.T) {
+func TestParse_timeSliceErrors(t *testing.T) {
 	t.Parallel()
 
-	b := &bytes.Buffer{}
+	cl := newCommandLine("test")
+	cl.errorHandling = flag.ContinueOnError
 
-	cl := newCommandLine("me")
+	err := cl.parse(&struct {
+		Maintenance []time.Time `def:"2024-01-06T22:00:00Z,soon"`
+	}{}, []string{})
+
+	assertError(t, err, `Maintenance def: parsing time element 1: `+
+		`parsing time "soon" as "2006-01-02T15:04:05Z07:00": cannot parse "soon" as "2006"`)
+}
+
+func TestParse_array(t *testing.T) {
+	t.Parallel()
+
+	cfg := &struct {
+		Pair    [2]string `def:"foo,bar"`
+		Ports   [3]int
+		Timeout [2]time.Duration `def:"1s,2s"`
+	}{}
+	cl := newCommandLine("test")
 	cl.errorHandling = flag.ContinueOnError
-	cl.output = b
-	cl.flagSet.Usage = func() {
-		_, _ = fmt.Fprintf(cl.output, "Usage of %s %s:\n", "test", cl.name)
-		cl.flagSet.PrintDefaults()
+	cl.lookupEnvFunc = func(env string) (string, bool) {
+		if env == "TEST_PORTS" {
+			return "80,443,8080", true
+		}
+
+		return "", false
 	}
 
-	_ = cl.parse(&struct{}{}, []string{"-h"})
+	err := cl.parse(cfg, []string{"--pair", "baz,qux"})
+	assertError(t, err, "")
 
-	if want := "Usage of test me:\n"; want != b.String() {
-		t.Errorf("want %q got %q", want, b.String())
+	if want := [2]string{"baz", "qux"}; cfg.Pair != want {
+		t.Fatalf("want pair %v, got %v", want, cfg.Pair)
+	}
+	if want := [3]int{80, 443, 8080}; cfg.Ports != want {
+		t.Fatalf("want ports %v, got %v", want, cfg.Ports)
+	}
+	if want := [2]time.Duration{time.Second, 2 * time.Second}; cfg.Timeout != want {
+		t.Fatalf("want timeout %v, got %v", want, cfg.Timeout)
+	}
+}
+
+func TestParse_arrayErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		config  any
+		flags   []string
+		wantErr string
+	}{
+		"def-too-many": {
+			config: &struct {
+				Pair [2]string `def:"foo,bar,baz"`
+			}{},
+			wantErr: `Pair def: parsing array: got 3 elements, want 2`,
+		},
+		"def-invalid-element": {
+			config: &struct {
+				Ports [2]int `def:"80,a"`
+			}{},
+			wantErr: `Ports def: parsing array element 1: parsing int: strconv.ParseInt: parsing "a": invalid syntax`,
+		},
+		"flag-too-few": {
+			config: &struct {
+				Pair [2]string
+			}{},
+			flags:   []string{"--pair", "foo"},
+			wantErr: `invalid value "foo" for flag -pair: parsing array: got 1 elements, want 2`,
+		},
+		"unsupported-element": {
+			config: &struct {
+				Pair [2]struct{}
+			}{},
+			flags:   []string{"--pair", ","},
+			wantErr: `invalid value "," for flag -pair: parsing array element 0: type not supported: struct {}`,
+		},
+	}
+
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			cl := newCommandLine("test")
+			cl.errorHandling = flag.ContinueOnError
+			cl.output = io.Discard
+			cl.flagSet.SetOutput(io.Discard)
+			cl.lookupEnvFunc = func(string) (string, bool) {
+				return "", false
+			}
+
+			err := cl.parse(tt.config, tt.flags)
+			assertError(t, err, tt.wantErr)
+		})
 	}
 }
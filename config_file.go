@@ -0,0 +1,339 @@
+package bee
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileFormat describes how config files of one format are decoded and how their keys are matched
+// to struct fields.
+type fileFormat struct {
+	// tag is the struct tag overriding key of the field.
+	tag string
+	// key returns the key of the field without tag.
+	key func(name string) string
+	// foldCase enables case-insensitive matching of keys.
+	foldCase bool
+	// decode decodes r into config rejecting unknown keys and trailing data and returns keys
+	// present in the file.
+	decode func(r io.ReadSeeker, config any) (map[string]any, error)
+	// inline reports whether the decoder promotes keys of the embedded struct field.
+	inline func(field reflect.StructField) bool
+}
+
+var (
+	jsonFormat = fileFormat{
+		tag:      "json",
+		key:      func(name string) string { return name },
+		foldCase: true,
+		decode:   decodeJSON,
+		inline: func(field reflect.StructField) bool {
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+
+			return name == ""
+		},
+	}
+	yamlFormat = fileFormat{
+		tag:      "yaml",
+		key:      strings.ToLower,
+		foldCase: false,
+		decode:   decodeYAML,
+		inline: func(field reflect.StructField) bool {
+			_, opts, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+
+			return slices.Contains(strings.Split(opts, ","), "inline")
+		},
+	}
+)
+
+// configFileFormat selects format of the config file by its extension, JSON by default.
+func configFileFormat(path string) fileFormat {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return yamlFormat
+	default:
+		return jsonFormat
+	}
+}
+
+// loadConfigFile decodes the config file at path into config as the base layer and returns flag
+// names of the fields present in the file. Unknown keys are rejected. Memory use is not bounded:
+// json.Decoder buffers the whole top-level value and the YAML key pass builds the node tree of the
+// document, so it grows with the size of the file.
+func (cl *commandLine) loadConfigFile(config any, path string, optional bool) (map[string]struct{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if optional && errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("config file: %w", err)
+	}
+	defer f.Close()
+
+	format := configFileFormat(path)
+
+	keys, err := format.decode(f, config)
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	fields := map[string]struct{}{}
+	cl.collectFileFields(reflect.TypeOf(config).Elem(), keys, "", format, fields)
+
+	return fields, nil
+}
+
+// errTrailingData is returned when the config file contains data after the decoded value.
+var errTrailingData = errors.New("unexpected data after config")
+
+// decodeJSON decodes config directly from r and, after rewinding r, collects the keys present in
+// the file using a token pass instead of decoding the file again into a map.
+func decodeJSON(r io.ReadSeeker, config any) (map[string]any, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(config); err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, errTrailingData
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	keys, err := jsonKeys(json.NewDecoder(r))
+	if err != nil {
+		return nil, err
+	}
+
+	nested, _ := keys.(map[string]any)
+
+	return nested, nil
+}
+
+// jsonKeys reads the next JSON value from dec and returns nested maps of the object keys it
+// contains, or nil for other values.
+func jsonKeys(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		keys := map[string]any{}
+
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err //nolint:wrapcheck
+			}
+
+			value, err := jsonKeys(dec)
+			if err != nil {
+				return nil, err
+			}
+
+			keys[key.(string)] = value //nolint:forcetypeassert
+		}
+
+		_, err = dec.Token()
+
+		return keys, err //nolint:wrapcheck
+	case json.Delim('['):
+		for dec.More() {
+			if _, err := jsonKeys(dec); err != nil {
+				return nil, err
+			}
+		}
+
+		_, err = dec.Token()
+
+		return nil, err //nolint:wrapcheck
+	default:
+		return nil, nil
+	}
+}
+
+// decodeYAML decodes config directly from r and, after rewinding r, collects the keys present in
+// the file from its node tree.
+func decodeYAML(r io.ReadSeeker, config any) (map[string]any, error) {
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+
+	if err := dec.Decode(config); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+
+		return nil, err //nolint:wrapcheck
+	}
+
+	var next yaml.Node
+	if err := dec.Decode(&next); !errors.Is(err, io.EOF) {
+		if err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+
+		return nil, errTrailingData
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	var node yaml.Node
+	if err := yaml.NewDecoder(r).Decode(&node); err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	keys, _ := yamlKeys(&node).(map[string]any)
+
+	return keys, nil
+}
+
+// yamlKeys returns nested maps of the mapping keys node contains, following aliases and merge
+// keys like the decoder, or nil for other nodes.
+func yamlKeys(node *yaml.Node) any {
+	switch node.Kind { //nolint:exhaustive
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil
+		}
+
+		return yamlKeys(node.Content[0])
+	case yaml.AliasNode:
+		return yamlKeys(node.Alias)
+	case yaml.MappingNode:
+		keys := map[string]any{}
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Tag == "!!merge" {
+				mergeYAMLKeys(keys, value)
+
+				continue
+			}
+
+			keys[key.Value] = yamlKeys(value)
+		}
+
+		return keys
+	default:
+		return nil
+	}
+}
+
+// mergeYAMLKeys adds keys of the mapping, or sequence of mappings, merged by the merge key.
+func mergeYAMLKeys(keys map[string]any, node *yaml.Node) {
+	merged := []*yaml.Node{node}
+	if node.Kind == yaml.SequenceNode {
+		merged = node.Content
+	}
+
+	for _, m := range merged {
+		if mk, ok := yamlKeys(m).(map[string]any); ok {
+			for key, value := range mk {
+				if _, exists := keys[key]; !exists {
+					keys[key] = value
+				}
+			}
+		}
+	}
+}
+
+// collectFileFields adds flag names of the fields present in keys decoded from the config file.
+func (cl *commandLine) collectFileFields(
+	t reflect.Type,
+	keys map[string]any,
+	prefix string,
+	format fileFormat,
+	fields map[string]struct{},
+) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if ignoredField(field) {
+			continue
+		}
+
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && format.inline(field) {
+			cl.collectFileFields(field.Type, keys, prefix, format, fields)
+
+			continue
+		}
+
+		value, ok := format.lookup(keys, field)
+		if !ok {
+			continue
+		}
+
+		if field.Type.Kind() == reflect.Struct && !isSpecialStructType(field.Type) {
+			nested, ok := value.(map[string]any)
+			if !ok {
+				continue
+			}
+
+			// Fields of embedded structs are promoted to flags without prefix, even if the file
+			// nests them under the key of the embedded struct.
+			newPrefix := cl.newPrefix(field, prefix)
+			if field.Anonymous {
+				newPrefix = prefix
+			}
+
+			if version := field.Tag.Get("version"); version != "" && prefix == "" {
+				if version != cl.version {
+					continue
+				}
+
+				newPrefix = ""
+			}
+
+			cl.collectFileFields(field.Type, nested, newPrefix, format, fields)
+
+			continue
+		}
+
+		fields[cl.flagName(field, prefix)] = struct{}{}
+	}
+}
+
+// lookup finds the value of the field the same way the format's decoder matches keys, using the
+// format's tag or key derived from field name.
+func (f fileFormat) lookup(keys map[string]any, field reflect.StructField) (any, bool) {
+	name := f.key(field.Name)
+	if tag, _, _ := strings.Cut(field.Tag.Get(f.tag), ","); tag == "-" {
+		return nil, false
+	} else if tag != "" {
+		name = tag
+	}
+
+	if value, ok := keys[name]; ok {
+		return value, true
+	}
+
+	if !f.foldCase {
+		return nil, false
+	}
+
+	for key, value := range keys {
+		if strings.EqualFold(key, name) {
+			return value, true
+		}
+	}
+
+	return nil, false
+}
@@ -0,0 +1,150 @@
+package bee
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sync"
+)
+
+const (
+	healthOK           = "ok"
+	healthFail         = "fail"
+	healthShuttingDown = "shutting down"
+)
+
+// healthCheck is a named readiness check.
+type healthCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// healthResult is the JSON status of the application or a single check.
+type healthResult struct {
+	Name   string         `json:"name,omitempty"`
+	Status string         `json:"status"`
+	Error  string         `json:"error,omitempty"`
+	Checks []healthResult `json:"checks,omitempty"`
+}
+
+// RegisterHealthCheck registers check reported by HealthHandler.
+func (c Ctx[T]) RegisterHealthCheck(name string, check func(ctx context.Context) error) {
+	c.appRuntime().RegisterHealthCheck(name, check)
+}
+
+// LivenessHandler returns handler reporting the application is alive.
+func (c Ctx[T]) LivenessHandler() http.Handler {
+	return c.appRuntime().LivenessHandler()
+}
+
+// HealthHandler returns readiness handler running registered health checks.
+func (c Ctx[T]) HealthHandler() http.Handler {
+	return c.appRuntime().HealthHandler()
+}
+
+// RejectWhenShuttingDown returns middleware responding with 503 once graceful shutdown begins.
+func (c Ctx[T]) RejectWhenShuttingDown() func(next http.Handler) http.Handler {
+	return c.appRuntime().RejectWhenShuttingDown()
+}
+
+// RegisterHealthCheck registers check, i.e. database ping, reported by HealthHandler. Checks may
+// be registered while the application runs.
+func (a *App[T]) RegisterHealthCheck(name string, check func(ctx context.Context) error) {
+	a.healthMu.Lock()
+	defer a.healthMu.Unlock()
+
+	a.healthChecks = append(a.healthChecks, healthCheck{name: name, check: check})
+}
+
+// LivenessHandler returns handler, i.e. for /healthz, which always responds with 200 and
+// {"status":"ok"}, as the process serving the request is alive.
+func (a *App[T]) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+		writeHealth(res, http.StatusOK, healthResult{Status: healthOK}) //nolint:exhaustruct
+	})
+}
+
+// HealthHandler returns readiness handler, i.e. for /readyz, which runs registered health checks
+// concurrently with the request context and responds with 200 if all of them succeed, otherwise
+// with 503. JSON body lists the status of each check in registration order, i.e.
+// {"status":"fail","checks":[{"name":"db","status":"fail","error":"connection refused"}]}. Once
+// graceful shutdown begins, it responds with 503 and status "shutting down" without running the
+// checks, so load balancers stop routing requests to the instance.
+func (a *App[T]) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if a.Ctx.Err() != nil {
+			writeHealth(res, http.StatusServiceUnavailable, healthResult{Status: healthShuttingDown}) //nolint:exhaustruct,lll
+
+			return
+		}
+
+		a.healthMu.RLock()
+		checks := slices.Clone(a.healthChecks)
+		a.healthMu.RUnlock()
+
+		result := healthResult{Status: healthOK, Checks: runHealthChecks(req.Context(), checks)} //nolint:exhaustruct
+		status := http.StatusOK
+
+		for _, check := range result.Checks {
+			if check.Status != healthOK {
+				result.Status = healthFail
+				status = http.StatusServiceUnavailable
+			}
+		}
+
+		writeHealth(res, status, result)
+	})
+}
+
+// RejectWhenShuttingDown returns middleware which, once graceful shutdown begins, responds to new
+// requests with 503 and Connection: close header, so clients retry on another instance, while
+// requests already in flight finish. Rejected requests are logged by SlogLogger with rejected_by
+// attribute "shutting_down".
+func (a *App[T]) RejectWhenShuttingDown() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			if a.Ctx.Err() != nil {
+				SetRejection(req.Context(), "shutting_down")
+				res.Header().Set("Connection", "close")
+				http.Error(res, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+
+				return
+			}
+
+			next.ServeHTTP(res, req)
+		})
+	}
+}
+
+// runHealthChecks runs checks concurrently and returns their results in the same order.
+func runHealthChecks(ctx context.Context, checks []healthCheck) []healthResult {
+	results := make([]healthResult, len(checks))
+
+	var wg sync.WaitGroup
+
+	for i, hc := range checks {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			results[i] = healthResult{Name: hc.name, Status: healthOK} //nolint:exhaustruct
+			if err := hc.check(ctx); err != nil {
+				results[i].Status = healthFail
+				results[i].Error = err.Error()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return results
+}
+
+func writeHealth(res http.ResponseWriter, status int, result healthResult) {
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("Cache-Control", "no-store")
+	res.WriteHeader(status)
+	_ = json.NewEncoder(res).Encode(result)
+}
@@ -0,0 +1,112 @@
+// Package metrics provides Prometheus HTTP metrics middleware for bee apps.
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// unmatchedRoute is the route label of requests without matched ServeMux pattern.
+const unmatchedRoute = "unmatched"
+
+// Option configures Middleware and Handler.
+type Option func(*options)
+
+type options struct {
+	registerer prometheus.Registerer
+	gatherer   prometheus.Gatherer
+}
+
+// WithRegistry registers and exposes metrics using reg instead of the global default
+// Prometheus registry.
+func WithRegistry(reg *prometheus.Registry) Option {
+	return func(o *options) {
+		o.registerer = reg
+		o.gatherer = reg
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		registerer: prometheus.DefaultRegisterer,
+		gatherer:   prometheus.DefaultGatherer,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// Middleware records http_requests_total counter and
+// http_request_duration_seconds histogram labeled by method, route and code. The route is the
+// ServeMux pattern matched by the request, i.e. "GET /things/{id}", or "unmatched". Middleware
+// changing the request context between Middleware and the mux must copy the pattern back, as
+// bee middleware do. It panics if the metrics can not be registered.
+func Middleware(opts ...Option) func(next http.Handler) http.Handler {
+	options := newOptions(opts)
+	labels := []string{"method", "route", "code"}
+
+	requests := registerCollector(options.registerer, prometheus.NewCounterVec(prometheus.CounterOpts{ //nolint:exhaustruct
+		Name: "http_requests_total",
+		Help: "Total number of HTTP requests.",
+	}, labels))
+	durations := registerCollector(options.registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{ //nolint:exhaustruct,lll
+		Name:    "http_request_duration_seconds",
+		Help:    "Duration of HTTP requests in seconds.",
+		Buckets: prometheus.DefBuckets,
+	}, labels))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			writer := middleware.NewWrapResponseWriter(res, req.ProtoMajor)
+			start := time.Now()
+
+			next.ServeHTTP(writer, req)
+
+			status := writer.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			route := req.Pattern
+			if route == "" {
+				route = unmatchedRoute
+			}
+
+			values := []string{req.Method, route, strconv.Itoa(status)}
+			requests.WithLabelValues(values...).Inc()
+			durations.WithLabelValues(values...).Observe(time.Since(start).Seconds())
+		})
+	}
+}
+
+// Handler returns handler exposing metrics in Prometheus text format.
+func Handler(opts ...Option) http.Handler {
+	options := newOptions(opts)
+
+	return promhttp.HandlerFor(options.gatherer, promhttp.HandlerOpts{}) //nolint:exhaustruct
+}
+
+// registerCollector registers c or returns already registered equal collector, so Middleware
+// may be used multiple times with the same registry.
+func registerCollector[C prometheus.Collector](reg prometheus.Registerer, c C) C {
+	if err := reg.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(C); ok {
+				return existing
+			}
+		}
+
+		panic("metrics: registering collector: " + err.Error())
+	}
+
+	return c
+}
@@ -0,0 +1,74 @@
+package metrics_test
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.acim.net/bee"
+	"go.acim.net/bee/metrics"
+)
+
+func TestMiddleware(t *testing.T) {
+	t.Parallel()
+
+	reg := prometheus.NewRegistry()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /things/{id}", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	mux.HandleFunc("GET /empty", func(http.ResponseWriter, *http.Request) {})
+
+	var mws bee.Middlewares
+	mws.Add(metrics.Middleware(metrics.WithRegistry(reg)))
+	mws.Add(bee.RequestID())
+	mws.Add(bee.SlogLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	mws.Add(bee.Timeout(time.Second))
+	mws.Add(bee.StripSlashes())
+	handler := mws.Wrap(mux)
+
+	for _, path := range []string{"/things/1", "/things/2/", "/empty", "/missing"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	// using the same registry again reuses registered metrics
+	metrics.Middleware(metrics.WithRegistry(reg))
+
+	rec := httptest.NewRecorder()
+	metrics.Handler(metrics.WithRegistry(reg)).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	for _, want := range []string{
+		`http_requests_total{code="201",method="GET",route="GET /things/{id}"} 2`,
+		`http_requests_total{code="200",method="GET",route="GET /empty"} 1`,
+		`http_requests_total{code="404",method="GET",route="unmatched"} 1`,
+		`http_request_duration_seconds_count{code="201",method="GET",route="GET /things/{id}"} 2`,
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Fatalf("want metric %s, got %s", want, rec.Body.String())
+		}
+	}
+}
+
+func TestMiddlewarePanicsOnConflictingCollector(t *testing.T) {
+	t.Parallel()
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(prometheus.NewCounter(prometheus.CounterOpts{ //nolint:exhaustruct
+		Name: "http_requests_total",
+		Help: "Conflicting counter.",
+	}))
+
+	defer func() {
+		if got, _ := recover().(string); !strings.HasPrefix(got, "metrics: registering collector: ") {
+			t.Fatalf("want registering collector panic, got %q", got)
+		}
+	}()
+
+	metrics.Middleware(metrics.WithRegistry(reg))
+}
@@ -1,8 +1,24 @@
 package bee
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
+	"maps"
+	"mime"
+	"net"
 	"net/http"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-chi/chi/v5/middleware"
@@ -34,23 +50,754 @@ func (ms *Middlewares) Wrap(mux *http.ServeMux) http.Handler {
 	return wrapped
 }
 
-// SlogLogger is a middleware for slog logging.
+type handlerErrorKey struct{}
+
+// handlerError holds the error reported by a handler using SetHandlerError and the reason of
+// rejection reported by a middleware using SetRejection.
+type handlerError struct {
+	err        error
+	rejectedBy string
+}
+
+// serveWithContext serves the request with ctx and copies back the pattern set by ServeMux, so
+// middleware wrapping this one, like metrics.Middleware and tracing.Middleware, see the matched
+// route.
+func serveWithContext(next http.Handler, res http.ResponseWriter, req *http.Request, ctx context.Context) {
+	r := req.WithContext(ctx)
+	next.ServeHTTP(res, r)
+	req.Pattern = r.Pattern
+}
+
+// withHandlerError returns ctx with holder of the handler error, reusing the holder already
+// injected by another middleware, so SlogLogger and tracing.Middleware both see the error.
+func withHandlerError(ctx context.Context) (context.Context, *handlerError) {
+	if he, ok := ctx.Value(handlerErrorKey{}).(*handlerError); ok {
+		return ctx, he
+	}
+
+	he := &handlerError{} //nolint:exhaustruct
+
+	return context.WithValue(ctx, handlerErrorKey{}, he), he
+}
+
+// ContextWithHandlerError returns ctx holding the error reported by SetHandlerError and function
+// returning that error once the request is completed, so middleware outside of this package, like
+// tracing.Middleware, can report it.
+func ContextWithHandlerError(ctx context.Context) (context.Context, func() error) {
+	ctx, he := withHandlerError(ctx)
+
+	return ctx, func() error { return he.err }
+}
+
+// SetHandlerError records err to be included as error attribute in the access log written by
+// SlogLogger and in the span started by tracing.Middleware once the request is completed. It does
+// nothing if ctx doesn't come from a request handled by SlogLogger or ContextWithHandlerError.
+func SetHandlerError(ctx context.Context, err error) {
+	if he, ok := ctx.Value(handlerErrorKey{}).(*handlerError); ok {
+		he.err = err
+	}
+}
+
+// SetRejection records that a middleware, i.e. authentication or rate limiting, rejected the
+// request, so the access log written by SlogLogger includes rejected_by attribute with reason, i.e.
+// "ratelimit". It does nothing if ctx doesn't come from a request handled by SlogLogger or
+// ContextWithHandlerError.
+func SetRejection(ctx context.Context, reason string) {
+	if he, ok := ctx.Value(handlerErrorKey{}).(*handlerError); ok {
+		he.rejectedBy = reason
+	}
+}
+
+type logAttrsKey struct{}
+
+// ContextWithLogAttrs returns ctx with attrs appended to the attributes of the access log written
+// by SlogLogger, i.e. trace and span IDs added by tracing middleware wrapping SlogLogger.
+func ContextWithLogAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	return context.WithValue(ctx, logAttrsKey{}, append(slices.Clip(logAttrsFromContext(ctx)), attrs...))
+}
+
+// logAttrsFromContext returns attributes added by ContextWithLogAttrs.
+func logAttrsFromContext(ctx context.Context) []slog.Attr {
+	attrs, _ := ctx.Value(logAttrsKey{}).([]slog.Attr)
+
+	return attrs
+}
+
+// SlogLoggerOption configures SlogLoggerWithOptions.
+type SlogLoggerOption func(*slogLoggerOptions)
+
+type slogLoggerOptions struct {
+	skipPaths  []string
+	remoteAddr bool
+	userAgent  bool
+	humanSizes bool
+}
+
+// WithSkipPaths skips logging of requests whose path starts with one of prefixes, i.e. "/healthz".
+func WithSkipPaths(prefixes ...string) SlogLoggerOption {
+	return func(o *slogLoggerOptions) {
+		o.skipPaths = append(o.skipPaths, prefixes...)
+	}
+}
+
+// WithRemoteAddr adds the remote address of the request as remote_addr attribute.
+func WithRemoteAddr() SlogLoggerOption {
+	return func(o *slogLoggerOptions) {
+		o.remoteAddr = true
+	}
+}
+
+// WithUserAgent adds the User-Agent header of the request as user_agent attribute.
+func WithUserAgent() SlogLoggerOption {
+	return func(o *slogLoggerOptions) {
+		o.userAgent = true
+	}
+}
+
+// WithHumanSizes logs request and response sizes as human-readable byte sizes, i.e. "1.5 KiB",
+// instead of numbers of bytes.
+func WithHumanSizes() SlogLoggerOption {
+	return func(o *slogLoggerOptions) {
+		o.humanSizes = true
+	}
+}
+
+// SlogLogger is a middleware for slog logging. The response writer passed to the next handler
+// implements http.Flusher, http.Hijacker and http.Pusher if the original writer does. The request
+// ID and attributes added by ContextWithLogAttrs, like trace and span IDs of tracing.Middleware,
+// are logged when SlogLogger is used after the middleware providing them.
 func SlogLogger(log *slog.Logger) func(next http.Handler) http.Handler {
+	return SlogLoggerWithOptions(log)
+}
+
+// SlogLoggerWithOptions works like SlogLogger, but may skip requests and add attributes as
+// configured by options.
+func SlogLoggerWithOptions(log *slog.Logger, opts ...SlogLoggerOption) func(next http.Handler) http.Handler {
+	options := slogLoggerOptions{} //nolint:exhaustruct
+	for _, opt := range opts {
+		opt(&options)
+	}
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			if hasAnyPrefix(req.URL.Path, options.skipPaths) {
+				next.ServeHTTP(res, req)
+
+				return
+			}
+
 			writer := middleware.NewWrapResponseWriter(res, req.ProtoMajor)
 			start := time.Now()
+			ctx, he := withHandlerError(req.Context())
 
-			next.ServeHTTP(writer, req)
+			body := &countingReader{ReadCloser: req.Body} //nolint:exhaustruct
+			if req.Body != nil {
+				req.Body = body
+			}
+
+			serveWithContext(next, writer, req, ctx)
 
-			log.Info(
-				"request completed",
+			attrs := []any{
 				slog.Time("time", start),
 				slog.String("method", req.Method),
 				slog.String("uri", req.RequestURI),
 				slog.Int("status", writer.Status()),
-				slog.Int("bytes", writer.BytesWritten()),
-				slog.Duration("duration", time.Since(start)))
+				sizeAttr("request_bytes", body.n, options.humanSizes),
+				sizeAttr("bytes", int64(writer.BytesWritten()), options.humanSizes),
+				slog.Duration("duration", time.Since(start)),
+			}
+
+			if options.remoteAddr {
+				attrs = append(attrs, slog.String("remote_addr", req.RemoteAddr))
+			}
+
+			if options.userAgent {
+				attrs = append(attrs, slog.String("user_agent", req.UserAgent()))
+			}
+
+			for _, attr := range logAttrsFromContext(req.Context()) {
+				attrs = append(attrs, attr)
+			}
+
+			if id := RequestIDFromContext(req.Context()); id != "" {
+				attrs = append(attrs, slog.String("request_id", id))
+			}
+
+			if he.err != nil {
+				attrs = append(attrs, slog.Any("error", he.err))
+			}
+
+			if he.rejectedBy != "" {
+				attrs = append(attrs, slog.String("rejected_by", he.rejectedBy))
+			}
+
+			log.Info("request completed", attrs...)
+		})
+	}
+}
+
+// countingReader counts bytes read from the request body.
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+
+	return n, err //nolint:wrapcheck
+}
+
+// sizeAttr returns attribute with number of bytes n, formatted as byte size if human.
+func sizeAttr(key string, n int64, human bool) slog.Attr {
+	if human {
+		return slog.String(key, formatByteSize(n))
+	}
+
+	return slog.Int64(key, n)
+}
+
+// formatByteSize formats n bytes using binary units, i.e. "512 B" or "1.5 KiB".
+func formatByteSize(n int64) string {
+	const unit = 1024
+
+	if n < unit {
+		return strconv.FormatInt(n, 10) + " B"
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// requestIDHeader is the header used by RequestID to read and propagate request IDs.
+const requestIDHeader = "X-Request-Id"
+
+type requestIDKey struct{}
+
+// RequestID is a middleware which stores the request ID from the X-Request-Id header in the
+// request context, generating a random one when the header is absent, and sets it as the
+// X-Request-Id response header.
+func RequestID() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			id := req.Header.Get(requestIDHeader)
+			if id == "" {
+				id = newRequestID()
+			}
+
+			res.Header().Set(requestIDHeader, id)
+			serveWithContext(next, res, req, context.WithValue(req.Context(), requestIDKey{}, id))
+		})
+	}
+}
+
+// RequestIDFromContext returns the request ID stored by RequestID or empty string if there is none.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+
+	return id
+}
+
+// newRequestID returns 128 random bits encoded as hex.
+func newRequestID() string {
+	b := make([]byte, 16) //nolint:mnd
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+// Recoverer is a middleware which recovers panics of the next handler, logs the panic value with
+// stack trace and responds with 500 Internal Server Error. When used inside SlogLogger, the panic
+// is also included as error attribute in the access log. Panics with http.ErrAbortHandler are
+// re-panicked to preserve the semantics of net/http.
+func Recoverer(log *slog.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			defer func() {
+				r := recover()
+				if r == nil {
+					return
+				}
+
+				if err, ok := r.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+					panic(r)
+				}
+
+				log.Error("handler panic",
+					slog.String("method", req.Method),
+					slog.String("uri", req.RequestURI),
+					slog.Any("panic", r),
+					slog.String("stack", string(debug.Stack())),
+				)
+				SetHandlerError(req.Context(), fmt.Errorf("panic: %v", r))
+
+				http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}()
+
+			next.ServeHTTP(res, req)
+		})
+	}
+}
+
+// Timeout is a middleware which cancels the request context after d. The handler runs in its own
+// goroutine and if it doesn't finish before the deadline, Timeout responds with 503 Service
+// Unavailable, unless the handler already started the response, and returns, so slow handlers
+// don't hold the connection. Writes of the handler after the deadline are discarded and return
+// http.ErrHandlerTimeout. Handlers should still respect cancellation of the request context, i.e.
+// by passing it to downstream calls, as Timeout can't stop them and they keep running in the
+// background. Panics of the handler are propagated to the goroutine serving the request.
+func Timeout(d time.Duration) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			ctx, cancel := context.WithTimeout(req.Context(), d)
+			defer cancel()
+
+			writer := &timeoutWriter{res: res, header: res.Header().Clone()} //nolint:exhaustruct
+			r := req.WithContext(ctx)
+			done := make(chan struct{})
+			panicked := make(chan any, 1)
+
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicked <- p
+
+						return
+					}
+
+					close(done)
+				}()
+
+				next.ServeHTTP(writer, r)
+			}()
+
+			select {
+			case p := <-panicked:
+				panic(p)
+			case <-done:
+				req.Pattern = r.Pattern
+			case <-ctx.Done():
+				writer.timeout(errors.Is(ctx.Err(), context.DeadlineExceeded))
+			}
+		})
+	}
+}
+
+// timeoutWriter guards the response writer of the handler run by Timeout, so the handler and the
+// timeout response don't write concurrently. Headers set by the handler are copied to the
+// response when it starts.
+type timeoutWriter struct {
+	res      http.ResponseWriter
+	header   http.Header
+	mu       sync.Mutex
+	status   int
+	timedOut bool
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *timeoutWriter) WriteHeader(status int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.timedOut || w.status != 0 {
+		return
+	}
+
+	w.writeHeader(status)
+}
+
+func (w *timeoutWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+
+	if w.status == 0 {
+		w.writeHeader(http.StatusOK)
+	}
+
+	return w.res.Write(p) //nolint:wrapcheck
+}
+
+// Flush implements http.Flusher, so handlers may stream responses before the deadline.
+func (w *timeoutWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.timedOut {
+		return
+	}
+
+	if w.status == 0 {
+		w.writeHeader(http.StatusOK)
+	}
+
+	if f, ok := w.res.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *timeoutWriter) writeHeader(status int) {
+	maps.Copy(w.res.Header(), w.header)
+	w.status = status
+	w.res.WriteHeader(status)
+}
+
+// timeout discards further writes of the handler and, if the deadline was exceeded and the
+// response didn't start, responds with 503 Service Unavailable.
+func (w *timeoutWriter) timeout(deadlineExceeded bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.timedOut = true
+
+	if deadlineExceeded && w.status == 0 {
+		http.Error(w.res, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+	}
+}
+
+// Compress is a middleware which compresses responses of contentTypes with gzip or deflate, as
+// negotiated by the Accept-Encoding header, using compression level from compress/flate. Content
+// types may end with "/*" to match any subtype, and a default set of text types is compressed if
+// none are given. It sets Content-Encoding and Vary headers, skips responses which already have
+// Content-Encoding and flushes the compressed data when handlers flush.
+func Compress(level int, contentTypes ...string) func(next http.Handler) http.Handler {
+	return middleware.Compress(level, contentTypes...)
+}
+
+// ETag is a middleware which buffers successful responses to GET and HEAD requests, sets weak
+// ETag header computed from the response body, unless the handler set ETag itself, and responds
+// with 304 Not Modified without body if it matches the If-None-Match header of the request. Other
+// methods and responses with status other than 200 are passed through unchanged. As responses are
+// buffered, it should not be used for streamed responses and should be added after Compress, so
+// the ETag is computed from the uncompressed body.
+func ETag() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			if req.Method != http.MethodGet && req.Method != http.MethodHead {
+				next.ServeHTTP(res, req)
+
+				return
+			}
+
+			writer := &bufferedWriter{ResponseWriter: res} //nolint:exhaustruct
+
+			next.ServeHTTP(writer, req)
+
+			status := writer.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			if status != http.StatusOK {
+				res.WriteHeader(status)
+				_, _ = res.Write(writer.body.Bytes())
+
+				return
+			}
+
+			etag := res.Header().Get("ETag")
+			if etag == "" {
+				sum := sha256.Sum256(writer.body.Bytes())
+				etag = `W/"` + hex.EncodeToString(sum[:16]) + `"`
+				res.Header().Set("ETag", etag)
+			}
+
+			if etagMatch(req.Header.Get("If-None-Match"), etag) {
+				res.Header().Del("Content-Length")
+				res.Header().Del("Content-Type")
+				res.WriteHeader(http.StatusNotModified)
+
+				return
+			}
+
+			res.WriteHeader(status)
+			_, _ = res.Write(writer.body.Bytes())
+		})
+	}
+}
+
+// bufferedWriter buffers the response status and body, while headers are written to the wrapped
+// response writer.
+type bufferedWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	return w.body.Write(b) //nolint:wrapcheck
+}
+
+// etagMatch reports whether If-None-Match header value matches etag using weak comparison.
+func etagMatch(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	etag = strings.TrimPrefix(etag, "W/")
+
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AllowedHosts is a middleware which responds with 400 Bad Request to requests whose Host header
+// is not in the allowlist. Host names are matched case-insensitively and without port. Wildcard
+// entries like "*.example.com" match any subdomain of example.com, but not example.com itself.
+// Empty allowlist allows all hosts. Rejected requests are logged by SlogLogger with rejected_by
+// attribute "allowed_hosts".
+func AllowedHosts(hosts ...string) func(next http.Handler) http.Handler {
+	allowed := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		allowed = append(allowed, strings.ToLower(host))
+	}
+
+	return func(next http.Handler) http.Handler {
+		if len(allowed) == 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			if !hostAllowed(req.Host, allowed) {
+				SetRejection(req.Context(), "allowed_hosts")
+				http.Error(res, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+
+				return
+			}
+
+			next.ServeHTTP(res, req)
+		})
+	}
+}
+
+func hostAllowed(host string, allowed []string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "" {
+		return false
+	}
+
+	for _, pattern := range allowed {
+		if suffix, ok := strings.CutPrefix(pattern, "*"); ok {
+			if strings.HasSuffix(host, suffix) && len(host) > len(suffix) {
+				return true
+			}
+
+			continue
+		}
+
+		if host == pattern {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RequireContentType is a middleware which responds with 415 Unsupported Media Type to POST, PUT
+// and PATCH requests whose Content-Type header doesn't match one of types. Media types are matched
+// case-insensitively and without parameters, so "application/json; charset=utf-8" matches
+// "application/json". Requests with other methods and empty types allow all requests. Rejected
+// requests are logged by SlogLogger with rejected_by attribute "content_type".
+func RequireContentType(types ...string) func(next http.Handler) http.Handler {
+	allowed := make([]string, 0, len(types))
+	for _, t := range types {
+		allowed = append(allowed, strings.ToLower(t))
+	}
+
+	return func(next http.Handler) http.Handler {
+		if len(allowed) == 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			switch req.Method {
+			case http.MethodPost, http.MethodPut, http.MethodPatch:
+				mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+				if err != nil || !slices.Contains(allowed, mediaType) {
+					SetRejection(req.Context(), "content_type")
+					http.Error(res, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+
+					return
+				}
+			}
+
+			next.ServeHTTP(res, req)
+		})
+	}
+}
+
+// CORSOptions configures CORS middleware.
+type CORSOptions struct {
+	// AllowedOrigins are origins allowed to make cross-origin requests, matched exactly, or "*"
+	// to allow any origin.
+	AllowedOrigins []string
+	// AllowedMethods are methods allowed in preflight requests, GET, HEAD and POST by default.
+	AllowedMethods []string
+	// AllowedHeaders are request headers allowed in preflight requests.
+	AllowedHeaders []string
+	// AllowCredentials allows requests with credentials like cookies. It can not be used with "*"
+	// origin.
+	AllowCredentials bool
+	// MaxAge is how long results of preflight requests may be cached, not set if zero.
+	MaxAge time.Duration
+}
+
+// CORS is a middleware which sets Access-Control-Allow-* headers for requests from allowed origins
+// and responds to OPTIONS preflight requests with 204 No Content. It panics if "*" origin is used
+// together with AllowCredentials, which is forbidden by the CORS specification.
+func CORS(opts CORSOptions) func(next http.Handler) http.Handler {
+	anyOrigin := slices.Contains(opts.AllowedOrigins, "*")
+	if anyOrigin && opts.AllowCredentials {
+		panic("bee: CORS wildcard origin can not be used with credentials")
+	}
+
+	methods := opts.AllowedMethods
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+	}
+
+	allowMethods := strings.Join(methods, ", ")
+	allowHeaders := strings.Join(opts.AllowedHeaders, ", ")
+	maxAge := strconv.Itoa(int(opts.MaxAge.Seconds()))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			origin := req.Header.Get("Origin")
+			preflight := req.Method == http.MethodOptions && req.Header.Get("Access-Control-Request-Method") != ""
+			header := res.Header()
+
+			header.Add("Vary", "Origin")
+
+			if origin == "" || !anyOrigin && !slices.Contains(opts.AllowedOrigins, origin) {
+				if preflight {
+					res.WriteHeader(http.StatusNoContent)
+
+					return
+				}
+
+				next.ServeHTTP(res, req)
+
+				return
+			}
+
+			if anyOrigin {
+				header.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				header.Set("Access-Control-Allow-Origin", origin)
+			}
+
+			if opts.AllowCredentials {
+				header.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if !preflight {
+				next.ServeHTTP(res, req)
+
+				return
+			}
+
+			header.Set("Access-Control-Allow-Methods", allowMethods)
+
+			if allowHeaders != "" {
+				header.Set("Access-Control-Allow-Headers", allowHeaders)
+			}
+
+			if opts.MaxAge > 0 {
+				header.Set("Access-Control-Max-Age", maxAge)
+			}
+
+			res.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+// StripSlashes is a middleware which removes trailing slashes from the request path before
+// routing, so /foo/ is routed as /foo. The root path / is preserved.
+func StripSlashes() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			path := trimTrailingSlashes(req.URL.Path)
+			if path == req.URL.Path {
+				next.ServeHTTP(res, req)
+
+				return
+			}
+
+			r := req.Clone(req.Context())
+			r.URL.Path = path
+			if r.URL.RawPath != "" {
+				r.URL.RawPath = trimTrailingSlashes(r.URL.RawPath)
+			}
+
+			next.ServeHTTP(res, r)
+			req.Pattern = r.Pattern
+		})
+	}
+}
+
+// RedirectSlashes is a middleware which responds with 301 Moved Permanently redirect to the
+// request path without trailing slashes, so /foo/ is redirected to /foo. The root path / is
+// preserved.
+func RedirectSlashes() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			path := trimTrailingSlashes(req.URL.Path)
+			if path == req.URL.Path {
+				next.ServeHTTP(res, req)
+
+				return
+			}
+
+			// force a single leading slash to prevent redirects to other hosts, i.e. //evil.com/
+			path = "/" + strings.TrimLeft(path, "/")
+			if req.URL.RawQuery != "" {
+				path += "?" + req.URL.RawQuery
+			}
+
+			http.Redirect(res, req, path, http.StatusMovedPermanently)
 		})
 	}
 }
+
+func trimTrailingSlashes(path string) string {
+	if len(path) <= 1 {
+		return path
+	}
+
+	if trimmed := strings.TrimRight(path, "/"); trimmed != "" {
+		return trimmed
+	}
+
+	return "/"
+}
@@ -1,12 +1,22 @@
 package bee
 
 import (
+	"bufio"
 	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestMiddlewaresWrap(t *testing.T) {
@@ -104,6 +114,1351 @@ func TestSlogLogger(t *testing.T) {
 	}
 }
 
+func TestSlogLoggerWithOptions(t *testing.T) {
+	t.Parallel()
+
+	var logs bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&logs, nil))
+
+	handler := SlogLoggerWithOptions(log, WithSkipPaths("/healthz", "/readyz"), WithRemoteAddr(), WithUserAgent())(
+		http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+
+	for _, path := range []string{"/healthz", "/readyz/db"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("want status %d for %s, got %d", http.StatusOK, path, rec.Code)
+		}
+	}
+
+	if logs.Len() != 0 {
+		t.Fatalf("want skipped paths not logged, got %s", logs.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/things", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("User-Agent", "curl/8.0")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatalf("decode log entry: %v", err)
+	}
+
+	assertLogValue(t, entry, "uri", "/things")
+	assertLogValue(t, entry, "remote_addr", "192.0.2.1:1234")
+	assertLogValue(t, entry, "user_agent", "curl/8.0")
+}
+
+func TestSlogLoggerSizes(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		opts         []SlogLoggerOption
+		body         string
+		wantRequest  any
+		wantResponse any
+	}{
+		"bytes": {
+			body:         strings.Repeat("a", 2048),
+			wantRequest:  float64(2048),
+			wantResponse: float64(1536),
+		},
+		"human": {
+			opts:         []SlogLoggerOption{WithHumanSizes()},
+			body:         strings.Repeat("a", 2048),
+			wantRequest:  "2.0 KiB",
+			wantResponse: "1.5 KiB",
+		},
+		"empty": {
+			opts:         []SlogLoggerOption{WithHumanSizes()},
+			wantRequest:  "0 B",
+			wantResponse: "1.5 KiB",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var logs bytes.Buffer
+			log := slog.New(slog.NewJSONHandler(&logs, nil))
+
+			handler := SlogLoggerWithOptions(log, tt.opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if _, err := io.Copy(io.Discard, r.Body); err != nil {
+					t.Errorf("read body: %v", err)
+				}
+
+				_, _ = w.Write(bytes.Repeat([]byte("b"), 1536))
+			}))
+
+			handler.ServeHTTP(httptest.NewRecorder(),
+				httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader(tt.body)))
+
+			var entry map[string]any
+			if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+				t.Fatalf("decode log entry: %v", err)
+			}
+
+			assertLogValue(t, entry, "request_bytes", tt.wantRequest)
+			assertLogValue(t, entry, "bytes", tt.wantResponse)
+		})
+	}
+}
+
+func TestFormatByteSize(t *testing.T) {
+	t.Parallel()
+
+	tests := map[int64]string{
+		0:                  "0 B",
+		1023:               "1023 B",
+		1024:               "1.0 KiB",
+		5 * 1024 * 1024:    "5.0 MiB",
+		3 << 30:            "3.0 GiB",
+		1536 * 1024 * 1024: "1.5 GiB",
+	}
+
+	for n, want := range tests {
+		if got := formatByteSize(n); got != want {
+			t.Errorf("want %d formatted as %q, got %q", n, want, got)
+		}
+	}
+}
+
+func TestSlogLoggerOmitsOptionalFieldsByDefault(t *testing.T) {
+	t.Parallel()
+
+	var logs bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&logs, nil))
+
+	handler := SlogLogger(log)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	var entry map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatalf("decode log entry: %v", err)
+	}
+
+	for _, key := range []string{"remote_addr", "user_agent", "request_id", "rejected_by"} {
+		if _, ok := entry[key]; ok {
+			t.Fatalf("want no %s in log entry", key)
+		}
+	}
+}
+
+func TestSlogLoggerHandlerError(t *testing.T) {
+	t.Parallel()
+
+	var logs bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&logs, nil))
+
+	handler := SlogLogger(log)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetHandlerError(r.Context(), errors.New("database unavailable"))
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/things", nil))
+
+	var entry map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatalf("decode log entry: %v", err)
+	}
+
+	assertLogValue(t, entry, "status", float64(http.StatusServiceUnavailable))
+	assertLogValue(t, entry, "error", "database unavailable")
+}
+
+func TestSlogLoggerWithoutHandlerError(t *testing.T) {
+	t.Parallel()
+
+	var logs bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&logs, nil))
+
+	handler := SlogLogger(log)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/things", nil))
+
+	var entry map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatalf("decode log entry: %v", err)
+	}
+
+	if _, ok := entry["error"]; ok {
+		t.Fatal("want no error in log entry")
+	}
+}
+
+func TestSetHandlerErrorWithoutSlogLogger(t *testing.T) {
+	t.Parallel()
+
+	SetHandlerError(context.Background(), errors.New("ignored"))
+	SetRejection(context.Background(), "ignored")
+}
+
+func TestContextWithHandlerError(t *testing.T) {
+	t.Parallel()
+
+	var logs bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&logs, nil))
+
+	var handlerErr func() error
+
+	outer := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var ctx context.Context
+			ctx, handlerErr = ContextWithHandlerError(r.Context())
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+
+	handler := outer(SlogLogger(log)(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		SetHandlerError(r.Context(), errors.New("database unavailable"))
+	})))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/things", nil))
+
+	if err := handlerErr(); err == nil || err.Error() != "database unavailable" {
+		t.Fatalf("want handler error seen by outer middleware, got %v", err)
+	}
+
+	var entry map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatalf("decode log entry: %v", err)
+	}
+
+	assertLogValue(t, entry, "error", "database unavailable")
+}
+
+func TestSlogLoggerContextLogAttrs(t *testing.T) {
+	t.Parallel()
+
+	var logs bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&logs, nil))
+
+	outer := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := ContextWithLogAttrs(r.Context(), slog.String("trace_id", "abc"))
+			ctx = ContextWithLogAttrs(ctx, slog.String("tenant", "acme"))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+
+	handler := outer(SlogLogger(log)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/things", nil))
+
+	var entry map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatalf("decode log entry: %v", err)
+	}
+
+	assertLogValue(t, entry, "trace_id", "abc")
+	assertLogValue(t, entry, "tenant", "acme")
+}
+
+func TestSlogLoggerRejection(t *testing.T) {
+	t.Parallel()
+
+	rateLimit := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/limited" {
+				SetRejection(r.Context(), "ratelimit")
+				w.WriteHeader(http.StatusTooManyRequests)
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	tests := map[string]struct {
+		middleware func(http.Handler) http.Handler
+		req        *http.Request
+		wantStatus int
+		want       string
+	}{
+		"custom": {
+			middleware: rateLimit,
+			req:        httptest.NewRequest(http.MethodGet, "/limited", nil),
+			wantStatus: http.StatusTooManyRequests,
+			want:       "ratelimit",
+		},
+		"allowed-hosts": {
+			middleware: AllowedHosts("example.com"),
+			req:        httptest.NewRequest(http.MethodGet, "http://evil.com/", nil),
+			wantStatus: http.StatusBadRequest,
+			want:       "allowed_hosts",
+		},
+		"content-type": {
+			middleware: RequireContentType("application/json"),
+			req:        httptest.NewRequest(http.MethodPost, "/", nil),
+			wantStatus: http.StatusUnsupportedMediaType,
+			want:       "content_type",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var logs bytes.Buffer
+			log := slog.New(slog.NewJSONHandler(&logs, nil))
+
+			handler := SlogLogger(log)(tt.middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})))
+
+			handler.ServeHTTP(httptest.NewRecorder(), tt.req)
+
+			var entry map[string]any
+			if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+				t.Fatalf("decode log entry: %v", err)
+			}
+
+			assertLogValue(t, entry, "status", float64(tt.wantStatus))
+			assertLogValue(t, entry, "rejected_by", tt.want)
+		})
+	}
+}
+
+func TestSlogLoggerForwardsFlusherAndHijacker(t *testing.T) {
+	t.Parallel()
+
+	var mws Middlewares
+	mws.Add(SlogLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	mws.Add(StripSlashes())
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /events", func(w http.ResponseWriter, _ *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			t.Error("want http.Flusher")
+
+			return
+		}
+
+		_, _ = w.Write([]byte("data: ping\n\n"))
+		flusher.Flush()
+	})
+	mux.HandleFunc("GET /ws", func(w http.ResponseWriter, _ *http.Request) {
+		hijacker, ok := w.(http.Hijacker)
+		if !ok {
+			t.Error("want http.Hijacker")
+
+			return
+		}
+
+		conn, buf, err := hijacker.Hijack()
+		if err != nil {
+			t.Error(err)
+
+			return
+		}
+		defer conn.Close()
+
+		_, _ = buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: close\r\n\r\n")
+		_ = buf.Flush()
+	})
+
+	server := httptest.NewServer(mws.Wrap(mux))
+	defer server.Close()
+
+	res, err := http.Get(server.URL + "/events/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := io.ReadAll(res.Body)
+	_ = res.Body.Close()
+
+	if got, want := string(body), "data: ping\n\n"; got != want {
+		t.Fatalf("want body %q, got %q", want, got)
+	}
+
+	res, err = http.Get(server.URL + "/ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("want status %d, got %d", http.StatusSwitchingProtocols, res.StatusCode)
+	}
+}
+
+type pusherRecorder struct {
+	*httptest.ResponseRecorder
+	pushed []string
+}
+
+func (p *pusherRecorder) Push(target string, _ *http.PushOptions) error {
+	p.pushed = append(p.pushed, target)
+
+	return nil
+}
+
+func TestSlogLoggerForwardsPusher(t *testing.T) {
+	t.Parallel()
+
+	handler := SlogLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))(
+		http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			pusher, ok := w.(http.Pusher)
+			if !ok {
+				t.Fatal("want http.Pusher")
+			}
+
+			if err := pusher.Push("/app.css", nil); err != nil {
+				t.Fatal(err)
+			}
+		}))
+
+	rec := &pusherRecorder{ResponseRecorder: httptest.NewRecorder()} //nolint:exhaustruct
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.ProtoMajor = 2
+
+	handler.ServeHTTP(rec, req)
+
+	if want := []string{"/app.css"}; !reflect.DeepEqual(rec.pushed, want) {
+		t.Fatalf("want pushed %v, got %v", want, rec.pushed)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		header string
+	}{
+		"incoming": {
+			header: "req-42",
+		},
+		"generated": {},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var logs bytes.Buffer
+			var got string
+
+			handler := RequestID()(SlogLogger(slog.New(slog.NewJSONHandler(&logs, nil)))(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					got = RequestIDFromContext(r.Context())
+					w.WriteHeader(http.StatusOK)
+				}),
+			))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set("X-Request-Id", tt.header)
+			}
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if tt.header != "" && got != tt.header {
+				t.Fatalf("want request id %q, got %q", tt.header, got)
+			}
+			if tt.header == "" && len(got) != 32 {
+				t.Fatalf("want generated 32 character request id, got %q", got)
+			}
+
+			if header := rec.Header().Get("X-Request-Id"); header != got {
+				t.Fatalf("want response header %q, got %q", got, header)
+			}
+
+			var entry map[string]any
+			if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+				t.Fatalf("decode log entry: %v", err)
+			}
+
+			assertLogValue(t, entry, "request_id", got)
+		})
+	}
+}
+
+func TestRequestIDGeneratesUniqueIDs(t *testing.T) {
+	t.Parallel()
+
+	if first, second := newRequestID(), newRequestID(); first == second {
+		t.Fatalf("want unique request ids, got %q twice", first)
+	}
+}
+
+func TestRequestIDFromContextWithoutRequestID(t *testing.T) {
+	t.Parallel()
+
+	if id := RequestIDFromContext(context.Background()); id != "" {
+		t.Fatalf("want empty request id, got %q", id)
+	}
+}
+
+func TestRecoverer(t *testing.T) {
+	t.Parallel()
+
+	var panics, access bytes.Buffer
+
+	handler := SlogLogger(slog.New(slog.NewJSONHandler(&access, nil)))(
+		Recoverer(slog.New(slog.NewJSONHandler(&panics, nil)))(
+			http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+				panic("boom")
+			}),
+		),
+	)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/things", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("want status %d, got %d", http.StatusInternalServerError, rec.Code)
+	}
+
+	var entry map[string]any
+	if err := json.Unmarshal(panics.Bytes(), &entry); err != nil {
+		t.Fatalf("decode panic log entry: %v", err)
+	}
+
+	assertLogValue(t, entry, "msg", "handler panic")
+	assertLogValue(t, entry, "uri", "/things")
+	assertLogValue(t, entry, "panic", "boom")
+
+	if stack, _ := entry["stack"].(string); !strings.Contains(stack, "goroutine ") {
+		t.Fatalf("want stack trace, got %q", stack)
+	}
+
+	entry = nil
+	if err := json.Unmarshal(access.Bytes(), &entry); err != nil {
+		t.Fatalf("decode access log entry: %v", err)
+	}
+
+	assertLogValue(t, entry, "status", float64(http.StatusInternalServerError))
+	assertLogValue(t, entry, "error", "panic: boom")
+}
+
+func TestRecovererRepanicsAbortHandler(t *testing.T) {
+	t.Parallel()
+
+	var logs bytes.Buffer
+
+	handler := Recoverer(slog.New(slog.NewJSONHandler(&logs, nil)))(
+		http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			panic(http.ErrAbortHandler)
+		}),
+	)
+
+	defer func() {
+		if got := recover(); got != http.ErrAbortHandler { //nolint:errorlint
+			t.Fatalf("want panic %v, got %v", http.ErrAbortHandler, got)
+		}
+
+		if logs.Len() != 0 {
+			t.Fatalf("want nothing logged, got %s", logs.String())
+		}
+	}()
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+func TestRecovererPassesThrough(t *testing.T) {
+	t.Parallel()
+
+	handler := Recoverer(slog.New(slog.NewTextHandler(io.Discard, nil)))(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("want status %d, got %d", http.StatusNoContent, rec.Code)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		handler    http.HandlerFunc
+		wantStatus int
+		wantBody   string
+	}{
+		"deadline-exceeded": {
+			handler: func(_ http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+			},
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   "Service Unavailable\n",
+		},
+		"response-started": {
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("partial"))
+				<-r.Context().Done()
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   "partial",
+		},
+		"flushed": {
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.(http.Flusher).Flush() //nolint:forcetypeassert
+				w.WriteHeader(http.StatusCreated)
+				_, _ = w.Write([]byte("streamed"))
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   "streamed",
+		},
+		"in-time": {
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if _, ok := r.Context().Deadline(); !ok {
+					t.Error("want request context with deadline")
+				}
+
+				w.WriteHeader(http.StatusCreated)
+			},
+			wantStatus: http.StatusCreated,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			Timeout(10*time.Millisecond)(tt.handler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("want status %d, got %d", tt.wantStatus, rec.Code)
+			}
+
+			if got := rec.Body.String(); got != tt.wantBody {
+				t.Fatalf("want body %q, got %q", tt.wantBody, got)
+			}
+		})
+	}
+}
+
+func TestTimeoutDoesNotWaitForHandler(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	lateWrite := make(chan error, 1)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		// Ignores cancellation of the request context, like a slow downstream call.
+		<-release
+
+		w.Header().Set("X-Late", "true")
+		_, err := w.Write([]byte("late"))
+		lateWrite <- err
+	})
+
+	rec := httptest.NewRecorder()
+	served := make(chan struct{})
+
+	go func() {
+		Timeout(10*time.Millisecond)(handler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		close(served)
+	}()
+
+	select {
+	case <-served:
+	case <-time.After(time.Second):
+		t.Fatal("want response at the deadline while handler blocks")
+	}
+
+	close(release)
+
+	if err := <-lateWrite; !errors.Is(err, http.ErrHandlerTimeout) {
+		t.Fatalf("want late write to fail with ErrHandlerTimeout, got %v", err)
+	}
+
+	if rec.Code != http.StatusServiceUnavailable || rec.Body.String() != "Service Unavailable\n" {
+		t.Fatalf("want 503 response, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	if rec.Header().Get("X-Late") != "" {
+		t.Fatal("want headers set after the deadline discarded")
+	}
+}
+
+func TestTimeoutPropagatesPanic(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if r := recover(); r != "boom" {
+			t.Fatalf("want handler panic propagated, got %v", r)
+		}
+	}()
+
+	handler := http.HandlerFunc(func(http.ResponseWriter, *http.Request) { panic("boom") })
+	Timeout(time.Second)(handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+func TestCompress(t *testing.T) {
+	t.Parallel()
+
+	body := strings.Repeat(`{"name":"thing"}`, 100)
+
+	tests := map[string]struct {
+		acceptEncoding  string
+		contentType     string
+		contentEncoding string
+		wantEncoding    string
+	}{
+		"gzip": {
+			acceptEncoding: "gzip, deflate",
+			contentType:    "application/json",
+			wantEncoding:   "gzip",
+		},
+		"deflate": {
+			acceptEncoding: "deflate",
+			contentType:    "application/json",
+			wantEncoding:   "deflate",
+		},
+		"not-accepted": {
+			contentType: "application/json",
+		},
+		"not-configured-type": {
+			acceptEncoding: "gzip",
+			contentType:    "image/png",
+		},
+		"already-compressed": {
+			acceptEncoding:  "gzip",
+			contentType:     "application/json",
+			contentEncoding: "br",
+			wantEncoding:    "br",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := Compress(5, "application/json")(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				if tt.contentEncoding != "" {
+					w.Header().Set("Content-Encoding", tt.contentEncoding)
+				}
+
+				_, _ = w.Write([]byte(body))
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if got := rec.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Fatalf("want content encoding %q, got %q", tt.wantEncoding, got)
+			}
+
+			var reader io.Reader = rec.Body
+
+			switch tt.wantEncoding {
+			case "gzip":
+				gz, err := gzip.NewReader(rec.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				reader = gz
+			case "deflate":
+				reader = flate.NewReader(rec.Body)
+			}
+
+			if tt.wantEncoding == "gzip" || tt.wantEncoding == "deflate" {
+				if got := rec.Header().Get("Vary"); got != "Accept-Encoding" {
+					t.Fatalf("want vary header Accept-Encoding, got %q", got)
+				}
+			}
+
+			got, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(got) != body {
+				t.Fatalf("want body %q, got %q", body, got)
+			}
+		})
+	}
+}
+
+func TestCompressFlushesStreamingResponses(t *testing.T) {
+	t.Parallel()
+
+	flushed := make(chan struct{})
+	done := make(chan struct{})
+
+	handler := Compress(5, "text/*")(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: first\n\n"))
+		w.(http.Flusher).Flush()
+		close(flushed)
+		<-done
+	}))
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	defer close(done)
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	res, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	<-flushed
+
+	gz, err := gzip.NewReader(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	line, err := bufio.NewReader(gz).ReadString('\n')
+	if err != nil || line != "data: first\n" {
+		t.Fatalf("want flushed event before handler returns, got %q %v", line, err)
+	}
+}
+
+func TestETag(t *testing.T) {
+	t.Parallel()
+
+	body := `{"name":"thing"}`
+	handler := ETag()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			http.NotFound(w, r)
+		case "/custom":
+			w.Header().Set("ETag", `"v1"`)
+			_, _ = w.Write([]byte(body))
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(body))
+		}
+	}))
+
+	first := httptest.NewRecorder()
+	handler.ServeHTTP(first, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || first.Body.String() != body {
+		t.Fatalf("want 200 with body %q, got %d with %q", body, first.Code, first.Body.String())
+	}
+
+	if !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("want weak etag, got %q", etag)
+	}
+
+	tests := map[string]struct {
+		method      string
+		path        string
+		ifNoneMatch string
+		wantStatus  int
+		wantBody    string
+		wantETag    string
+	}{
+		"not-modified": {
+			method:      http.MethodGet,
+			ifNoneMatch: etag,
+			wantStatus:  http.StatusNotModified,
+			wantETag:    etag,
+		},
+		"not-modified-strong-list": {
+			method:      http.MethodGet,
+			ifNoneMatch: `"other", ` + strings.TrimPrefix(etag, "W/"),
+			wantStatus:  http.StatusNotModified,
+			wantETag:    etag,
+		},
+		"not-modified-wildcard": {
+			method:      http.MethodHead,
+			ifNoneMatch: "*",
+			wantStatus:  http.StatusNotModified,
+			wantETag:    etag,
+		},
+		"modified": {
+			method:      http.MethodGet,
+			ifNoneMatch: `W/"other"`,
+			wantStatus:  http.StatusOK,
+			wantBody:    body,
+			wantETag:    etag,
+		},
+		"handler-etag": {
+			method:      http.MethodGet,
+			path:        "/custom",
+			ifNoneMatch: `"v1"`,
+			wantStatus:  http.StatusNotModified,
+			wantETag:    `"v1"`,
+		},
+		"not-cacheable-method": {
+			method:      http.MethodPost,
+			ifNoneMatch: etag,
+			wantStatus:  http.StatusOK,
+			wantBody:    body,
+		},
+		"not-cacheable-status": {
+			method:      http.MethodGet,
+			path:        "/missing",
+			ifNoneMatch: "*",
+			wantStatus:  http.StatusNotFound,
+			wantBody:    "404 page not found\n",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			path := tt.path
+			if path == "" {
+				path = "/"
+			}
+
+			req := httptest.NewRequest(tt.method, path, nil)
+			req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("want status %d, got %d", tt.wantStatus, rec.Code)
+			}
+
+			if got := rec.Body.String(); got != tt.wantBody {
+				t.Errorf("want body %q, got %q", tt.wantBody, got)
+			}
+
+			if got := rec.Header().Get("ETag"); got != tt.wantETag {
+				t.Errorf("want etag %q, got %q", tt.wantETag, got)
+			}
+		})
+	}
+}
+
+func TestAllowedHosts(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		hosts      []string
+		host       string
+		wantStatus int
+	}{
+		"allowed": {
+			hosts:      []string{"api.example.com"},
+			host:       "api.example.com",
+			wantStatus: http.StatusOK,
+		},
+		"allowed-with-port": {
+			hosts:      []string{"API.example.com"},
+			host:       "api.example.com:8080",
+			wantStatus: http.StatusOK,
+		},
+		"disallowed": {
+			hosts:      []string{"api.example.com"},
+			host:       "evil.com",
+			wantStatus: http.StatusBadRequest,
+		},
+		"wildcard": {
+			hosts:      []string{"*.example.com"},
+			host:       "tenant.example.com",
+			wantStatus: http.StatusOK,
+		},
+		"wildcard-does-not-match-apex": {
+			hosts:      []string{"*.example.com"},
+			host:       "example.com",
+			wantStatus: http.StatusBadRequest,
+		},
+		"wildcard-does-not-match-suffix": {
+			hosts:      []string{"*.example.com"},
+			host:       "evilexample.com",
+			wantStatus: http.StatusBadRequest,
+		},
+		"empty-allowlist": {
+			host:       "anything.test",
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := AllowedHosts(tt.hosts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Host = tt.host
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("want status %d, got %d", tt.wantStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestRequireContentType(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		types       []string
+		method      string
+		contentType string
+		wantStatus  int
+	}{
+		"matching": {
+			types:       []string{"application/json"},
+			method:      http.MethodPost,
+			contentType: "application/json",
+			wantStatus:  http.StatusOK,
+		},
+		"matching-with-parameters": {
+			types:       []string{"application/json", "application/x-www-form-urlencoded"},
+			method:      http.MethodPatch,
+			contentType: "Application/JSON; charset=utf-8",
+			wantStatus:  http.StatusOK,
+		},
+		"mismatching": {
+			types:       []string{"application/json"},
+			method:      http.MethodPut,
+			contentType: "text/plain",
+			wantStatus:  http.StatusUnsupportedMediaType,
+		},
+		"missing": {
+			types:      []string{"application/json"},
+			method:     http.MethodPost,
+			wantStatus: http.StatusUnsupportedMediaType,
+		},
+		"get-bypass": {
+			types:      []string{"application/json"},
+			method:     http.MethodGet,
+			wantStatus: http.StatusOK,
+		},
+		"delete-bypass": {
+			types:       []string{"application/json"},
+			method:      http.MethodDelete,
+			contentType: "text/plain",
+			wantStatus:  http.StatusOK,
+		},
+		"empty-types": {
+			method:      http.MethodPost,
+			contentType: "text/plain",
+			wantStatus:  http.StatusOK,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := RequireContentType(tt.types...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(tt.method, "/", strings.NewReader("{}"))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("want status %d, got %d", tt.wantStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestCORS(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		opts        CORSOptions
+		method      string
+		origin      string
+		preflight   bool
+		wantStatus  int
+		wantHeaders map[string]string
+	}{
+		"exact-origin": {
+			opts:       CORSOptions{AllowedOrigins: []string{"https://app.example.com"}, AllowCredentials: true},
+			method:     http.MethodGet,
+			origin:     "https://app.example.com",
+			wantStatus: http.StatusOK,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":      "https://app.example.com",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Allow-Methods":     "",
+				"Vary":                             "Origin",
+			},
+		},
+		"wildcard-origin": {
+			opts:       CORSOptions{AllowedOrigins: []string{"*"}},
+			method:     http.MethodGet,
+			origin:     "https://any.example.com",
+			wantStatus: http.StatusOK,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":      "*",
+				"Access-Control-Allow-Credentials": "",
+			},
+		},
+		"disallowed-origin": {
+			opts:       CORSOptions{AllowedOrigins: []string{"https://app.example.com"}},
+			method:     http.MethodGet,
+			origin:     "https://evil.com",
+			wantStatus: http.StatusOK,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin": "",
+			},
+		},
+		"no-origin": {
+			opts:       CORSOptions{AllowedOrigins: []string{"*"}},
+			method:     http.MethodGet,
+			wantStatus: http.StatusOK,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin": "",
+			},
+		},
+		"preflight": {
+			opts: CORSOptions{
+				AllowedOrigins: []string{"https://app.example.com"},
+				AllowedMethods: []string{http.MethodGet, http.MethodPut},
+				AllowedHeaders: []string{"Authorization", "Content-Type"},
+				MaxAge:         10 * time.Minute,
+			},
+			method:     http.MethodOptions,
+			origin:     "https://app.example.com",
+			preflight:  true,
+			wantStatus: http.StatusNoContent,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":  "https://app.example.com",
+				"Access-Control-Allow-Methods": "GET, PUT",
+				"Access-Control-Allow-Headers": "Authorization, Content-Type",
+				"Access-Control-Max-Age":       "600",
+			},
+		},
+		"preflight-defaults": {
+			opts:       CORSOptions{AllowedOrigins: []string{"*"}},
+			method:     http.MethodOptions,
+			origin:     "https://app.example.com",
+			preflight:  true,
+			wantStatus: http.StatusNoContent,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Methods": "GET, HEAD, POST",
+				"Access-Control-Allow-Headers": "",
+				"Access-Control-Max-Age":       "",
+			},
+		},
+		"preflight-disallowed-origin": {
+			opts:       CORSOptions{AllowedOrigins: []string{"https://app.example.com"}},
+			method:     http.MethodOptions,
+			origin:     "https://evil.com",
+			preflight:  true,
+			wantStatus: http.StatusNoContent,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":  "",
+				"Access-Control-Allow-Methods": "",
+			},
+		},
+		"options-without-preflight": {
+			opts:       CORSOptions{AllowedOrigins: []string{"*"}},
+			method:     http.MethodOptions,
+			origin:     "https://app.example.com",
+			wantStatus: http.StatusOK,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":  "*",
+				"Access-Control-Allow-Methods": "",
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := CORS(tt.opts)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(tt.method, "/", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPut)
+			}
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("want status %d, got %d", tt.wantStatus, rec.Code)
+			}
+
+			for key, want := range tt.wantHeaders {
+				if got := rec.Header().Get(key); got != want {
+					t.Fatalf("want header %s %q, got %q", key, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestCORSPanicsOnWildcardWithCredentials(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if got := recover(); got != "bee: CORS wildcard origin can not be used with credentials" {
+			t.Fatalf("want wildcard with credentials panic, got %v", got)
+		}
+	}()
+
+	CORS(CORSOptions{AllowedOrigins: []string{"*"}, AllowCredentials: true})
+}
+
+func TestStripSlashes(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		target   string
+		wantPath string
+	}{
+		"trailing-slash": {
+			target:   "/foo/",
+			wantPath: "/foo",
+		},
+		"multiple-trailing-slashes": {
+			target:   "/foo//?id=1",
+			wantPath: "/foo",
+		},
+		"root": {
+			target:   "/",
+			wantPath: "/",
+		},
+		"without-trailing-slash": {
+			target:   "/foo",
+			wantPath: "/foo",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var gotPath string
+			handler := StripSlashes()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotPath = r.URL.Path
+			}))
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("want status %d, got %d", http.StatusOK, rec.Code)
+			}
+			if gotPath != tt.wantPath {
+				t.Fatalf("want path %q, got %q", tt.wantPath, gotPath)
+			}
+		})
+	}
+}
+
+func TestStripSlashesRoutesWithServeMux(t *testing.T) {
+	t.Parallel()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /foo", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	var mws Middlewares
+	mws.Add(StripSlashes())
+
+	rec := httptest.NewRecorder()
+	mws.Wrap(mux).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/foo/", nil))
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("want status %d, got %d", http.StatusNoContent, rec.Code)
+	}
+}
+
+func TestRedirectSlashes(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		target       string
+		wantStatus   int
+		wantLocation string
+	}{
+		"trailing-slash": {
+			target:       "/foo/",
+			wantStatus:   http.StatusMovedPermanently,
+			wantLocation: "/foo",
+		},
+		"trailing-slash-with-query": {
+			target:       "/foo/?id=1",
+			wantStatus:   http.StatusMovedPermanently,
+			wantLocation: "/foo?id=1",
+		},
+		"leading-slashes": {
+			target:       "//evil.com/",
+			wantStatus:   http.StatusMovedPermanently,
+			wantLocation: "/evil.com",
+		},
+		"root": {
+			target:     "/",
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := RedirectSlashes()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			path, query, _ := strings.Cut(tt.target, "?")
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.URL = &url.URL{Path: path, RawQuery: query} //nolint:exhaustruct
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("want status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if got := rec.Header().Get("Location"); got != tt.wantLocation {
+				t.Fatalf("want location %q, got %q", tt.wantLocation, got)
+			}
+		})
+	}
+}
+
 func assertLogValue(t *testing.T, entry map[string]any, key string, want any) {
 	t.Helper()
 
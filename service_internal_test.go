@@ -3,12 +3,16 @@ package bee
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"io"
 	"log/slog"
+	"net"
+	"net/http"
 	"os"
 	"reflect"
+	"slices"
 	"strings"
 	"sync"
 	"testing"
@@ -59,6 +63,7 @@ func TestServiceOptions(t *testing.T) {
 	opts := appOptions{} //nolint:exhaustruct
 
 	WithShutdownGracePeriod(3 * time.Second)(&opts)
+	WithPreShutdownTimeout(time.Minute)(&opts)
 	WithLogLevel("warn")(&opts)
 	WithErrorHandling(flag.ContinueOnError)(&opts)
 	WithOutput(&output)(&opts)
@@ -66,11 +71,17 @@ func TestServiceOptions(t *testing.T) {
 		return "value", true
 	})(&opts)
 	WithUsage("parent")(&opts)
+	WithSecretsDir("/run/secrets")(&opts)
+	WithConfigFile("/etc/maia.json")(&opts)
 
 	if opts.timeout != 3*time.Second {
 		t.Fatalf("want timeout 3s, got %s", opts.timeout)
 	}
 
+	if opts.preTimeout != time.Minute {
+		t.Fatalf("want pre-shutdown timeout 1m, got %s", opts.preTimeout)
+	}
+
 	if opts.logLevel.Level() != slog.LevelWarn {
 		t.Fatalf("want warn log level, got %s", opts.logLevel.Level())
 	}
@@ -91,6 +102,26 @@ func TestServiceOptions(t *testing.T) {
 	if opts.parentUsage != "parent" {
 		t.Fatalf("want parent usage, got %q", opts.parentUsage)
 	}
+
+	if opts.secretsDir != "/run/secrets" {
+		t.Fatalf("want secrets dir, got %q", opts.secretsDir)
+	}
+
+	if opts.configFile != "/etc/maia.json" || opts.configOptional {
+		t.Fatalf("want required config file, got %q", opts.configFile)
+	}
+
+	WithOptionalConfigFile("/etc/maia.yaml")(&opts)
+
+	if opts.configFile != "/etc/maia.yaml" || !opts.configOptional {
+		t.Fatalf("want optional config file, got %q", opts.configFile)
+	}
+
+	WithConfigFileFlag("config", "MAIA_CONFIG_FILE")(&opts)
+
+	if opts.configFlag != "config" || opts.configEnv != "MAIA_CONFIG_FILE" {
+		t.Fatalf("want config file flag and env, got %q %q", opts.configFlag, opts.configEnv)
+	}
 }
 
 func TestWithLogLevelDefaultsToDebug(t *testing.T) {
@@ -136,6 +167,219 @@ func TestWithLogLevel(t *testing.T) {
 	}
 }
 
+func TestWithLogTimeFormat(t *testing.T) {
+	t.Parallel()
+
+	at := time.Date(2024, time.March, 5, 10, 30, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		opt  Option
+		want any
+	}{
+		"layout": {
+			opt:  WithLogTimeFormat(time.DateTime),
+			want: "2024-03-05 10:30:00",
+		},
+		"epoch-millis": {
+			opt:  WithLogTimeEpochMillis(),
+			want: float64(at.UnixMilli()),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			opts := appOptions{} //nolint:exhaustruct
+			tt.opt(&opts)
+
+			var buf bytes.Buffer
+			record := slog.NewRecord(at, slog.LevelInfo, "started", 0)
+
+			if err := newLogHandler(&buf, opts).Handle(context.Background(), record); err != nil {
+				t.Fatal(err)
+			}
+
+			var entry map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+				t.Fatalf("decode log entry: %v", err)
+			}
+
+			if entry["time"] != tt.want {
+				t.Fatalf("want time %v, got %v", tt.want, entry["time"])
+			}
+		})
+	}
+}
+
+func TestWithoutLogTime(t *testing.T) {
+	t.Parallel()
+
+	opts := appOptions{} //nolint:exhaustruct
+	WithoutLogTime()(&opts)
+
+	var buf bytes.Buffer
+	log := slog.New(newLogHandler(&buf, opts))
+	log.Info("started")
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("decode log entry: %v", err)
+	}
+
+	if _, ok := entry["time"]; ok {
+		t.Fatalf("want no time attribute, got %v", entry)
+	}
+
+	if entry["msg"] != "started" {
+		t.Fatalf("want msg started, got %v", entry["msg"])
+	}
+}
+
+func TestWithLogFormat(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		opts []Option
+		want string
+	}{
+		"default": {
+			want: `{"level":"WARN","msg":"started","service":"test","port":8080}` + "\n",
+		},
+		"json": {
+			opts: []Option{WithLogFormat("json")},
+			want: `{"level":"WARN","msg":"started","service":"test","port":8080}` + "\n",
+		},
+		"text": {
+			opts: []Option{WithLogFormat("TEXT")},
+			want: "level=WARN msg=started service=test port=8080\n",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			opts := append([]Option{WithLogOutput(&buf), WithLogLevel("warn"), WithoutLogTime()}, tt.opts...)
+			app := New("test", &struct{}{}, opts...)
+
+			app.Log.Info("skipped")
+			app.Log.Warn("started", slog.Int("port", 8080))
+
+			if got := buf.String(); got != tt.want {
+				t.Fatalf("want log %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestWithLogTee(t *testing.T) {
+	t.Parallel()
+
+	var console, file bytes.Buffer
+	app := New("test", &struct{}{}, WithLogTee(&file), WithLogOutput(&console), WithoutLogTime())
+
+	app.Log.Info("started")
+
+	want := `{"level":"INFO","msg":"started","service":"test"}` + "\n"
+	if got := console.String(); got != want {
+		t.Errorf("want console log %q, got %q", want, got)
+	}
+	if got := file.String(); got != want {
+		t.Errorf("want file log %q, got %q", want, got)
+	}
+}
+
+func TestWithLogAttrs(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		opts func(w io.Writer) []Option
+		want string
+	}{
+		"default": {
+			opts: func(w io.Writer) []Option {
+				return []Option{WithLogOutput(w), WithoutLogTime()}
+			},
+			want: `{"level":"INFO","msg":"started","service":"maia"}` + "\n",
+		},
+		"attrs": {
+			opts: func(w io.Writer) []Option {
+				return []Option{WithLogOutput(w), WithoutLogTime(), WithLogAttrs(slog.String("version", "v1.2.3"))}
+			},
+			want: `{"level":"INFO","msg":"started","service":"maia","version":"v1.2.3"}` + "\n",
+		},
+		"injected": {
+			opts: func(w io.Writer) []Option {
+				return []Option{
+					WithLogHandler(slog.NewTextHandler(w, &slog.HandlerOptions{ //nolint:exhaustruct
+						ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+							if a.Key == slog.TimeKey {
+								return slog.Attr{} //nolint:exhaustruct
+							}
+
+							return a
+						},
+					})),
+					WithLogAttrs(slog.String("version", "v1.2.3"), slog.Int("shard", 2)),
+				}
+			},
+			want: "level=INFO msg=started version=v1.2.3 shard=2\n",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			app := New("maia", &struct{}{}, tt.opts(&buf)...)
+			app.Log.Info("started")
+
+			if got := buf.String(); got != tt.want {
+				t.Fatalf("want log %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestWithLogHandler(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	handler := slog.NewJSONHandler(&buf, nil).WithAttrs([]slog.Attr{slog.String("service", "maia")})
+
+	app := New("test", &struct{}{},
+		WithLogHandler(handler),
+		WithLogLevel("error"),
+		WithOutput(io.Discard),
+		WithErrorHandling(flag.ContinueOnError),
+	)
+	if app.Log.Handler() != handler {
+		t.Fatal("want injected handler used verbatim")
+	}
+
+	app.Root("Run app", func(ctx *Ctx[struct{}]) error {
+		ctx.Register("db", func(context.Context) error {
+			return errors.New("close db")
+		})
+
+		return nil
+	})
+
+	if err := app.RunE(); err == nil {
+		t.Fatal("want closer error")
+	}
+
+	if !strings.Contains(buf.String(), `"msg":"graceful shutdown","service":"maia"`) {
+		t.Fatalf("want shutdown logged by injected handler, got %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), `"msg":"closer db","service":"maia","error":"close db"`) {
+		t.Fatalf("want closer error logged by injected handler, got %s", buf.String())
+	}
+}
+
 func TestServiceRunClosesRegisteredClosersInReverseOrder(t *testing.T) {
 	t.Parallel()
 
@@ -244,6 +488,46 @@ func TestAppRunExitsOnFailure(t *testing.T) {
 	}
 }
 
+func TestAppFlushesLogHandlerBeforeExit(t *testing.T) {
+	exitFuncMu.Lock()
+	t.Cleanup(func() {
+		osExit = os.Exit
+		exitFuncMu.Unlock()
+	})
+
+	type config struct {
+		Port int `req:""`
+	}
+
+	var calls, atExit []string
+	handler := flushingHandler{Handler: slog.NewTextHandler(io.Discard, nil), calls: &calls}
+	app := New("test", &config{}, WithLogHandler(handler), WithOutput(io.Discard))
+	app.Root("Run app", func(*Ctx[config]) error { return nil })
+
+	osExit = func(code int) {
+		atExit = slices.Clone(calls)
+
+		panic(exitPanic(code))
+	}
+
+	func() {
+		defer func() {
+			if _, ok := recover().(exitPanic); !ok {
+				t.Fatal("want exit on missing required value")
+			}
+		}()
+
+		_ = app.RunE()
+	}()
+
+	if want := []string{"flush", "close"}; !reflect.DeepEqual(atExit, want) {
+		t.Fatalf("want calls %v before exit, got %v", want, atExit)
+	}
+	if want := []string{"flush", "close"}; !reflect.DeepEqual(calls, want) {
+		t.Fatalf("want handler flushed once, got %v", calls)
+	}
+}
+
 func TestSlogError(t *testing.T) {
 	t.Parallel()
 
@@ -253,6 +537,108 @@ func TestSlogError(t *testing.T) {
 	}
 }
 
+func TestSlogConfig(t *testing.T) {
+	t.Parallel()
+
+	cfg := struct {
+		LogLevel string
+		Mongo    struct {
+			Host     string
+			Password string `secret:""`
+			Timeout  time.Duration
+		}
+		Endpoint URL
+		Buckets  StringSlice
+		Client   *http.Client `bee:"-"`
+		Allowed  net.IPNet
+	}{}
+	cfg.LogLevel = "info"
+	cfg.Mongo.Host = "mongo"
+	cfg.Mongo.Password = "s3cr3t"
+	cfg.Mongo.Timeout = time.Second
+	_ = cfg.Endpoint.Set("http://localhost")
+	cfg.Buckets = StringSlice{"foo", "bar"}
+	_, allowed, _ := net.ParseCIDR("10.0.0.0/8")
+	cfg.Allowed = *allowed
+
+	var logs bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&logs, nil))
+	log.Info("starting", SlogConfig(&cfg))
+
+	var entry struct {
+		Config struct {
+			LogLevel string `json:"log_level"`
+			Mongo    struct {
+				Host     string `json:"host"`
+				Password string `json:"password"`
+				Timeout  int64  `json:"timeout"`
+			} `json:"mongo"`
+			Endpoint string   `json:"endpoint"`
+			Buckets  []string `json:"buckets"`
+		} `json:"config"`
+	}
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatalf("decode log entry: %v", err)
+	}
+
+	if !strings.Contains(logs.String(), `"allowed":"10.0.0.0/8"`) {
+		t.Errorf("want config.allowed 10.0.0.0/8, got %s", logs.String())
+	}
+
+	if strings.Contains(logs.String(), "client") {
+		t.Errorf("want ignored field not logged, got %s", logs.String())
+	}
+
+	if entry.Config.LogLevel != "info" {
+		t.Errorf("want config.log_level info, got %q", entry.Config.LogLevel)
+	}
+	if entry.Config.Mongo.Host != "mongo" {
+		t.Errorf("want config.mongo.host mongo, got %q", entry.Config.Mongo.Host)
+	}
+	if entry.Config.Mongo.Password != "[REDACTED]" {
+		t.Errorf("want config.mongo.password redacted, got %q", entry.Config.Mongo.Password)
+	}
+	if entry.Config.Mongo.Timeout != int64(time.Second) {
+		t.Errorf("want config.mongo.timeout 1s, got %d", entry.Config.Mongo.Timeout)
+	}
+	if entry.Config.Endpoint != "http://localhost" {
+		t.Errorf("want config.endpoint http://localhost, got %q", entry.Config.Endpoint)
+	}
+	if !reflect.DeepEqual(entry.Config.Buckets, []string{"foo", "bar"}) {
+		t.Errorf("want config.buckets [foo bar], got %v", entry.Config.Buckets)
+	}
+}
+
+func TestSlogConfigEmbedded(t *testing.T) {
+	t.Parallel()
+
+	type Common struct {
+		LogLevel string
+	}
+
+	cfg := struct {
+		Common
+		Port int
+	}{Common: Common{LogLevel: "info"}, Port: 8080}
+
+	var logs bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&logs, nil))
+	log.Info("starting", SlogConfig(&cfg))
+
+	if want := `"config":{"log_level":"info","port":8080}`; !strings.Contains(logs.String(), want) {
+		t.Fatalf("want promoted fields %s, got %s", want, logs.String())
+	}
+}
+
+func TestSlogConfigNonStruct(t *testing.T) {
+	t.Parallel()
+
+	attr := SlogConfig("foo")
+	if attr.Key != "config" || len(attr.Value.Group()) != 0 {
+		t.Fatalf("want empty config group, got %s=%s", attr.Key, attr.Value)
+	}
+}
+
 func TestExitWritesMessageAndExits(t *testing.T) {
 	var output bytes.Buffer
 	stderr := os.Stderr
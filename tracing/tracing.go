@@ -0,0 +1,74 @@
+// Package tracing provides OpenTelemetry HTTP tracing middleware for bee apps.
+package tracing
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"go.acim.net/bee"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type requestKey struct{}
+
+// Middleware starts a server span for every request using otelhttp, which extracts trace context
+// from request headers and records attributes following OpenTelemetry HTTP semantic conventions,
+// using the global tracer provider and propagator unless configured otherwise by opts. The span is
+// named by the method and the ServeMux pattern matched by the request, i.e. "GET /things/{id}",
+// and marked as error for 5xx responses or when a handler reports an error using
+// bee.SetHandlerError. Without configured OpenTelemetry SDK, spans are not recorded.
+// bee.SlogLogger added after Middleware logs trace and span IDs.
+func Middleware(opts ...otelhttp.Option) func(next http.Handler) http.Handler {
+	opts = append([]otelhttp.Option{
+		otelhttp.WithSpanNameFormatter(func(_ string, req *http.Request) string { return req.Method }),
+	}, opts...)
+
+	return func(next http.Handler) http.Handler {
+		handler := otelhttp.NewHandler(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			span := trace.SpanFromContext(req.Context())
+			ctx, handlerErr := bee.ContextWithHandlerError(req.Context())
+
+			if sc := span.SpanContext(); sc.IsValid() {
+				ctx = bee.ContextWithLogAttrs(ctx, slog.String("trace_id", sc.TraceID().String()),
+					slog.String("span_id", sc.SpanID().String()))
+			}
+
+			r := req.WithContext(ctx)
+			next.ServeHTTP(res, r)
+
+			// copy the pattern back to the request otelhttp was called with, so middleware
+			// wrapping this one see the matched route
+			if outer, ok := req.Context().Value(requestKey{}).(*http.Request); ok {
+				outer.Pattern = r.Pattern
+			}
+
+			if r.Pattern != "" {
+				span.SetName(spanName(r.Method, r.Pattern))
+				span.SetAttributes(semconv.HTTPRoute(r.Pattern))
+			}
+
+			if err := handlerErr(); err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+		}), "", opts...)
+
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			handler.ServeHTTP(res, req.WithContext(context.WithValue(req.Context(), requestKey{}, req)))
+		})
+	}
+}
+
+// spanName returns pattern if it includes method, otherwise method followed by pattern.
+func spanName(method, pattern string) string {
+	if strings.Contains(pattern, " ") {
+		return pattern
+	}
+
+	return method + " " + pattern
+}
@@ -0,0 +1,120 @@
+package tracing_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.acim.net/bee"
+	"go.acim.net/bee/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestMiddleware(t *testing.T) { //nolint:paralleltest // sets global tracer provider and propagator
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	})
+
+	var logs bytes.Buffer
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /things/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") == "2" {
+			bee.SetHandlerError(r.Context(), errors.New("database unavailable"))
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/fail", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	var mws bee.Middlewares
+	mws.Add(tracing.Middleware())
+	mws.Add(bee.SlogLogger(slog.New(slog.NewJSONHandler(&logs, nil))))
+	handler := mws.Wrap(mux)
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	req := httptest.NewRequest(http.MethodGet, "/things/1", nil)
+	req.Header.Set("Traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/things/2", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/fail", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	spans := recorder.Ended()
+	if len(spans) != 4 {
+		t.Fatalf("want 4 spans, got %d", len(spans))
+	}
+
+	tests := []struct {
+		name       string
+		status     codes.Code
+		statusCode int64
+		route      string
+	}{
+		{name: "GET /things/{id}", status: codes.Unset, statusCode: http.StatusOK, route: "GET /things/{id}"},
+		{name: "GET /things/{id}", status: codes.Error, statusCode: http.StatusOK, route: "GET /things/{id}"},
+		{name: "POST /fail", status: codes.Error, statusCode: http.StatusBadGateway, route: "/fail"},
+		{name: "GET", status: codes.Unset, statusCode: http.StatusNotFound, route: ""},
+	}
+
+	for i, tt := range tests {
+		span := spans[i]
+		if span.Name() != tt.name {
+			t.Errorf("span %d: want name %q, got %q", i, tt.name, span.Name())
+		}
+
+		if span.Status().Code != tt.status {
+			t.Errorf("span %d: want status %v, got %v", i, tt.status, span.Status().Code)
+		}
+
+		attrs := attribute.NewSet(span.Attributes()...)
+
+		// otelhttp records semantic conventions v1.20 unless OTEL_SEMCONV_STABILITY_OPT_IN is set
+		if got, _ := attrs.Value("http.status_code"); got.AsInt64() != tt.statusCode {
+			t.Errorf("span %d: want status code %d, got %d", i, tt.statusCode, got.AsInt64())
+		}
+
+		if got, _ := attrs.Value("http.route"); got.AsString() != tt.route {
+			t.Errorf("span %d: want route %q, got %q", i, tt.route, got.AsString())
+		}
+	}
+
+	if got := spans[0].SpanContext().TraceID().String(); got != traceID {
+		t.Fatalf("want span with extracted trace id %s, got %s", traceID, got)
+	}
+
+	if got := spans[1].Status().Description; got != "database unavailable" {
+		t.Fatalf("want handler error status description, got %q", got)
+	}
+
+	var entry map[string]any
+	if err := json.NewDecoder(&logs).Decode(&entry); err != nil {
+		t.Fatalf("decode log entry: %v", err)
+	}
+
+	if got := entry["trace_id"]; got != traceID {
+		t.Fatalf("want log trace_id=%s, got %v", traceID, got)
+	}
+
+	if want := spans[0].SpanContext().SpanID().String(); entry["span_id"] != want {
+		t.Fatalf("want log span_id=%s, got %v", want, entry["span_id"])
+	}
+}
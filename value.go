@@ -1,10 +1,17 @@
 package bee
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
+	"net"
+	"net/netip"
 	"net/url"
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -13,11 +20,15 @@ type StringSlice []string
 
 // Set sets flag's value by splitting provided comma separated string.
 func (f *StringSlice) Set(s string) error {
+	return f.setSeparated(s, ",")
+}
+
+func (f *StringSlice) setSeparated(s, sep string) error {
 	if s == "" {
 		return nil
 	}
 
-	*f = strings.Split(s, ",")
+	*f = strings.Split(s, sep)
 
 	return nil
 }
@@ -40,27 +51,45 @@ func (f *StringSlice) Get() any {
 	return []string(*f)
 }
 
+// MarshalJSON encodes value as JSON array, empty if value is nil.
+func (f StringSlice) MarshalJSON() ([]byte, error) {
+	if f == nil {
+		return []byte("[]"), nil
+	}
+
+	return json.Marshal([]string(f)) //nolint:wrapcheck
+}
+
+// UnmarshalJSON decodes JSON array of strings.
+func (f *StringSlice) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, (*[]string)(f)) //nolint:wrapcheck
+}
+
 // IntSlice implements flag.Getter interface for []int type.
 type IntSlice []int
 
 // Set sets flag's value by splitting provided comma separated string.
 func (f *IntSlice) Set(s string) error {
+	return f.setSeparated(s, ",")
+}
+
+func (f *IntSlice) setSeparated(s, sep string) error {
 	if s == "" {
 		return nil
 	}
 
-	vs := strings.Split(s, ",")
+	vs := strings.Split(s, sep)
 	*f = make([]int, 0, len(vs))
 
 	for _, v := range vs {
-		i, err := strconv.Atoi(v)
+		i, err := strconv.ParseInt(v, 0, strconv.IntSize)
 		if err != nil {
 			*f = []int{}
 
 			return fmt.Errorf("parsing int: %w", err)
 		}
 
-		*f = append(*f, i)
+		*f = append(*f, int(i))
 	}
 
 	return nil
@@ -89,6 +118,375 @@ func (f *IntSlice) Get() any {
 	return []int(*f)
 }
 
+// MarshalJSON encodes value as JSON array, empty if value is nil.
+func (f IntSlice) MarshalJSON() ([]byte, error) {
+	if f == nil {
+		return []byte("[]"), nil
+	}
+
+	return json.Marshal([]int(f)) //nolint:wrapcheck
+}
+
+// UnmarshalJSON decodes JSON array of integers.
+func (f *IntSlice) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, (*[]int)(f)) //nolint:wrapcheck
+}
+
+// Float64Slice implements flag.Getter interface for []float64 type.
+type Float64Slice []float64
+
+// Set sets flag's value by splitting provided comma separated string.
+func (f *Float64Slice) Set(s string) error {
+	return f.setSeparated(s, ",")
+}
+
+func (f *Float64Slice) setSeparated(s, sep string) error {
+	if s == "" {
+		return nil
+	}
+
+	vs := strings.Split(s, sep)
+	*f = make([]float64, 0, len(vs))
+
+	for i, v := range vs {
+		n, err := strconv.ParseFloat(v, 64) //nolint:gomnd
+		if err != nil {
+			*f = []float64{}
+
+			return fmt.Errorf("parsing float64 element %d: %w", i, err)
+		}
+
+		*f = append(*f, n)
+	}
+
+	return nil
+}
+
+// String formats flag's value.
+func (f *Float64Slice) String() string {
+	if f != nil {
+		if len(*f) == 0 {
+			return "[]"
+		}
+
+		s := make([]string, 0, len(*f))
+		for _, n := range *f {
+			s = append(s, strconv.FormatFloat(n, 'g', -1, 64)) //nolint:gomnd
+		}
+
+		return fmt.Sprintf("[%s]", strings.Join(s, `,`))
+	}
+
+	return ""
+}
+
+// Get returns flag's value.
+func (f *Float64Slice) Get() any {
+	return []float64(*f)
+}
+
+// BoolSlice implements flag.Getter interface for []bool type.
+type BoolSlice []bool
+
+// Set sets flag's value by splitting provided comma separated string.
+func (f *BoolSlice) Set(s string) error {
+	return f.setSeparated(s, ",")
+}
+
+func (f *BoolSlice) setSeparated(s, sep string) error {
+	if s == "" {
+		return nil
+	}
+
+	vs := strings.Split(s, sep)
+	*f = make([]bool, 0, len(vs))
+
+	for i, v := range vs {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			*f = []bool{}
+
+			return fmt.Errorf("parsing bool element %d: %w", i, err)
+		}
+
+		*f = append(*f, b)
+	}
+
+	return nil
+}
+
+// String formats flag's value.
+func (f *BoolSlice) String() string {
+	if f != nil {
+		if len(*f) == 0 {
+			return "[]"
+		}
+
+		s := make([]string, 0, len(*f))
+		for _, b := range *f {
+			s = append(s, strconv.FormatBool(b))
+		}
+
+		return fmt.Sprintf("[%s]", strings.Join(s, `,`))
+	}
+
+	return ""
+}
+
+// Get returns flag's value.
+func (f *BoolSlice) Get() any {
+	return []bool(*f)
+}
+
+// DurationSlice implements flag.Getter interface for []time.Duration type.
+type DurationSlice []time.Duration
+
+// Set sets flag's value by splitting provided comma separated string.
+func (f *DurationSlice) Set(s string) error {
+	return f.setSeparated(s, ",")
+}
+
+func (f *DurationSlice) setSeparated(s, sep string) error {
+	if s == "" {
+		return nil
+	}
+
+	vs := strings.Split(s, sep)
+	*f = make([]time.Duration, 0, len(vs))
+
+	for i, v := range vs {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			*f = []time.Duration{}
+
+			return fmt.Errorf("parsing duration element %d: %w", i, err)
+		}
+
+		*f = append(*f, d)
+	}
+
+	return nil
+}
+
+// String formats flag's value.
+func (f *DurationSlice) String() string {
+	if f != nil {
+		if len(*f) == 0 {
+			return "[]"
+		}
+
+		s := make([]string, 0, len(*f))
+		for _, d := range *f {
+			s = append(s, d.String())
+		}
+
+		return fmt.Sprintf("[%s]", strings.Join(s, `,`))
+	}
+
+	return ""
+}
+
+// Get returns flag's value.
+func (f *DurationSlice) Get() any {
+	return []time.Duration(*f)
+}
+
+// TimeSlice implements flag.Getter interface for []time.Time type.
+type TimeSlice []time.Time
+
+// Set sets flag's value by splitting provided comma separated string of RFC3339 times.
+func (f *TimeSlice) Set(s string) error {
+	return f.setSeparated(s, ",")
+}
+
+func (f *TimeSlice) setSeparated(s, sep string) error {
+	if s == "" {
+		return nil
+	}
+
+	vs := strings.Split(s, sep)
+	*f = make([]time.Time, 0, len(vs))
+
+	for i, v := range vs {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			*f = []time.Time{}
+
+			return fmt.Errorf("parsing time element %d: %w", i, err)
+		}
+
+		*f = append(*f, t)
+	}
+
+	return nil
+}
+
+// String formats flag's value.
+func (f *TimeSlice) String() string {
+	if f != nil {
+		if len(*f) == 0 {
+			return "[]"
+		}
+
+		s := make([]string, 0, len(*f))
+		for _, t := range *f {
+			s = append(s, t.Format(time.RFC3339))
+		}
+
+		return fmt.Sprintf("[%s]", strings.Join(s, `,`))
+	}
+
+	return ""
+}
+
+// Get returns flag's value.
+func (f *TimeSlice) Get() any {
+	return []time.Time(*f)
+}
+
+// Counter implements flag.Getter interface for count-style flags, i.e. -v -v -v for verbosity.
+// Each occurrence of the flag without value increments the counter, while numeric value, i.e.
+// -v=3 or environment variable, sets it. Occurrences are added to the value from environment
+// variable or default.
+type Counter int
+
+// Set increments the counter for "true", which the flag package passes for flags without value,
+// or sets it to provided number.
+func (f *Counter) Set(s string) error {
+	switch s {
+	case "true":
+		*f++
+
+		return nil
+	case "false":
+		*f = 0
+
+		return nil
+	}
+
+	n, err := strconv.ParseInt(s, 0, strconv.IntSize)
+	if err != nil {
+		return fmt.Errorf("parsing counter: %w", err)
+	}
+
+	*f = Counter(n)
+
+	return nil
+}
+
+// String formats flag's value.
+func (f *Counter) String() string {
+	if f == nil {
+		return "0"
+	}
+
+	return strconv.Itoa(int(*f))
+}
+
+// Get returns flag's value.
+func (f *Counter) Get() any {
+	if f == nil {
+		return 0
+	}
+
+	return int(*f)
+}
+
+// IsBoolFlag allows the flag to be used without value.
+func (*Counter) IsBoolFlag() bool {
+	return true
+}
+
+// Complex implements flag.Getter interface for complex128 type, i.e. "1+2i".
+type Complex complex128
+
+// Set sets flag's value by parsing provided complex number.
+func (f *Complex) Set(s string) error {
+	c, err := strconv.ParseComplex(s, 128) //nolint:gomnd
+	if err != nil {
+		return fmt.Errorf("parsing complex: %w", err)
+	}
+
+	*f = Complex(c)
+
+	return nil
+}
+
+// String formats flag's value.
+func (f *Complex) String() string {
+	if f == nil {
+		return "(0+0i)"
+	}
+
+	return strconv.FormatComplex(complex128(*f), 'g', -1, 128) //nolint:gomnd
+}
+
+// Get returns flag's value.
+func (f *Complex) Get() any {
+	if f == nil {
+		return complex128(0)
+	}
+
+	return complex128(*f)
+}
+
+// DurationMap implements flag.Getter interface for map[string]time.Duration type.
+type DurationMap map[string]time.Duration
+
+// Set sets flag's value by splitting provided comma separated key=duration pairs.
+func (f *DurationMap) Set(s string) error {
+	if s == "" {
+		return nil
+	}
+
+	vs := strings.Split(s, ",")
+	m := make(map[string]time.Duration, len(vs))
+
+	for _, v := range vs {
+		key, value, ok := strings.Cut(v, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("parsing duration map: invalid pair %q", v)
+		}
+
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("parsing duration for key %q: %w", key, err)
+		}
+
+		m[key] = d
+	}
+
+	*f = m
+
+	return nil
+}
+
+// String formats flag's value.
+func (f *DurationMap) String() string {
+	if f != nil {
+		keys := make([]string, 0, len(*f))
+		for k := range *f {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+
+		s := make([]string, 0, len(keys))
+		for _, k := range keys {
+			s = append(s, fmt.Sprintf("%s=%s", k, (*f)[k]))
+		}
+
+		return fmt.Sprintf("[%s]", strings.Join(s, ","))
+	}
+
+	return ""
+}
+
+// Get returns flag's value.
+func (f *DurationMap) Get() any {
+	return map[string]time.Duration(*f)
+}
+
 // URL implements flag.Getter interface for url.URL type.
 type URL struct {
 	*url.URL
@@ -124,16 +522,39 @@ func (f *URL) Get() any {
 	return *f.URL
 }
 
+// MarshalJSON encodes value as JSON string, or null if URL is not set.
+func (f URL) MarshalJSON() ([]byte, error) {
+	if f.URL == nil {
+		return []byte("null"), nil
+	}
+
+	return json.Marshal(f.URL.String()) //nolint:wrapcheck
+}
+
+// UnmarshalJSON decodes JSON string by parsing it as url. JSON null leaves the value unchanged.
+func (f *URL) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("parsing url: %w", err)
+	}
+
+	return f.Set(s)
+}
+
 // Time implements flag.Getter interface for time.Time type.
 type Time struct {
 	*time.Time
 }
 
-// Set sets flag's value by parsing provided RFC3339 time.
+// Set sets flag's value by parsing provided RFC3339 or relative time, i.e. "now+1h" or "now+1mo".
 func (f *Time) Set(s string) error {
-	t, err := time.Parse(time.RFC3339, s)
+	t, err := parseTime(s)
 	if err != nil {
-		return fmt.Errorf("parsing time: %w", err)
+		return err
 	}
 
 	f.Time = &t
@@ -158,3 +579,457 @@ func (f *Time) Get() any {
 
 	return *f.Time
 }
+
+// MarshalJSON encodes value as RFC3339 JSON string, including fractional seconds if any, or null
+// if time is not set.
+func (f Time) MarshalJSON() ([]byte, error) {
+	if f.Time == nil {
+		return []byte("null"), nil
+	}
+
+	return json.Marshal(f.Time.Format(time.RFC3339Nano)) //nolint:wrapcheck
+}
+
+// UnmarshalJSON decodes JSON string by parsing it as RFC3339 or relative time, like Set. JSON null
+// leaves the value unchanged.
+func (f *Time) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("parsing time: %w", err)
+	}
+
+	return f.Set(s)
+}
+
+var (
+	sliceParsersMu sync.RWMutex
+	sliceParsers   = map[reflect.Type]func(string) (any, error){}
+)
+
+// RegisterSliceParser registers function parsing one element of slices with the given element type.
+// Config fields of such slice types are then parsed from comma separated values.
+func RegisterSliceParser(elemType reflect.Type, parse func(string) (any, error)) {
+	sliceParsersMu.Lock()
+	defer sliceParsersMu.Unlock()
+
+	sliceParsers[elemType] = parse
+}
+
+func lookupSliceParser(elemType reflect.Type) (func(string) (any, error), bool) {
+	sliceParsersMu.RLock()
+	defer sliceParsersMu.RUnlock()
+
+	parse, ok := sliceParsers[elemType]
+
+	return parse, ok
+}
+
+// sliceValue implements flag.Value interface for slices with registered element parser.
+type sliceValue struct {
+	value reflect.Value
+	parse func(string) (any, error)
+	sep   string
+}
+
+// Set sets flag's value by splitting provided string using the separator.
+func (f *sliceValue) Set(s string) error {
+	if s == "" {
+		return nil
+	}
+
+	vs := strings.Split(s, f.sep)
+	slice := reflect.MakeSlice(f.value.Type(), 0, len(vs))
+	elemType := f.value.Type().Elem()
+
+	for i, v := range vs {
+		e, err := f.parse(v)
+		if err != nil {
+			return fmt.Errorf("parsing slice element %d: %w", i, err)
+		}
+
+		ev := reflect.ValueOf(e)
+		if !ev.IsValid() || !ev.Type().AssignableTo(elemType) {
+			return fmt.Errorf("parsing slice element %d: parser returned %T, want %s", i, e, elemType)
+		}
+
+		slice = reflect.Append(slice, ev)
+	}
+
+	f.value.Set(slice)
+
+	return nil
+}
+
+// String formats flag's value.
+func (f *sliceValue) String() string {
+	if f == nil || !f.value.IsValid() || f.value.Len() == 0 {
+		return ""
+	}
+
+	s := make([]string, 0, f.value.Len())
+	for i := range f.value.Len() {
+		s = append(s, fmt.Sprint(f.value.Index(i).Interface()))
+	}
+
+	return fmt.Sprintf("[%s]", strings.Join(s, ","))
+}
+
+// separatedSetter is implemented by slice types which may be split using separator other than comma.
+type separatedSetter interface {
+	flag.Value
+	setSeparated(s, sep string) error
+}
+
+// separatedValue implements flag.Value interface for slices split using separator set by sep tag.
+type separatedValue struct {
+	value separatedSetter
+	sep   string
+}
+
+// Set sets flag's value by splitting provided string using the separator.
+func (f *separatedValue) Set(s string) error {
+	return f.value.setSeparated(s, f.sep)
+}
+
+// String formats flag's value.
+func (f *separatedValue) String() string {
+	if f == nil || f.value == nil {
+		return "[]"
+	}
+
+	return f.value.String()
+}
+
+// timeNow returns the current time relative times are resolved against.
+var timeNow = time.Now //nolint:gochecknoglobals
+
+// parseTime parses RFC3339 time or time relative to now, i.e. "now", "now-30m" or "now+1mo". The
+// mo unit adds calendar months using time.AddDate, so "now+1mo" on January 15th is February 15th
+// regardless of the month length, while other units are parsed by time.ParseDuration.
+func parseTime(s string) (time.Time, error) {
+	rest, relative := strings.CutPrefix(s, "now")
+	if !relative {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("parsing time: %w", err)
+		}
+
+		return t, nil
+	}
+
+	now := timeNow()
+	if rest == "" {
+		return now, nil
+	}
+
+	if rest[0] != '+' && rest[0] != '-' {
+		return time.Time{}, fmt.Errorf("parsing time %q: invalid relative time", s)
+	}
+
+	if months, ok := strings.CutSuffix(rest, "mo"); ok {
+		n, err := strconv.Atoi(months)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("parsing time %q: invalid relative time", s)
+		}
+
+		return now.AddDate(0, n, 0), nil
+	}
+
+	d, err := time.ParseDuration(rest)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parsing time: %w", err)
+	}
+
+	return now.Add(d), nil
+}
+
+// timeValue implements flag.Value interface for time.Time type.
+type timeValue time.Time
+
+// Set sets flag's value by parsing provided RFC3339 or relative time.
+func (f *timeValue) Set(s string) error {
+	t, err := parseTime(s)
+	if err != nil {
+		return err
+	}
+
+	*f = timeValue(t)
+
+	return nil
+}
+
+// String formats flag's value.
+func (f *timeValue) String() string {
+	if f == nil || time.Time(*f).IsZero() {
+		return ""
+	}
+
+	return time.Time(*f).Format(time.RFC3339)
+}
+
+// ipValue implements flag.Value interface for net.IP type.
+type ipValue net.IP
+
+// Set sets flag's value by parsing provided IPv4 or IPv6 address.
+func (f *ipValue) Set(s string) error {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return fmt.Errorf("parsing ip %q: invalid address", s)
+	}
+
+	*f = ipValue(ip)
+
+	return nil
+}
+
+// String formats flag's value.
+func (f *ipValue) String() string {
+	if f == nil || len(*f) == 0 {
+		return ""
+	}
+
+	return net.IP(*f).String()
+}
+
+// ipNetValue implements flag.Value interface for net.IPNet type.
+type ipNetValue net.IPNet
+
+// Set sets flag's value by parsing provided CIDR, i.e. "10.0.0.0/8".
+func (f *ipNetValue) Set(s string) error {
+	_, ipNet, err := net.ParseCIDR(s)
+	if err != nil {
+		return fmt.Errorf("parsing cidr: %w", err)
+	}
+
+	*f = ipNetValue(*ipNet)
+
+	return nil
+}
+
+// String formats flag's value.
+func (f *ipNetValue) String() string {
+	if f == nil || len(f.IP) == 0 {
+		return ""
+	}
+
+	return (*net.IPNet)(f).String()
+}
+
+// prefixValue implements flag.Value interface for netip.Prefix type.
+type prefixValue netip.Prefix
+
+// Set sets flag's value by parsing provided CIDR, i.e. "10.0.0.0/8".
+func (f *prefixValue) Set(s string) error {
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return fmt.Errorf("parsing prefix: %w", err)
+	}
+
+	*f = prefixValue(prefix)
+
+	return nil
+}
+
+// String formats flag's value.
+func (f *prefixValue) String() string {
+	if f == nil || !netip.Prefix(*f).IsValid() {
+		return ""
+	}
+
+	return netip.Prefix(*f).String()
+}
+
+// rawJSONValue implements flag.Value interface for json.RawMessage type.
+type rawJSONValue json.RawMessage
+
+// Set sets flag's value to raw bytes of provided JSON.
+func (f *rawJSONValue) Set(s string) error {
+	var raw json.RawMessage
+	if err := json.Unmarshal([]byte(s), &raw); err != nil {
+		return fmt.Errorf("parsing json: %w", err)
+	}
+
+	*f = rawJSONValue(raw)
+
+	return nil
+}
+
+// String formats flag's value.
+func (f *rawJSONValue) String() string {
+	if f == nil {
+		return ""
+	}
+
+	return string(*f)
+}
+
+// arrayValue implements flag.Value interface for fixed-size arrays.
+type arrayValue struct {
+	value reflect.Value
+	sep   string
+}
+
+// Set sets flag's value by splitting provided string using the separator.
+func (f *arrayValue) Set(s string) error {
+	vs := strings.Split(s, f.sep)
+	if len(vs) != f.value.Len() {
+		return fmt.Errorf("parsing array: got %d elements, want %d", len(vs), f.value.Len())
+	}
+
+	arr := reflect.New(f.value.Type()).Elem()
+	for i, v := range vs {
+		if err := setElement(arr.Index(i), v); err != nil {
+			return fmt.Errorf("parsing array element %d: %w", i, err)
+		}
+	}
+
+	f.value.Set(arr)
+
+	return nil
+}
+
+// String formats flag's value.
+func (f *arrayValue) String() string {
+	if f == nil || !f.value.IsValid() || f.value.IsZero() {
+		return ""
+	}
+
+	s := make([]string, 0, f.value.Len())
+	for i := range f.value.Len() {
+		s = append(s, fmt.Sprint(f.value.Index(i).Interface()))
+	}
+
+	return fmt.Sprintf("[%s]", strings.Join(s, ","))
+}
+
+// pointerValue implements flag.Value interface for pointers to basic types. The pointed-to
+// value is allocated only when the flag is set.
+type pointerValue struct {
+	value reflect.Value
+}
+
+// Set sets flag's value by allocating and parsing the pointed-to value.
+func (f *pointerValue) Set(s string) error {
+	v := reflect.New(f.value.Type().Elem())
+	if err := setElement(v.Elem(), s); err != nil {
+		return err
+	}
+
+	f.value.Set(v)
+
+	return nil
+}
+
+// String formats flag's value.
+func (f *pointerValue) String() string {
+	if f == nil || !f.value.IsValid() || f.value.IsNil() {
+		return ""
+	}
+
+	return fmt.Sprint(f.value.Elem().Interface())
+}
+
+// IsBoolFlag allows pointers to bool to be set without value, same as bool flags.
+func (f *pointerValue) IsBoolFlag() bool {
+	return f.value.Type().Elem().Kind() == reflect.Bool
+}
+
+// sizedValue implements flag.Value interface for fixed-width numbers, i.e. int16 or float32, which
+// flag package doesn't support directly.
+type sizedValue struct {
+	value reflect.Value
+}
+
+// Set sets flag's value by parsing provided number with the bit size of the underlying type.
+func (f *sizedValue) Set(s string) error {
+	v := reflect.New(f.value.Type()).Elem()
+	if err := setElement(v, s); err != nil {
+		return err
+	}
+
+	f.value.Set(v)
+
+	return nil
+}
+
+// String formats flag's value.
+func (f *sizedValue) String() string {
+	if f == nil || !f.value.IsValid() || f.value.IsZero() {
+		return ""
+	}
+
+	return fmt.Sprint(f.value.Interface())
+}
+
+func isElementType(t reflect.Type) bool {
+	switch t.Kind() { //nolint:exhaustive
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return true
+	default:
+		return false
+	}
+}
+
+func setElement(v reflect.Value, s string) error { //nolint:cyclop
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("parsing duration: %w", err)
+		}
+
+		v.SetInt(int64(d))
+
+		return nil
+	}
+
+	switch v.Kind() { //nolint:exhaustive
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("parsing bool: %w", err)
+		}
+
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 0, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("parsing int: %w", err)
+		}
+
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 0, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("parsing uint: %w", err)
+		}
+
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("parsing float: %w", err)
+		}
+
+		v.SetFloat(f)
+	case reflect.Complex64, reflect.Complex128:
+		c, err := strconv.ParseComplex(s, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("parsing complex: %w", err)
+		}
+
+		v.SetComplex(c)
+	default:
+		return fmt.Errorf("%w: %v", ErrUnsupportedType, v.Type())
+	}
+
+	return nil
+}
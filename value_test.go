@@ -1,6 +1,7 @@
 package bee_test
 
 import (
+	"encoding/json"
 	"flag"
 	"net/url"
 	"reflect"
@@ -13,6 +14,13 @@ import (
 var (
 	_ flag.Getter = (*bee.StringSlice)(nil)
 	_ flag.Getter = (*bee.IntSlice)(nil)
+	_ flag.Getter = (*bee.Float64Slice)(nil)
+	_ flag.Getter = (*bee.BoolSlice)(nil)
+	_ flag.Getter = (*bee.DurationSlice)(nil)
+	_ flag.Getter = (*bee.TimeSlice)(nil)
+	_ flag.Getter = (*bee.Counter)(nil)
+	_ flag.Getter = (*bee.Complex)(nil)
+	_ flag.Getter = (*bee.DurationMap)(nil)
 	_ flag.Getter = (*bee.URL)(nil)
 	_ flag.Getter = (*bee.Time)(nil)
 )
@@ -93,6 +101,12 @@ func TestIntSlice(t *testing.T) { //nolint:funlen
 			[]int{1, 2, 3},
 			false,
 		},
+		"prefixed": {
+			"0x10,0o17,0b11,-8",
+			"[16,15,3,-8]",
+			[]int{16, 15, 3, -8},
+			false,
+		},
 		"invalid-one": {
 			"foo",
 			"[]",
@@ -136,6 +150,409 @@ func TestIntSlice(t *testing.T) { //nolint:funlen
 	}
 }
 
+func TestFloat64Slice(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		in         string
+		wantString string
+		wantGet    []float64
+		wantErr    string
+	}{
+		"empty": {
+			in:         "",
+			wantString: "[]",
+			wantGet:    []float64{},
+		},
+		"multi": {
+			in:         "0.5,1e3,-2",
+			wantString: "[0.5,1000,-2]",
+			wantGet:    []float64{0.5, 1000, -2},
+		},
+		"invalid-element": {
+			in:      "0.5,a",
+			wantErr: `parsing float64 element 1: strconv.ParseFloat: parsing "a": invalid syntax`,
+		},
+	}
+
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			f := &bee.Float64Slice{}
+
+			err := f.Set(tt.in)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("want error %q, got %v", tt.wantErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := f.String(); got != tt.wantString {
+				t.Errorf("want %s got %s", tt.wantString, got)
+			}
+
+			if got := f.Get(); !reflect.DeepEqual(got, tt.wantGet) {
+				t.Errorf("want %v got %v", tt.wantGet, got)
+			}
+		})
+	}
+}
+
+func TestBoolSlice(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		in         string
+		wantString string
+		wantGet    []bool
+		wantErr    string
+	}{
+		"empty": {
+			in:         "",
+			wantString: "[]",
+			wantGet:    []bool{},
+		},
+		"multi": {
+			in:         "true,false,1,F",
+			wantString: "[true,false,true,false]",
+			wantGet:    []bool{true, false, true, false},
+		},
+		"invalid-element": {
+			in:      "true,a",
+			wantErr: `parsing bool element 1: strconv.ParseBool: parsing "a": invalid syntax`,
+		},
+	}
+
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			f := &bee.BoolSlice{}
+
+			err := f.Set(tt.in)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("want error %q, got %v", tt.wantErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := f.String(); got != tt.wantString {
+				t.Errorf("want %s got %s", tt.wantString, got)
+			}
+
+			if got := f.Get(); !reflect.DeepEqual(got, tt.wantGet) {
+				t.Errorf("want %v got %v", tt.wantGet, got)
+			}
+		})
+	}
+}
+
+func TestDurationSlice(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		in         string
+		wantString string
+		wantGet    []time.Duration
+		wantErr    string
+	}{
+		"empty": {
+			in:         "",
+			wantString: "[]",
+			wantGet:    []time.Duration{},
+		},
+		"multi": {
+			in:         "1s,5s,30s",
+			wantString: "[1s,5s,30s]",
+			wantGet:    []time.Duration{time.Second, 5 * time.Second, 30 * time.Second},
+		},
+		"invalid-element": {
+			in:      "1s,a",
+			wantErr: `parsing duration element 1: time: invalid duration "a"`,
+		},
+	}
+
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			f := &bee.DurationSlice{}
+
+			err := f.Set(tt.in)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("want error %q, got %v", tt.wantErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := f.String(); got != tt.wantString {
+				t.Errorf("want %s got %s", tt.wantString, got)
+			}
+
+			if got := f.Get(); !reflect.DeepEqual(got, tt.wantGet) {
+				t.Errorf("want %v got %v", tt.wantGet, got)
+			}
+		})
+	}
+}
+
+func TestTimeSlice(t *testing.T) {
+	t.Parallel()
+
+	first := time.Date(2024, time.January, 6, 22, 0, 0, 0, time.UTC)
+	second := time.Date(2024, time.January, 13, 22, 0, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		in         string
+		wantString string
+		wantGet    []time.Time
+		wantErr    string
+	}{
+		"empty": {
+			in:         "",
+			wantString: "[]",
+			wantGet:    []time.Time{},
+		},
+		"multi": {
+			in:         "2024-01-06T22:00:00Z,2024-01-13T22:00:00Z",
+			wantString: "[2024-01-06T22:00:00Z,2024-01-13T22:00:00Z]",
+			wantGet:    []time.Time{first, second},
+		},
+		"invalid-element": {
+			in:      "2024-01-06T22:00:00Z,tomorrow",
+			wantErr: `parsing time element 1: parsing time "tomorrow" as "2006-01-02T15:04:05Z07:00": cannot parse "tomorrow" as "2006"`,
+		},
+	}
+
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			f := &bee.TimeSlice{}
+
+			err := f.Set(tt.in)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("want error %q, got %v", tt.wantErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := f.String(); got != tt.wantString {
+				t.Errorf("want %s got %s", tt.wantString, got)
+			}
+
+			if got := f.Get(); !reflect.DeepEqual(got, tt.wantGet) {
+				t.Errorf("want %v got %v", tt.wantGet, got)
+			}
+		})
+	}
+}
+
+func TestCounter(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		in      []string
+		want    int
+		wantErr string
+	}{
+		"increment": {
+			in:   []string{"true", "true", "true"},
+			want: 3,
+		},
+		"number": {
+			in:   []string{"2", "true"},
+			want: 3,
+		},
+		"reset": {
+			in:   []string{"true", "false"},
+			want: 0,
+		},
+		"invalid": {
+			in:      []string{"a"},
+			wantErr: `parsing counter: strconv.ParseInt: parsing "a": invalid syntax`,
+		},
+	}
+
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			f := new(bee.Counter)
+
+			for _, in := range tt.in {
+				err := f.Set(in)
+				if tt.wantErr != "" {
+					if err == nil || err.Error() != tt.wantErr {
+						t.Fatalf("want error %q, got %v", tt.wantErr, err)
+					}
+
+					return
+				}
+
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if got := f.Get(); got != tt.want {
+				t.Errorf("want %v got %v", tt.want, got)
+			}
+
+			if !f.IsBoolFlag() {
+				t.Error("want bool flag")
+			}
+		})
+	}
+}
+
+func TestComplex(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		in         string
+		wantString string
+		wantGet    complex128
+		wantErr    string
+	}{
+		"complex": {
+			in:         "1+2i",
+			wantString: "(1+2i)",
+			wantGet:    complex(1, 2),
+		},
+		"real": {
+			in:         "-1.5",
+			wantString: "(-1.5+0i)",
+			wantGet:    complex(-1.5, 0),
+		},
+		"invalid": {
+			in:      "1+",
+			wantErr: `parsing complex: strconv.ParseComplex: parsing "1+": invalid syntax`,
+		},
+	}
+
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			f := new(bee.Complex)
+
+			err := f.Set(tt.in)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("want error %q, got %v", tt.wantErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := f.String(); got != tt.wantString {
+				t.Errorf("want %s got %s", tt.wantString, got)
+			}
+
+			if got := f.Get(); got != tt.wantGet {
+				t.Errorf("want %v got %v", tt.wantGet, got)
+			}
+		})
+	}
+}
+
+func TestDurationMap(t *testing.T) { //nolint:funlen
+	t.Parallel()
+
+	tests := map[string]struct {
+		in         string
+		wantString string
+		wantGet    map[string]time.Duration
+		wantErr    string
+	}{
+		"empty": {
+			in:         "",
+			wantString: "[]",
+			wantGet:    map[string]time.Duration{},
+		},
+		"multi": {
+			in:         "write=10s,read=5s",
+			wantString: "[read=5s,write=10s]",
+			wantGet:    map[string]time.Duration{"read": 5 * time.Second, "write": 10 * time.Second},
+		},
+		"invalid-duration": {
+			in:      "read=5s,write=a",
+			wantErr: `parsing duration for key "write": time: invalid duration "a"`,
+		},
+		"invalid-pair": {
+			in:      "read",
+			wantErr: `parsing duration map: invalid pair "read"`,
+		},
+		"empty-key": {
+			in:      "=5s",
+			wantErr: `parsing duration map: invalid pair "=5s"`,
+		},
+	}
+
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			f := &bee.DurationMap{}
+
+			err := f.Set(tt.in)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("want error %q, got %v", tt.wantErr, err)
+				}
+
+				if len(*f) != 0 {
+					t.Fatalf("want value unchanged on error, got %v", *f)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := f.String(); got != tt.wantString {
+				t.Errorf("want %s got %s", tt.wantString, got)
+			}
+
+			if got := f.Get(); !reflect.DeepEqual(got, tt.wantGet) {
+				t.Errorf("want %v got %v", tt.wantGet, got)
+			}
+		})
+	}
+}
+
 func TestURL(t *testing.T) { //nolint:funlen
 	t.Parallel()
 
@@ -329,6 +746,105 @@ func TestTimeGetNil(t *testing.T) {
 	}
 }
 
+func TestJSON(t *testing.T) {
+	t.Parallel()
+
+	at, err := time.Parse(time.RFC3339, "2002-10-02T15:00:00.05-05:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := url.Parse("https://foo.bar/baz?qux=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type payload struct {
+		Strings bee.StringSlice `json:"strings"`
+		Ints    bee.IntSlice    `json:"ints"`
+		URL     bee.URL         `json:"url"`
+		Time    bee.Time        `json:"time"`
+	}
+
+	in := payload{
+		Strings: bee.StringSlice{"a", "b,c"},
+		Ints:    bee.IntSlice{1, -2},
+		URL:     bee.URL{URL: u},
+		Time:    bee.Time{Time: &at},
+	}
+
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"strings":["a","b,c"],"ints":[1,-2],"url":"https://foo.bar/baz?qux=1","time":"2002-10-02T15:00:00.05-05:00"}`
+	if string(data) != want {
+		t.Errorf("want %s got %s", want, data)
+	}
+
+	var out payload
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(out.Strings, in.Strings) || !reflect.DeepEqual(out.Ints, in.Ints) {
+		t.Errorf("want slices %v %v got %v %v", in.Strings, in.Ints, out.Strings, out.Ints)
+	}
+
+	if out.URL.String() != in.URL.String() {
+		t.Errorf("want url %s got %s", in.URL.String(), out.URL.String())
+	}
+
+	if out.Time.Time == nil || !out.Time.Equal(at) {
+		t.Errorf("want time %v got %v", at, out.Time.Time)
+	}
+}
+
+func TestJSONZeroValues(t *testing.T) {
+	t.Parallel()
+
+	data, err := json.Marshal(struct {
+		Strings bee.StringSlice
+		Ints    bee.IntSlice
+		URL     bee.URL
+		Time    bee.Time
+	}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := `{"Strings":[],"Ints":[],"URL":null,"Time":null}`; string(data) != want {
+		t.Errorf("want %s got %s", want, data)
+	}
+}
+
+func TestJSONErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		value any
+		data  string
+	}{
+		"strings":    {value: &bee.StringSlice{}, data: `"a,b"`},
+		"ints":       {value: &bee.IntSlice{}, data: `["1"]`},
+		"url":        {value: &bee.URL{}, data: `1`},
+		"url_parse":  {value: &bee.URL{}, data: `":foo"`},
+		"time":       {value: &bee.Time{}, data: `1`},
+		"time_parse": {value: &bee.Time{}, data: `"yesterday"`},
+	}
+
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			if err := json.Unmarshal([]byte(tt.data), tt.value); err == nil {
+				t.Errorf("want error for %s", tt.data)
+			}
+		})
+	}
+}
+
 func TestNils(t *testing.T) {
 	t.Parallel()
 
@@ -338,6 +854,29 @@ func TestNils(t *testing.T) {
 	is := (*bee.IntSlice)(nil)
 	_ = is.String()
 
+	fs := (*bee.Float64Slice)(nil)
+	_ = fs.String()
+
+	bs := (*bee.BoolSlice)(nil)
+	_ = bs.String()
+
+	ds := (*bee.DurationSlice)(nil)
+	_ = ds.String()
+
+	ts := (*bee.TimeSlice)(nil)
+	_ = ts.String()
+
+	c := (*bee.Counter)(nil)
+	_ = c.String()
+	_ = c.Get()
+
+	cx := (*bee.Complex)(nil)
+	_ = cx.String()
+	_ = cx.Get()
+
+	dm := (*bee.DurationMap)(nil)
+	_ = dm.String()
+
 	u := (*bee.URL)(nil)
 	_ = u.String()
 